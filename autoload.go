@@ -0,0 +1,63 @@
+package osquery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// AutoloadManifest returns the content of an extensions autoload file (see
+// the osquery --extensions_autoload flag) loading the extension binaries,
+// along with warnings for the binaries osquery would refuse to load.
+//
+// osquery only autoloads regular files with the ".ext" suffix, executable by
+// their owner, and neither the binaries nor their directories may be
+// writable by other users. Ownership (osquery also requires the binaries to
+// be owned by root or the user running osquery) and Windows ACLs are not
+// checked. The paths are made absolute, and an error is returned if a
+// binary cannot be found.
+func AutoloadManifest(binaryPaths ...string) (string, []string, error) {
+	var manifest strings.Builder
+	var warnings []string
+	for _, path := range binaryPaths {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("resolving extension path: %w", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("checking extension: %w", err)
+		}
+		warnings = append(warnings, autoloadWarnings(path, info)...)
+		manifest.WriteString(path)
+		manifest.WriteString("\n")
+	}
+	return manifest.String(), warnings, nil
+}
+
+func autoloadWarnings(path string, info os.FileInfo) []string {
+	var warnings []string
+	if !strings.HasSuffix(path, ".ext") {
+		warnings = append(warnings, fmt.Sprintf("%s: extension file name must end with .ext", path))
+	}
+	if !info.Mode().IsRegular() {
+		warnings = append(warnings, fmt.Sprintf("%s: extension is not a regular file", path))
+		return warnings
+	}
+	if runtime.GOOS == "windows" {
+		return warnings
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: extension is not executable by its owner", path))
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: extension is writable by other users (mode %#o)", path, info.Mode().Perm()))
+	}
+	dir, err := os.Stat(filepath.Dir(path))
+	if err == nil && dir.Mode().Perm()&0022 != 0 {
+		warnings = append(warnings, fmt.Sprintf("%s: extension directory is writable by other users (mode %#o)", path, dir.Mode().Perm()))
+	}
+	return warnings
+}
@@ -0,0 +1,65 @@
+package osquery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoloadManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Chmod(dir, 0755))
+
+	first := filepath.Join(dir, "first.ext")
+	require.NoError(t, ioutil.WriteFile(first, nil, 0700))
+	second := filepath.Join(dir, "second.ext")
+	require.NoError(t, ioutil.WriteFile(second, nil, 0755))
+
+	manifest, warnings, err := AutoloadManifest(first, second)
+	require.NoError(t, err)
+	assert.Equal(t, first+"\n"+second+"\n", manifest)
+	assert.Empty(t, warnings)
+
+	_, _, err = AutoloadManifest(filepath.Join(dir, "missing.ext"))
+	assert.Error(t, err)
+}
+
+func TestAutoloadManifestWarnings(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not checked on windows")
+	}
+
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Chmod(dir, 0755))
+
+	path := filepath.Join(dir, "extension")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	require.NoError(t, os.Chmod(path, 0666))
+
+	manifest, warnings, err := AutoloadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, path+"\n", manifest)
+	assert.Equal(t, []string{
+		path + ": extension file name must end with .ext",
+		path + ": extension is not executable by its owner",
+		path + ": extension is writable by other users (mode 0666)",
+	}, warnings)
+
+	require.NoError(t, os.Chmod(path, 0700))
+	require.NoError(t, os.Chmod(dir, 0777))
+	_, warnings, err = AutoloadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		path + ": extension file name must end with .ext",
+		path + ": extension directory is writable by other users (mode 0777)",
+	}, warnings)
+}
@@ -0,0 +1,128 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/require"
+)
+
+// The benchmarks in this file measure the end-to-end paths between osquery
+// and an extension over real unix sockets. Run them with:
+//
+//	make bench
+//
+// or go test -run '^$' -bench . -benchmem for a subset.
+
+// newBenchTable returns a table with the provided number of columns, each
+// generated row having a 16 byte value in every column.
+func newBenchTable(name string, columns, rows int) *table.Plugin {
+	var defs []table.ColumnDefinition
+	row := map[string]string{}
+	for i := 0; i < columns; i++ {
+		col := "column_" + strconv.Itoa(i)
+		defs = append(defs, table.TextColumn(col))
+		row[col] = "0123456789abcdef"
+	}
+	result := make([]map[string]string, rows)
+	for i := range result {
+		result[i] = row
+	}
+	return table.NewPlugin(name, defs, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return result, nil
+	})
+}
+
+// serveExtension starts an extension serving plugin and returns a client
+// connected to its socket, as osquery would be.
+func serveExtension(b *testing.B, plugin OsqueryPlugin) *osquery.ExtensionClient {
+	dir, err := ioutil.TempDir("", "osq")
+	require.NoError(b, err)
+	b.Cleanup(func() { os.RemoveAll(dir) })
+	sockPath := filepath.Join(dir, "sock")
+
+	manager := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: 1}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := newTestServer(plugin, WithClient(manager))
+	server.sockPath = sockPath
+	server.timeout = 5 * time.Second
+	go server.Start()
+	server.waitStarted()
+	b.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	addr, err := net.ResolveUnixAddr("unix", sockPath+".1")
+	require.NoError(b, err)
+	trans := thrift.NewTSocketFromAddrTimeout(addr, 5*time.Second, 5*time.Second)
+	require.NoError(b, trans.Open())
+	b.Cleanup(func() { trans.Close() })
+	return osquery.NewExtensionClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault())
+}
+
+func BenchmarkRegistration(b *testing.B) {
+	manager := &mock.ExtensionManager{
+		RegisterExtensionFunc: func(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: 1}, nil
+		},
+	}
+	client, err := NewClient(serveManager(b, manager), 5*time.Second)
+	require.NoError(b, err)
+	defer client.Close()
+
+	server := newTestServer(newBenchTable("table_0", 10, 0), WithClient(client))
+	for i := 1; i < 100; i++ {
+		server.RegisterPlugin(newBenchTable(fmt.Sprintf("table_%d", i), 10, 0))
+	}
+	info := &osquery.InternalExtensionInfo{Name: "bench"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := client.RegisterExtension(info, server.genRegistry()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkGenerate(b *testing.B, rows int) {
+	client := serveExtension(b, newBenchTable("bench", 10, rows))
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := client.Call(context.Background(), "table", "bench", request)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(resp.Response) != rows {
+			b.Fatalf("expected %d rows, got %d", rows, len(resp.Response))
+		}
+	}
+}
+
+func BenchmarkGenerateRoundTrip(b *testing.B) {
+	benchmarkGenerate(b, 1)
+}
+
+func BenchmarkGenerateLargeResult(b *testing.B) {
+	benchmarkGenerate(b, 10000)
+}
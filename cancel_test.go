@@ -0,0 +1,173 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goroutinesIn returns the stacks of the goroutines currently running a
+// function whose name contains fn, other than the calling goroutine.
+func goroutinesIn(fn string) []string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	var stacks []string
+	// The first stack is the calling goroutine.
+	for _, stack := range strings.Split(string(buf), "\n\n")[1:] {
+		if strings.Contains(stack, fn) {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks
+}
+
+// requireNoGoroutinesIn fails the test if goroutines running fn remain after a
+// grace period.
+func requireNoGoroutinesIn(t *testing.T, fn string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stacks := goroutinesIn(fn)
+		if len(stacks) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked goroutines in %s:\n%s", fn, strings.Join(stacks, "\n\n"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// callCancelled makes a generate call to the table, cancelling the context
+// once the table started generating, and returns the response.
+func callCancelled(t *testing.T, server *ExtensionManagerServer, item string, started <-chan struct{}) osquery.ExtensionResponse {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	respc := make(chan *osquery.ExtensionResponse, 1)
+	go func() {
+		resp, err := server.Call(ctx, "table", item, osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		assert.NoError(t, err)
+		respc <- resp
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("generate not started")
+	}
+	cancel()
+
+	select {
+	case resp := <-respc:
+		return *resp
+	case <-time.After(time.Second):
+		t.Fatal("call did not return promptly after cancellation")
+		return osquery.ExtensionResponse{}
+	}
+}
+
+func TestCancelGenerate(t *testing.T) {
+	started := make(chan struct{})
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	server := newTestServer(table.NewPlugin("slow", []table.ColumnDefinition{table.TextColumn("foo")}, gen))
+
+	resp := callCancelled(t, server, "slow", started)
+	assert.Equal(t, StatusCanceled, resp.Status.Code)
+	assert.Equal(t, "error generating table: context canceled", resp.Status.Message)
+	requireNoGoroutinesIn(t, "TestCancelGenerate")
+	assert.Equal(t, 0, server.calls.n)
+}
+
+func TestCancelStreamingGenerate(t *testing.T) {
+	started := make(chan struct{})
+	gen := func(ctx context.Context, queryContext table.QueryContext, rows chan<- map[string]string) error {
+		close(started)
+		for {
+			select {
+			case rows <- map[string]string{"foo": "bar"}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	server := newTestServer(table.NewStreamingPlugin("slow", []table.ColumnDefinition{table.TextColumn("foo")}, gen))
+
+	resp := callCancelled(t, server, "slow", started)
+	assert.Equal(t, StatusCanceled, resp.Status.Code)
+	assert.Empty(t, resp.Response)
+	requireNoGoroutinesIn(t, "TestCancelStreamingGenerate")
+	requireNoGoroutinesIn(t, "table.streamRows")
+}
+
+func TestCallTimeoutGenerate(t *testing.T) {
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	server := newTestServer(table.NewPlugin("slow", []table.ColumnDefinition{table.TextColumn("foo")}, gen), WithCallTimeout(20*time.Millisecond))
+
+	start := time.Now()
+	resp, err := server.Call(context.Background(), "table", "slow", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, StatusDeadlineExceeded, resp.Status.Code)
+	assert.Equal(t, "error generating table: context deadline exceeded", resp.Status.Message)
+	requireNoGoroutinesIn(t, "TestCallTimeoutGenerate")
+}
+
+func TestGenerateErrorNotCanceled(t *testing.T) {
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return nil, errors.New("backend unavailable")
+	}
+	server := newTestServer(table.NewPlugin("broken", []table.ColumnDefinition{table.TextColumn("foo")}, gen), WithCallTimeout(time.Minute))
+
+	resp, err := server.Call(context.Background(), "table", "broken", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: backend unavailable", resp.Status.Message)
+}
+
+func TestCancelQueryInGenerate(t *testing.T) {
+	release := make(chan struct{})
+	mock := &MockExtensionManager{
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			<-release
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}, nil
+		},
+	}
+	started := make(chan struct{})
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		close(started)
+		return QueryInGenerate(ctx, "select * from processes")
+	}
+	server := newTestServer(table.NewPlugin("enriched", []table.ColumnDefinition{table.TextColumn("foo")}, gen), WithClient(mock))
+
+	resp := callCancelled(t, server, "enriched", started)
+	assert.Equal(t, "error generating table: context canceled", resp.Status.Message)
+
+	// The query in flight completes in the background.
+	close(release)
+	requireNoGoroutinesIn(t, "osquery.QueryInGenerate")
+}
@@ -0,0 +1,102 @@
+package osquery
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// CellLimitAction controls what happens when a row value returned by a plugin
+// exceeds the limit set with WithMaxCellBytes.
+type CellLimitAction int
+
+const (
+	// CellLimitTruncate truncates oversized values, marking them with
+	// TruncatedCellSuffix.
+	CellLimitTruncate CellLimitAction = iota
+	// CellLimitError fails the call with an error status.
+	CellLimitError
+)
+
+// TruncatedCellSuffix is appended to values truncated by CellLimitTruncate.
+const TruncatedCellSuffix = "...[truncated]"
+
+// WithMaxCellBytes limits the size of the individual row values generated by
+// tables to max bytes. Values over the limit are logged with the plugin and
+// column name, and then either truncated or cause the call to fail, according
+// to action. Truncated values, including the suffix marker, fit within max
+// bytes, and TEXT values are only cut at character boundaries. A max of zero
+// or less disables the limit, which is the default.
+//
+// The responses of other plugins, such as the documents returned by config
+// and distributed plugins, are not limited.
+func WithMaxCellBytes(max int, action CellLimitAction) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.maxCellBytes = max
+		s.cellLimitAction = action
+	}
+}
+
+// limitCells applies the cell size limit to a table generate response,
+// logging each oversized column once. Rows are copied before being modified,
+// as plugins may retain them.
+func (s *ExtensionManagerServer) limitCells(id, registry, item string, response *osquery.ExtensionResponse, blobs map[string]bool) {
+	rows := response.Response
+	logged := map[string]bool{}
+	var limited osquery.ExtensionPluginResponse
+	for i, row := range rows {
+		var copied map[string]string
+		for k, v := range row {
+			if len(v) <= s.maxCellBytes {
+				continue
+			}
+			if !logged[k] {
+				logged[k] = true
+				s.log("level", "warn", "msg", "cell exceeds maximum size", "plugin", registry+"/"+item, "column", k, "bytes", len(v), "max", s.maxCellBytes, "request_id", id)
+			}
+			if s.cellLimitAction == CellLimitError {
+				response.Status = &osquery.ExtensionStatus{
+					Code:    1,
+					Message: fmt.Sprintf("value of column %s is %d bytes, exceeding the maximum of %d", k, len(v), s.maxCellBytes),
+				}
+				response.Response = nil
+				return
+			}
+			if copied == nil {
+				copied = make(map[string]string, len(row))
+				for k, v := range row {
+					copied[k] = v
+				}
+			}
+			copied[k] = truncateCell(v, s.maxCellBytes, blobs[k])
+		}
+		if copied == nil {
+			continue
+		}
+		if limited == nil {
+			limited = append(osquery.ExtensionPluginResponse(nil), rows...)
+		}
+		limited[i] = copied
+	}
+
+	if limited != nil {
+		response.Response = limited
+	}
+}
+
+// truncateCell truncates v to at most max bytes including the suffix marker.
+// Unless raw is set, v is not cut within a UTF-8 sequence.
+func truncateCell(v string, max int, raw bool) string {
+	suffix := TruncatedCellSuffix
+	if max <= len(suffix) {
+		suffix = ""
+	}
+	n := max - len(suffix)
+	if !raw {
+		for n > 0 && !utf8.RuneStart(v[n]) {
+			n--
+		}
+	}
+	return v[:n] + suffix
+}
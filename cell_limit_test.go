@@ -0,0 +1,106 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCellLimitServer(rows []map[string]string, opts ...ServerOption) *ExtensionManagerServer {
+	plugin := table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("path"), table.TextColumn("contents")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return rows, nil
+		},
+	)
+	return newTestServer(plugin, opts...)
+}
+
+func TestMaxCellBytesTruncate(t *testing.T) {
+	rows := []map[string]string{
+		{"path": "/big", "contents": strings.Repeat("a", 100)},
+		{"path": "/small", "contents": "ok"},
+		{"path": "/big2", "contents": strings.Repeat("b", 50)},
+	}
+	logger := &testLogger{}
+	server := newCellLimitServer(rows, WithMaxCellBytes(32, CellLimitTruncate), WithLogger(logger))
+
+	resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code)
+	require.Len(t, resp.Response, 3)
+
+	expected := strings.Repeat("a", 32-len(TruncatedCellSuffix)) + TruncatedCellSuffix
+	assert.Equal(t, expected, resp.Response[0]["contents"])
+	assert.Len(t, resp.Response[0]["contents"], 32)
+	assert.Equal(t, "/big", resp.Response[0]["path"])
+	assert.Equal(t, map[string]string{"path": "/small", "contents": "ok"}, resp.Response[1])
+	assert.True(t, strings.HasSuffix(resp.Response[2]["contents"], TruncatedCellSuffix))
+
+	// The column is only logged once per call
+	require.Len(t, logger.lines(), 1)
+	assert.True(t, strings.HasPrefix(logger.lines()[0], fmt.Sprint("level", "warn", "msg", "cell exceeds maximum size", "plugin", "table/files", "column", "contents", "bytes", 100, "max", 32, "request_id")))
+
+	// The rows returned by the plugin are not modified
+	assert.Len(t, rows[0]["contents"], 100)
+}
+
+func TestMaxCellBytesError(t *testing.T) {
+	rows := []map[string]string{
+		{"path": "/small", "contents": "ok"},
+		{"path": "/big", "contents": strings.Repeat("a", 100)},
+	}
+	logger := &testLogger{}
+	server := newCellLimitServer(rows, WithMaxCellBytes(32, CellLimitError), WithLogger(logger))
+
+	resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "value of column contents is 100 bytes, exceeding the maximum of 32", resp.Status.Message)
+	assert.Empty(t, resp.Response)
+	require.Len(t, logger.lines(), 1)
+	assert.True(t, strings.HasPrefix(logger.lines()[0], fmt.Sprint("level", "warn", "msg", "cell exceeds maximum size", "plugin", "table/files", "column", "contents", "bytes", 100, "max", 32, "request_id")))
+}
+
+func TestMaxCellBytesOnlyTables(t *testing.T) {
+	doc := `{"schedule": {"big": {"query": "` + strings.Repeat("a", 100) + `"}}}`
+	plugin := config.NewPlugin("fleet", func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"main": doc}, nil
+	})
+	logger := &testLogger{}
+	server := newTestServer(plugin, WithMaxCellBytes(32, CellLimitError), WithLogger(logger))
+
+	resp, err := server.Call(context.Background(), "config", "fleet", osquery.ExtensionPluginRequest{"action": "genConfig"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"main": doc}}, resp.Response)
+	assert.Empty(t, logger.lines())
+}
+
+func TestTruncateCell(t *testing.T) {
+	var testCases = []struct {
+		value    string
+		max      int
+		raw      bool
+		expected string
+	}{
+		{"abcdefghijklmnopqrstuvwxyz", 20, false, "abcdef" + TruncatedCellSuffix},
+		// Multi-byte characters are not split
+		{"abcdeéééé", 20, false, "abcde" + TruncatedCellSuffix},
+		{"abcdeéééé", 20, true, "abcde\xc3" + TruncatedCellSuffix},
+		// Limits too small for the marker truncate without it
+		{"abcdefghijklmnopqrstuvwxyz", 4, false, "abcd"},
+	}
+
+	for _, tt := range testCases {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tt.expected, truncateCell(tt.value, tt.max, tt.raw))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// NewCircuitBreakerTablePlugin wraps a table plugin so that, once threshold
+// consecutive generate calls failed (returned a non-zero status), the
+// following generate calls fail immediately with an error status, without
+// calling the plugin, for the cooldown period. This spares a backend that is
+// down, as well as the extension, from the queries that would fail anyway.
+//
+// Once the cooldown expires, a single generate call is passed to the plugin
+// as a trial while the others keep failing: the calls are passed again if it
+// succeeds, otherwise they fail for another cooldown period. Other actions are
+// always passed to the plugin. A threshold of zero or less disables the
+// breaker.
+func NewCircuitBreakerTablePlugin(inner OsqueryPlugin, threshold int, cooldown time.Duration) OsqueryPlugin {
+	return &circuitBreakerPlugin{OsqueryPlugin: inner, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// The states of a circuitBreakerPlugin.
+const (
+	// circuitClosed passes the calls to the plugin.
+	circuitClosed = iota
+	// circuitOpen fails the calls until the cooldown expires.
+	circuitOpen
+	// circuitHalfOpen passes a trial call, failing the others.
+	circuitHalfOpen
+)
+
+type circuitBreakerPlugin struct {
+	OsqueryPlugin
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+func (p *circuitBreakerPlugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	if request["action"] != "generate" || p.threshold <= 0 {
+		return p.OsqueryPlugin.Call(ctx, request)
+	}
+	if !p.allow() {
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: fmt.Sprintf("table unavailable: %d consecutive generate calls failed", p.threshold),
+			},
+		}
+	}
+
+	response := p.OsqueryPlugin.Call(ctx, request)
+	p.record(response.Status != nil && response.Status.Code == 0)
+	return response
+}
+
+// allow returns true if a generate call may be passed to the plugin.
+func (p *circuitBreakerPlugin) allow() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case circuitOpen:
+		if p.now().Sub(p.openedAt) < p.cooldown {
+			return false
+		}
+		p.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// A trial call is in progress.
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the state with the outcome of a generate call.
+func (p *circuitBreakerPlugin) record(ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ok {
+		p.state = circuitClosed
+		p.failures = 0
+		return
+	}
+	p.failures++
+	if p.state == circuitHalfOpen || p.failures >= p.threshold {
+		p.state = circuitOpen
+		p.openedAt = p.now()
+	}
+}
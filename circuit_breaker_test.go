@@ -0,0 +1,105 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerTablePlugin(t *testing.T) {
+	var calls int
+	var failing bool
+	inner := table.NewPlugin("flaky", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			calls++
+			if failing {
+				return nil, errors.New("backend down")
+			}
+			return []map[string]string{{"foo": "bar"}}, nil
+		},
+	)
+	plugin := NewCircuitBreakerTablePlugin(inner, 3, time.Minute)
+	now := time.Unix(1000, 0)
+	plugin.(*circuitBreakerPlugin).now = func() time.Time { return now }
+
+	generate := func() osquery.ExtensionResponse {
+		return plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+	const openMessage = "table unavailable: 3 consecutive generate calls failed"
+
+	// Closed: failures below the threshold are passed through.
+	failing = true
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, "error generating table: backend down", generate().Status.Message)
+	}
+	failing = false
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, 3, calls)
+
+	// Open after threshold consecutive failures.
+	failing = true
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "error generating table: backend down", generate().Status.Message)
+	}
+	assert.Equal(t, 6, calls)
+	resp := generate()
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, openMessage, resp.Status.Message)
+	assert.Equal(t, 6, calls)
+
+	// Other actions are passed through.
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "columns"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+
+	// Half-open after the cooldown: a failing trial opens the circuit
+	// again.
+	now = now.Add(time.Minute)
+	assert.Equal(t, "error generating table: backend down", generate().Status.Message)
+	assert.Equal(t, 7, calls)
+	assert.Equal(t, openMessage, generate().Status.Message)
+	assert.Equal(t, 7, calls)
+
+	// A successful trial closes it.
+	now = now.Add(time.Minute)
+	failing = false
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, 9, calls)
+}
+
+func TestCircuitBreakerHalfOpenSingleTrial(t *testing.T) {
+	plugin := NewCircuitBreakerTablePlugin(newTestTable("foo"), 1, time.Minute).(*circuitBreakerPlugin)
+	now := time.Unix(1000, 0)
+	plugin.now = func() time.Time { return now }
+
+	plugin.record(false)
+	assert.False(t, plugin.allow())
+	now = now.Add(time.Minute)
+	// Only one call is allowed while the trial is in progress.
+	assert.True(t, plugin.allow())
+	assert.False(t, plugin.allow())
+	plugin.record(true)
+	assert.True(t, plugin.allow())
+	assert.True(t, plugin.allow())
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	calls := 0
+	inner := table.NewPlugin("down", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			calls++
+			return nil, errors.New("backend down")
+		},
+	)
+	plugin := NewCircuitBreakerTablePlugin(inner, 0, time.Minute)
+	for i := 0; i < 5; i++ {
+		plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, "down", plugin.Name())
+}
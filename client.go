@@ -2,17 +2,18 @@ package osquery
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/osquery/osquery-go/gen/osquery"
-	"github.com/osquery/osquery-go/transport"
-	"github.com/pkg/errors"
-
-	"github.com/apache/thrift/lib/go/thrift"
 )
 
 type ExtensionManager interface {
-	Close()
+	Close() error
 	Ping() (*osquery.ExtensionStatus, error)
 	Call(registry, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error)
 	Extensions() (osquery.InternalExtensionList, error)
@@ -25,70 +26,211 @@ type ExtensionManager interface {
 
 // ExtensionManagerClient is a wrapper for the osquery Thrift extensions API.
 type ExtensionManagerClient struct {
-	Client    osquery.ExtensionManager
-	transport thrift.TTransport
+	// Client is the Thrift client used by the ExtensionManagerClient
+	// methods. For clients created by NewClient, it dispatches each call
+	// over the connection pool, so it is safe for concurrent use along
+	// with the methods.
+	Client osquery.ExtensionManager
+
+	pool            *connPool
+	maxConns        int
+	maxResponseSize int64
+
+	// closed is set to 1 by Close, for clients constructed without a
+	// pool.
+	closed int32
+
+	// fromEnv is set by ClientFromEnv.
+	fromEnv bool
+
+	// uuid is the UUID assigned by osquery to the extension registered
+	// with RegisterExtension, if registered is set.
+	uuidMu     sync.Mutex
+	uuid       osquery.ExtensionRouteUUID
+	registered bool
+}
+
+// ClientOption allows for setting optional settings on an
+// ExtensionManagerClient.
+type ClientOption func(*ExtensionManagerClient)
+
+// WithMaxConns sets the maximum number of connections the client opens to
+// osquery. Each connection serves one call at a time, so this is the maximum
+// number of concurrent calls. Connections are opened on demand. The default
+// is 1.
+func WithMaxConns(n int) ClientOption {
+	return func(c *ExtensionManagerClient) {
+		if n > 0 {
+			c.maxConns = n
+		}
+	}
 }
 
 // NewClient creates a new client communicating to osquery over the socket at
 // the provided path. If resolving the address or connecting to the socket
 // fails, this function will error.
-func NewClient(path string, timeout time.Duration) (*ExtensionManagerClient, error) {
-	trans, err := transport.Open(path, timeout)
+//
+// The client is client-only: it never registers an extension, so tools that
+// only issue queries leave nothing behind in osquery's extension list once the
+// client is closed. ExtensionManagerServer also uses NewClient to communicate
+// with osquery, registering its extension separately.
+func NewClient(path string, timeout time.Duration, opts ...ClientOption) (*ExtensionManagerClient, error) {
+	c := &ExtensionManagerClient{maxConns: 1}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.fromEnv {
+		if path == "" {
+			path = os.Getenv(EnvSocket)
+		}
+		if timeout == 0 {
+			var err error
+			if timeout, err = envDuration(EnvTimeout); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	c.pool = newConnPool(path, timeout, c.maxConns)
+	c.pool.maxMessageSize = c.maxResponseSize
+
+	// Open the first connection eagerly so that connection errors are
+	// reported by NewClient.
+	conn, err := c.pool.get()
 	if err != nil {
 		return nil, err
 	}
+	c.pool.put(conn, nil)
+	c.Client = pooledClient{pool: c.pool}
 
-	client := osquery.NewExtensionManagerClientFactory(
-		trans,
-		thrift.NewTBinaryProtocolFactoryDefault(),
-	)
-
-	return &ExtensionManagerClient{client, trans}, nil
+	return c, nil
 }
 
 // Close should be called to close the transport when use of the client is
-// completed.
-func (c *ExtensionManagerClient) Close() {
-	if c.transport != nil && c.transport.IsOpen() {
-		c.transport.Close()
+// completed. Calls made after Close return ErrClosed. Closing a client more
+// than once is safe, and only the first Close returns an error.
+func (c *ExtensionManagerClient) Close() error {
+	if c.pool != nil {
+		return c.pool.close()
+	}
+	atomic.StoreInt32(&c.closed, 1)
+	return nil
+}
+
+// do runs fn with the Thrift client. Clients constructed without a pool
+// check for Close themselves.
+func (c *ExtensionManagerClient) do(fn func(client osquery.ExtensionManager) error) error {
+	if c.pool == nil && atomic.LoadInt32(&c.closed) == 1 {
+		return ErrClosed
 	}
+	return fn(c.Client)
 }
 
 // Ping requests metadata from the extension manager.
 func (c *ExtensionManagerClient) Ping() (*osquery.ExtensionStatus, error) {
-	return c.Client.Ping(context.Background())
+	var res *osquery.ExtensionStatus
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.Ping(context.Background())
+		return err
+	})
+	return res, err
 }
 
 // Call requests a call to an extension (or core) registry plugin.
 func (c *ExtensionManagerClient) Call(registry, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
-	return c.Client.Call(context.Background(), registry, item, request)
+	var res *osquery.ExtensionResponse
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.Call(context.Background(), registry, item, request)
+		return err
+	})
+	return res, err
 }
 
 // Extensions requests the list of active registered extensions.
 func (c *ExtensionManagerClient) Extensions() (osquery.InternalExtensionList, error) {
-	return c.Client.Extensions(context.Background())
+	var res osquery.InternalExtensionList
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.Extensions(context.Background())
+		return err
+	})
+	return res, err
 }
 
 // RegisterExtension registers the extension plugins with the osquery process.
+// On success, the UUID assigned by osquery is kept by the client (see UUID).
 func (c *ExtensionManagerClient) RegisterExtension(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
-	return c.Client.RegisterExtension(context.Background(), info, registry)
+	var res *osquery.ExtensionStatus
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.RegisterExtension(context.Background(), info, registry)
+		return err
+	})
+	if err == nil && res != nil && res.Code == 0 {
+		c.uuidMu.Lock()
+		c.uuid, c.registered = res.UUID, true
+		c.uuidMu.Unlock()
+	}
+	return res, err
 }
 
 // DeregisterExtension de-registers the extension plugins with the osquery process.
 func (c *ExtensionManagerClient) DeregisterExtension(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
-	return c.Client.DeregisterExtension(context.Background(), uuid)
+	var res *osquery.ExtensionStatus
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.DeregisterExtension(context.Background(), uuid)
+		return err
+	})
+	if err == nil && res != nil && res.Code == 0 {
+		c.uuidMu.Lock()
+		if c.registered && c.uuid == uuid {
+			c.uuid, c.registered = 0, false
+		}
+		c.uuidMu.Unlock()
+	}
+	return res, err
+}
+
+// UUID returns the UUID assigned by osquery to the extension registered with
+// RegisterExtension, if it is still registered. Of the calls in the osquery
+// extension manager API, only DeregisterExtension takes the UUID, for which
+// Deregister supplies it. osquery also uses the UUID to name the socket of the
+// extension, "<socket>.<uuid>".
+func (c *ExtensionManagerClient) UUID() (osquery.ExtensionRouteUUID, bool) {
+	c.uuidMu.Lock()
+	defer c.uuidMu.Unlock()
+	return c.uuid, c.registered
+}
+
+// Deregister de-registers the extension registered with RegisterExtension,
+// using the UUID assigned by osquery. It returns ErrNotRegistered if no
+// extension is registered through the client.
+func (c *ExtensionManagerClient) Deregister() (*osquery.ExtensionStatus, error) {
+	uuid, ok := c.UUID()
+	if !ok {
+		return nil, ErrNotRegistered
+	}
+	return c.DeregisterExtension(uuid)
 }
 
 // Options requests the list of bootstrap or configuration options.
 func (c *ExtensionManagerClient) Options() (osquery.InternalOptionList, error) {
-	return c.Client.Options(context.Background())
+	var res osquery.InternalOptionList
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.Options(context.Background())
+		return err
+	})
+	return res, err
 }
 
 // Query requests a query to be run and returns the extension response.
 // Consider using the QueryRow or QueryRows helpers for a more friendly
 // interface.
 func (c *ExtensionManagerClient) Query(sql string) (*osquery.ExtensionResponse, error) {
-	return c.Client.Query(context.Background(), sql)
+	var res *osquery.ExtensionResponse
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.Query(context.Background(), sql)
+		return err
+	})
+	return res, err
 }
 
 // QueryRows is a helper that executes the requested query and returns the
@@ -97,13 +239,17 @@ func (c *ExtensionManagerClient) Query(sql string) (*osquery.ExtensionResponse,
 func (c *ExtensionManagerClient) QueryRows(sql string) ([]map[string]string, error) {
 	res, err := c.Query(sql)
 	if err != nil {
-		return nil, errors.Wrap(err, "transport error in query")
+		return nil, fmt.Errorf("transport error in query: %w", err)
 	}
 	if res.Status == nil {
 		return nil, errors.New("query returned nil status")
 	}
 	if res.Status.Code != 0 {
-		return nil, errors.Errorf("query returned error: %s", res.Status.Message)
+		return nil, &OsqueryError{
+			Code:    int(res.Status.Code),
+			Message: res.Status.Message,
+			Query:   sql,
+		}
 	}
 	return res.Response, nil
 
@@ -117,12 +263,107 @@ func (c *ExtensionManagerClient) QueryRow(sql string) (map[string]string, error)
 		return nil, err
 	}
 	if len(res) != 1 {
-		return nil, errors.Errorf("expected 1 row, got %d", len(res))
+		return nil, fmt.Errorf("expected 1 row, got %d", len(res))
 	}
 	return res[0], nil
 }
 
+// QueryResult is the outcome of one of the queries run by QueryMulti.
+type QueryResult struct {
+	// Query is the SQL of the query.
+	Query string
+	// Rows are the rows returned by the query, if it succeeded.
+	Rows []map[string]string
+	// Err is the error returned by QueryRows for the query, or the context
+	// error if the query did not complete before the context was done.
+	Err error
+}
+
+// QueryMulti runs the provided queries concurrently, up to the maximum number
+// of connections of the client (see WithMaxConns), and returns their results
+// in the same order as the queries. A failing query does not affect the
+// others, its error is reported in its result.
+//
+// If ctx is done before all the queries complete, QueryMulti returns
+// immediately with the results collected so far and the context error. The
+// results of the queries that did not complete have Err set to the context
+// error. Queries already sent to osquery are not interrupted.
+func (c *ExtensionManagerClient) QueryMulti(ctx context.Context, queries []string) ([]QueryResult, error) {
+	type indexedResult struct {
+		i int
+		QueryResult
+	}
+
+	slots := c.maxConns
+	if c.pool == nil || slots < 1 {
+		slots = 1
+	}
+	sem := make(chan struct{}, slots)
+	// Buffered so that queries completing after ctx is done do not block.
+	resc := make(chan indexedResult, len(queries))
+	for i, sql := range queries {
+		go func(i int, sql string) {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				resc <- indexedResult{i, QueryResult{Query: sql, Err: ctx.Err()}}
+				return
+			}
+			defer func() { <-sem }()
+
+			rows, err := c.QueryRows(sql)
+			resc <- indexedResult{i, QueryResult{Query: sql, Rows: rows, Err: err}}
+		}(i, sql)
+	}
+
+	results := make([]QueryResult, len(queries))
+	done := make([]bool, len(queries))
+	for range queries {
+		select {
+		case res := <-resc:
+			results[res.i] = res.QueryResult
+			done[res.i] = true
+		case <-ctx.Done():
+			for i, sql := range queries {
+				if !done[i] {
+					results[i] = QueryResult{Query: sql, Err: ctx.Err()}
+				}
+			}
+			return results, ctx.Err()
+		}
+	}
+	return results, nil
+}
+
+// CallExtension is a helper that calls the registry plugin and returns the
+// response rows. It handles checking both the transport level errors and the
+// status returned by the plugin, which is returned as an *OsqueryError.
+func (c *ExtensionManagerClient) CallExtension(registry, item string, request osquery.ExtensionPluginRequest) ([]map[string]string, error) {
+	res, err := c.Call(registry, item, request)
+	if err != nil {
+		return nil, fmt.Errorf("transport error in call: %w", err)
+	}
+	if res.Status == nil {
+		return nil, errors.New("call returned nil status")
+	}
+	if res.Status.Code != 0 {
+		return nil, &OsqueryError{
+			Code:     int(res.Status.Code),
+			Message:  res.Status.Message,
+			Registry: registry,
+			Item:     item,
+			Action:   request["action"],
+		}
+	}
+	return res.Response, nil
+}
+
 // GetQueryColumns requests the columns returned by the parsed query.
 func (c *ExtensionManagerClient) GetQueryColumns(sql string) (*osquery.ExtensionResponse, error) {
-	return c.Client.GetQueryColumns(context.Background(), sql)
+	var res *osquery.ExtensionResponse
+	err := c.do(func(client osquery.ExtensionManager) (err error) {
+		res, err = client.GetQueryColumns(context.Background(), sql)
+		return err
+	})
+	return res, err
 }
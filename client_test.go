@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/osquery/osquery-go/gen/osquery"
 	"github.com/osquery/osquery-go/mock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestQueryRows(t *testing.T) {
@@ -40,8 +42,16 @@ func TestQueryRows(t *testing.T) {
 	}
 	rows, err = client.QueryRows("select bad query")
 	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrQueryFailed))
+	var osqErr *OsqueryError
+	if assert.True(t, errors.As(err, &osqErr)) {
+		assert.Equal(t, 1, osqErr.Code)
+		assert.Equal(t, "bad query", osqErr.Message)
+		assert.Equal(t, "select bad query", osqErr.Query)
+	}
 	row, err = client.QueryRow("select bad query")
 	assert.NotNil(t, err)
+	assert.True(t, errors.Is(err, ErrQueryFailed))
 
 	// Good query (one row)
 	expectedRows := []map[string]string{
@@ -77,3 +87,210 @@ func TestQueryRows(t *testing.T) {
 	row, err = client.QueryRow("select 1 union select 2")
 	assert.NotNil(t, err)
 }
+
+func TestCallExtension(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+	request := osquery.ExtensionPluginRequest{"action": "generate"}
+
+	// Transport related error
+	mock.CallFunc = func(ctx context.Context, registry, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+		return nil, errors.New("boom!")
+	}
+	_, err := client.CallExtension("table", "foo", request)
+	assert.NotNil(t, err)
+
+	// Plugin error
+	mock.CallFunc = func(ctx context.Context, registry, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table"},
+		}, nil
+	}
+	_, err = client.CallExtension("table", "foo", request)
+	var osqErr *OsqueryError
+	if assert.True(t, errors.As(err, &osqErr)) {
+		assert.Equal(t, 1, osqErr.Code)
+		assert.Equal(t, "no such table", osqErr.Message)
+		assert.Equal(t, "table", osqErr.Registry)
+		assert.Equal(t, "foo", osqErr.Item)
+		assert.Equal(t, "generate", osqErr.Action)
+	}
+	assert.False(t, errors.Is(err, ErrQueryFailed))
+	assert.EqualError(t, err, `call to table/foo returned error: no such table (status 1, action "generate")`)
+
+	// Success
+	expected := []map[string]string{{"foo": "bar"}}
+	mock.CallFunc = func(ctx context.Context, registry, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: expected,
+		}, nil
+	}
+	rows, err := client.CallExtension("table", "foo", request)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, rows)
+}
+
+func TestNewClientDoesNotRegister(t *testing.T) {
+	manager := &mock.ExtensionManager{
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: []map[string]string{{"1": "1"}},
+			}, nil
+		},
+		RegisterExtensionFunc: func(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: 1}, nil
+		},
+	}
+	path := serveManager(t, manager)
+
+	client, err := NewClient(path, 5*time.Second)
+	require.NoError(t, err)
+	rows, err := client.QueryRows("select 1")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"1": "1"}}, rows)
+	client.Close()
+
+	assert.True(t, manager.QueryFuncInvoked)
+	assert.False(t, manager.RegisterExtensionFuncInvoked)
+	assert.False(t, manager.DeregisterExtensionFuncInvoked)
+}
+
+func TestQueryMulti(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		switch sql {
+		case "select bad":
+			return &osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{Code: 1, Message: "bad query"},
+			}, nil
+		case "select broken":
+			return nil, errors.New("boom!")
+		}
+		return &osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: []map[string]string{{"sql": sql}},
+		}, nil
+	}
+
+	queries := []string{"select 1", "select bad", "select 2", "select broken"}
+	results, err := client.QueryMulti(context.Background(), queries)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	for i, res := range results {
+		assert.Equal(t, queries[i], res.Query)
+	}
+
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, []map[string]string{{"sql": "select 1"}}, results[0].Rows)
+	assert.True(t, errors.Is(results[1].Err, ErrQueryFailed))
+	assert.Nil(t, results[1].Rows)
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, []map[string]string{{"sql": "select 2"}}, results[2].Rows)
+	assert.Error(t, results[3].Err)
+}
+
+func TestQueryMultiContextDone(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+	release := make(chan struct{})
+	defer close(release)
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		<-release
+		return &osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results, err := client.QueryMulti(ctx, []string{"select 1", "select 2"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		assert.Equal(t, context.DeadlineExceeded, res.Err)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	manager := &mock.ExtensionManager{
+		PingFunc: func(ctx context.Context) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+		},
+	}
+	path := serveManager(t, manager)
+
+	client, err := NewClient(path, 5*time.Second)
+	require.NoError(t, err)
+	_, err = client.Ping()
+	require.NoError(t, err)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, client.Close())
+
+	_, err = client.Ping()
+	assert.True(t, errors.Is(err, ErrClosed))
+	_, err = client.QueryRows("select 1")
+	assert.True(t, errors.Is(err, ErrClosed))
+}
+
+func TestClientCloseWithoutPool(t *testing.T) {
+	client := &ExtensionManagerClient{Client: &mock.ExtensionManager{}}
+	assert.NoError(t, client.Close())
+	assert.NoError(t, client.Close())
+	_, err := client.Query("select 1")
+	assert.True(t, errors.Is(err, ErrClosed))
+}
+
+func TestClientDeregisterUsesUUID(t *testing.T) {
+	var deregistered []osquery.ExtensionRouteUUID
+	mock := &mock.ExtensionManager{
+		RegisterExtensionFunc: func(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: 42}, nil
+		},
+		DeregisterExtensionFunc: func(ctx context.Context, uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			deregistered = append(deregistered, uuid)
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+		},
+	}
+	client := &ExtensionManagerClient{Client: mock}
+
+	_, ok := client.UUID()
+	assert.False(t, ok)
+	_, err := client.Deregister()
+	assert.True(t, errors.Is(err, ErrNotRegistered))
+	assert.False(t, mock.DeregisterExtensionFuncInvoked)
+
+	_, err = client.RegisterExtension(&osquery.InternalExtensionInfo{Name: "test"}, osquery.ExtensionRegistry{})
+	require.NoError(t, err)
+	uuid, ok := client.UUID()
+	assert.True(t, ok)
+	assert.Equal(t, osquery.ExtensionRouteUUID(42), uuid)
+
+	status, err := client.Deregister()
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+	assert.Equal(t, []osquery.ExtensionRouteUUID{42}, deregistered)
+
+	// The UUID is forgotten once deregistered
+	_, ok = client.UUID()
+	assert.False(t, ok)
+	_, err = client.Deregister()
+	assert.True(t, errors.Is(err, ErrNotRegistered))
+}
+
+func TestClientFailedRegistrationKeepsNoUUID(t *testing.T) {
+	mock := &mock.ExtensionManager{
+		RegisterExtensionFunc: func(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 1, Message: "duplicate extension", UUID: 7}, nil
+		},
+	}
+	client := &ExtensionManagerClient{Client: mock}
+
+	_, err := client.RegisterExtension(&osquery.InternalExtensionInfo{Name: "test"}, osquery.ExtensionRegistry{})
+	require.NoError(t, err)
+	_, ok := client.UUID()
+	assert.False(t, ok)
+}
@@ -0,0 +1,88 @@
+package osquery
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrTableCollision indicates that a table plugin has the same name as a
+// table already registered with osquery.
+var ErrTableCollision = errors.New("table name collides with an existing osquery table")
+
+// WithRejectTableCollisions causes Start to fail with ErrTableCollision,
+// without registering the extension, if any of the table plugins have the same
+// name as a table already registered with osquery. By default collisions are
+// only logged.
+func WithRejectTableCollisions() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.rejectTableCollisions = true
+	}
+}
+
+// TableCollisions returns the names of the table plugins that have the same
+// name as a table already registered with the osquery instance, whether a core
+// table or one registered by another extension. The names are sorted.
+func TableCollisions(client ExtensionManager, plugins ...OsqueryPlugin) ([]string, error) {
+	const sql = "SELECT name FROM osquery_registry WHERE registry = 'table'"
+	resp, err := client.Query(sql)
+	if err != nil {
+		return nil, fmt.Errorf("querying osquery tables: %w", err)
+	}
+	if resp.Status == nil {
+		return nil, errors.New("querying osquery tables: nil status")
+	}
+	if resp.Status.Code != 0 {
+		return nil, &OsqueryError{
+			Code:    int(resp.Status.Code),
+			Message: resp.Status.Message,
+			Query:   sql,
+		}
+	}
+
+	existing := map[string]bool{}
+	for _, row := range resp.Response {
+		existing[row["name"]] = true
+	}
+
+	var collisions []string
+	for _, plugin := range plugins {
+		if plugin.RegistryName() == "table" && existing[plugin.Name()] {
+			collisions = append(collisions, plugin.Name())
+		}
+	}
+	sort.Strings(collisions)
+	return collisions, nil
+}
+
+// checkTableCollisions logs the table plugins colliding with existing osquery
+// tables, returning an error if collisions are rejected. The check is only
+// performed if there is a logger or collisions are rejected. s.mutex must be
+// held.
+func (s *ExtensionManagerServer) checkTableCollisions() error {
+	if s.logger == nil && !s.rejectTableCollisions {
+		return nil
+	}
+
+	var plugins []OsqueryPlugin
+	for _, plugin := range s.registry["table"] {
+		plugins = append(plugins, plugin)
+	}
+	if len(plugins) == 0 {
+		return nil
+	}
+
+	collisions, err := TableCollisions(s.serverClient, plugins...)
+	if err != nil {
+		s.log("level", "warn", "msg", "checking for table collisions", "err", err)
+		return nil
+	}
+	for _, name := range collisions {
+		s.log("level", "warn", "msg", ErrTableCollision.Error(), "table", name)
+	}
+	if len(collisions) > 0 && s.rejectTableCollisions {
+		return wrapSentinel(ErrTableCollision, errors.New(strings.Join(collisions, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger records the formatted keyvals of each Log call.
+type testLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *testLogger) Log(keyvals ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprint(keyvals...))
+	return nil
+}
+
+func (l *testLogger) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.logs...)
+}
+
+// newCollisionsMock returns a mock manager reporting the provided existing
+// tables.
+func newCollisionsMock(tables ...string) *MockExtensionManager {
+	return &MockExtensionManager{
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			var rows osquery.ExtensionPluginResponse
+			for _, name := range tables {
+				rows = append(rows, map[string]string{"name": name})
+			}
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: rows,
+			}, nil
+		},
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			// Fail registration so that Start returns
+			return nil, errors.New("boom!")
+		},
+	}
+}
+
+func newTestTable(name string) *table.Plugin {
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return nil, nil
+	}
+	return table.NewPlugin(name, []table.ColumnDefinition{table.TextColumn("foo")}, gen)
+}
+
+func TestTableCollisions(t *testing.T) {
+	mock := newCollisionsMock("processes", "users", "my_table")
+
+	collisions, err := TableCollisions(mock, newTestTable("users"), newTestTable("processes"), newTestTable("other"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"processes", "users"}, collisions)
+
+	collisions, err = TableCollisions(mock, newTestTable("other"))
+	require.NoError(t, err)
+	assert.Empty(t, collisions)
+}
+
+func TestStartLogsTableCollisions(t *testing.T) {
+	mock := newCollisionsMock("processes", "users")
+	logger := &testLogger{}
+	server := newTestServer(newTestTable("users"), WithLogger(logger))
+	server.serverClient = mock
+
+	err := server.Start()
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.True(t, mock.RegisterExtensionFuncInvoked)
+	assert.Equal(t, []string{fmt.Sprint("level", "warn", "msg", ErrTableCollision.Error(), "table", "users")}, logger.lines())
+}
+
+func TestStartRejectsTableCollisions(t *testing.T) {
+	mock := newCollisionsMock("processes", "users")
+	server := newTestServer(newTestTable("users"), WithRejectTableCollisions())
+	server.serverClient = mock
+
+	err := server.Start()
+	assert.True(t, errors.Is(err, ErrTableCollision))
+	assert.Contains(t, err.Error(), "users")
+	assert.False(t, mock.RegisterExtensionFuncInvoked)
+}
+
+func TestStartSkipsTableCollisionCheck(t *testing.T) {
+	mock := newCollisionsMock("users")
+	server := newTestServer(newTestTable("users"))
+	server.serverClient = mock
+
+	err := server.Start()
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.False(t, mock.QueryFuncInvoked)
+}
@@ -0,0 +1,120 @@
+package osquery
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// WithBaseContext sets the context from which the context of each connection
+// accepted from osquery is derived. The context passed to the plugin calls
+// made over a connection (and to any CallInterceptors) is the connection
+// context, so values in the base context are visible to plugins. The default
+// is context.Background().
+//
+// A connection context is cancelled once the connection is closed and the
+// calls made over it have completed, or when the server is shut down.
+// Cancelling the base context cancels the context of every connection.
+//
+// Once the context of a connection is done, the connection is closed. This
+// aborts the write of a response in progress, so that a response to a peer
+// that stopped reading (eg. a hung osquery) does not block forever.
+func WithBaseContext(ctx context.Context) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.baseContext = ctx
+	}
+}
+
+// WithConnContext sets a function used to modify the context of every
+// connection accepted from osquery, eg. to attach a connection identifier for
+// tracing. The function is called once per connection with a context derived
+// from the base context (see WithBaseContext), before any call is processed
+// on the connection, and must return a context derived from it.
+func WithConnContext(fn func(ctx context.Context) context.Context) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.connContext = fn
+	}
+}
+
+// connProcessorFactory creates a processor per connection, each processing
+// the requests on the connection with a fresh connection context.
+type connProcessorFactory struct {
+	processor   thrift.TProcessor
+	base        context.Context
+	connContext func(context.Context) context.Context
+}
+
+func (f *connProcessorFactory) GetProcessor(trans thrift.TTransport) thrift.TProcessor {
+	ctx, cancel := context.WithCancel(f.base)
+	if f.connContext != nil {
+		ctx = f.connContext(ctx)
+	}
+
+	if sock, ok := trans.(interface{ Conn() net.Conn }); ok && sock.Conn() != nil {
+		state := &connState{conn: sock.Conn()}
+		ctx = context.WithValue(ctx, connStateKey{}, state)
+		go func() {
+			<-ctx.Done()
+			state.close()
+		}()
+	}
+	return &connProcessor{TProcessor: f.processor, ctx: ctx, cancel: cancel}
+}
+
+type connStateKey struct{}
+
+// connState allows closing a connection once its context is done.
+type connState struct {
+	conn net.Conn
+
+	mu       sync.Mutex
+	keepOpen bool
+}
+
+// close closes the connection, unless keepOpen was called. Closing the
+// connection aborts any blocked read or write.
+func (c *connState) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.keepOpen {
+		c.conn.Close()
+	}
+}
+
+// keepOpenOnDone prevents the connection of ctx, if any, from being closed
+// when its context is done. The connection is then closed by the server
+// once it stops processing requests on it.
+func keepOpenOnDone(ctx context.Context) {
+	if c, ok := ctx.Value(connStateKey{}).(*connState); ok {
+		c.mu.Lock()
+		c.keepOpen = true
+		c.mu.Unlock()
+	}
+}
+
+// connProcessor processes the requests on a single connection with the
+// connection context, cancelling it once the connection ends.
+type connProcessor struct {
+	thrift.TProcessor
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Process processes a single request with the connection context. The
+// server stops processing requests on the connection after a transport error
+// (eg. when the connection is closed) or when ok is false, so the connection
+// context is cancelled then.
+func (p *connProcessor) Process(_ context.Context, in, out thrift.TProtocol) (bool, thrift.TException) {
+	ok, err := p.TProcessor.Process(p.ctx, in, out)
+	if p.ctx.Err() != nil {
+		// The connection was closed as its context is done, which is
+		// not worth reporting.
+		return false, nil
+	}
+	if _, isTransportErr := err.(thrift.TTransportException); isTransportErr || !ok {
+		p.cancel()
+	}
+	return ok, err
+}
@@ -0,0 +1,128 @@
+package osquery
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type connKey struct{}
+
+func TestConnContextCancelledOnClose(t *testing.T) {
+	calls := make(chan context.Context, 1)
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		calls <- ctx
+		return nil, nil
+	}
+	server := newTestServer(table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")}, gen))
+
+	base := context.WithValue(context.Background(), connKey{}, "base")
+	factory := &connProcessorFactory{
+		processor: osquery.NewExtensionProcessor(server),
+		base:      base,
+		connContext: func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, connKey{}, ctx.Value(connKey{}).(string)+"/conn")
+		},
+	}
+
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		trans := thrift.NewTSocketFromConnTimeout(serverConn, 0)
+		prot := thrift.NewTBinaryProtocolFactoryDefault().GetProtocol(trans)
+		processor := factory.GetProcessor(trans)
+		for {
+			ok, err := processor.Process(context.Background(), prot, prot)
+			if err != nil || !ok {
+				return
+			}
+		}
+	}()
+
+	client := osquery.NewExtensionClientFactory(
+		thrift.NewTSocketFromConnTimeout(clientConn, 0),
+		thrift.NewTBinaryProtocolFactoryDefault(),
+	)
+	resp, err := client.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+
+	ctx := <-calls
+	assert.Equal(t, "base/conn", ctx.Value(connKey{}))
+	assert.NoError(t, ctx.Err())
+
+	clientConn.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection context not cancelled")
+	}
+}
+
+func TestConnContextAbortsStalledWrite(t *testing.T) {
+	// A response large enough to block writing, as the client never
+	// reads it.
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"foo": strings.Repeat("x", 1<<20)}}, nil
+	}
+	server := newTestServer(table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")}, gen))
+
+	base, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	factory := &connProcessorFactory{
+		processor: osquery.NewExtensionProcessor(server),
+		base:      base,
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	done := make(chan thrift.TException, 1)
+	go func() {
+		trans := thrift.NewTSocketFromConnTimeout(serverConn, 0)
+		prot := thrift.NewTBinaryProtocolFactoryDefault().GetProtocol(trans)
+		processor := factory.GetProcessor(trans)
+		_, err := processor.Process(context.Background(), prot, prot)
+		done <- err
+	}()
+
+	// Send a call without ever reading the response.
+	prot := thrift.NewTBinaryProtocolFactoryDefault().GetProtocol(thrift.NewTSocketFromConnTimeout(clientConn, 0))
+	require.NoError(t, prot.WriteMessageBegin("call", thrift.CALL, 1))
+	args := osquery.ExtensionCallArgs{
+		Registry: "table",
+		Item:     "foo",
+		Request:  osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"},
+	}
+	require.NoError(t, args.Write(prot))
+	require.NoError(t, prot.WriteMessageEnd())
+	require.NoError(t, prot.Flush(context.Background()))
+
+	select {
+	case <-done:
+		t.Fatal("response written to a stalled reader")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("write not aborted when the connection context was cancelled")
+	}
+}
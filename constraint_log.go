@@ -0,0 +1,34 @@
+package osquery
+
+import (
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// WithConstraintLogging sets whether the constraints of every table generate
+// call are logged at the debug level, as parsed from the query context sent
+// by osquery, along with the ID of the call. It helps debugging tables by
+// showing which constraints osquery passes for a query. It is disabled by
+// default.
+func WithConstraintLogging(enabled bool) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.logConstraints = enabled
+	}
+}
+
+func (s *ExtensionManagerServer) logGenerateConstraints(id, registry, item string, request osquery.ExtensionPluginRequest) {
+	if registry != "table" || request["action"] != "generate" {
+		return
+	}
+	s.log("level", "debug", "msg", "generate", "plugin", registry+"/"+item, "constraints", renderConstraints(request), "request_id", id)
+}
+
+// renderConstraints returns the constraints of a table generate request, as
+// rendered by QueryContext.String, or the raw context if it cannot be parsed.
+func renderConstraints(request osquery.ExtensionPluginRequest) string {
+	constraints := request["context"]
+	if queryContext, err := table.ParseQueryContext(constraints); err == nil {
+		constraints = queryContext.String()
+	}
+	return constraints
+}
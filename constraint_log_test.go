@@ -0,0 +1,41 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstraintLogging(t *testing.T) {
+	var id string
+	capture := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+		if request["action"] == "generate" {
+			id, _ = RequestIDFromContext(ctx)
+		}
+		return next(ctx, registry, item, request)
+	}
+	request := osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"foo","list":[{"op":2,"expr":"bar"}],"affinity":"TEXT"}]}`,
+	}
+
+	logger := &testLogger{}
+	server := newTestServer(newTestTable("foo"), WithLogger(logger), WithConstraintLogging(true), WithCallInterceptors(capture))
+	_, err := server.Call(context.Background(), "table", "foo", request)
+	require.NoError(t, err)
+	_, err = server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprint("level", "debug", "msg", "generate", "plugin", "table/foo", "constraints", "foo = 'bar'", "request_id", id),
+	}, logger.lines())
+
+	logger = &testLogger{}
+	server = newTestServer(newTestTable("foo"), WithLogger(logger))
+	_, err = server.Call(context.Background(), "table", "foo", request)
+	require.NoError(t, err)
+	assert.Empty(t, logger.lines())
+}
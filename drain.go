@@ -0,0 +1,66 @@
+package osquery
+
+import (
+	"context"
+	"sync"
+)
+
+// callTracker counts the in-flight plugin calls, so that shutdown can wait for
+// them to complete.
+type callTracker struct {
+	mu     sync.Mutex
+	n      int
+	idle   chan struct{} // closed when n drops to zero, created by wait
+	closed bool
+}
+
+// add records the start of a call. It returns false, without recording the
+// call, once the tracker is closed.
+func (t *callTracker) add() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.n++
+	return true
+}
+
+// close makes add reject the calls started afterwards, so that wait does not
+// return while new calls keep starting.
+func (t *callTracker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+func (t *callTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n--
+	if t.n == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+// wait blocks until there are no in-flight calls or ctx is done.
+func (t *callTracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.n == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.idle == nil {
+		t.idle = make(chan struct{})
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
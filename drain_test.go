@@ -0,0 +1,203 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunShutsDownOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to the current process on windows")
+	}
+
+	tempPath, err := ioutil.TempFile("", "")
+	require.Nil(t, err)
+	defer os.Remove(tempPath.Name())
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := &ExtensionManagerServer{
+		serverClient: mock,
+		sockPath:     tempPath.Name(),
+		pingInterval: time.Second,
+		drainTimeout: time.Second,
+	}
+	WithSignals(syscall.SIGHUP)(server)
+
+	errc := make(chan error)
+	go func() {
+		errc <- server.Run()
+	}()
+	server.waitStarted()
+
+	proc, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, proc.Signal(syscall.SIGHUP))
+
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after signal")
+	}
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+}
+
+// newBlockingServer returns a server with a table that blocks generation until
+// release is closed. A value is sent on started when generation begins.
+func newBlockingServer(started chan<- struct{}, release <-chan struct{}) *ExtensionManagerServer {
+	plugin := table.NewPlugin("slow", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			started <- struct{}{}
+			<-release
+			return nil, nil
+		},
+	)
+	server := newTestServer(plugin)
+	server.serverClient = &MockExtensionManager{
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	return server
+}
+
+func TestShutdownDrainsCalls(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := newBlockingServer(started, release)
+
+	go server.Call(context.Background(), "table", "slow", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	<-started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- server.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned before the in-flight call completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the in-flight call completed")
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	server := newBlockingServer(started, release)
+
+	go server.Call(context.Background(), "table", "slow", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.Is(err, ErrCallsInterrupted))
+}
+
+func TestShutdownIdempotent(t *testing.T) {
+	deregistrations := 0
+	server := newTestServer(newTestTable("foo"))
+	server.serverClient = &MockExtensionManager{
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			deregistrations++
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, 1, deregistrations)
+
+	resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "extension is shutting down", resp.Status.Message)
+}
+
+func TestShutdownConcurrentWithCalls(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		var generating int32
+		var completed, rejected int32
+		plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")},
+			func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+				atomic.AddInt32(&generating, 1)
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&generating, -1)
+				return []map[string]string{{"foo": "bar"}}, nil
+			},
+		)
+		server := newTestServer(plugin)
+		server.serverClient = &MockExtensionManager{
+			DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+				return &osquery.ExtensionStatus{}, nil
+			},
+			CloseFunc: func() error { return nil },
+		}
+
+		var wg sync.WaitGroup
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 10; k++ {
+					resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+					if !assert.NoError(t, err) {
+						return
+					}
+					if resp.Status.Code == 0 {
+						atomic.AddInt32(&completed, 1)
+					} else {
+						assert.Equal(t, "extension is shutting down", resp.Status.Message)
+						atomic.AddInt32(&rejected, 1)
+					}
+				}
+			}()
+		}
+		errs := make(chan error, 3)
+		for j := 0; j < 3; j++ {
+			go func() { errs <- server.Shutdown(context.Background()) }()
+		}
+		for j := 0; j < 3; j++ {
+			assert.NoError(t, <-errs)
+			// Shutdown returns once the in-flight calls completed.
+			assert.Equal(t, int32(0), atomic.LoadInt32(&generating))
+		}
+		wg.Wait()
+		assert.Equal(t, int32(100), completed+rejected)
+	}
+}
@@ -0,0 +1,39 @@
+package osquery
+
+import (
+	"context"
+	"sort"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// DynamicGenerateFunc generates the rows of the table name, for tables
+// registered with RegisterDynamicTables.
+type DynamicGenerateFunc func(ctx context.Context, name string, queryContext table.QueryContext) ([]map[string]string, error)
+
+// RegisterDynamicTables adds the tables in columns, keyed by table name, all
+// generated by gen. It allows an extension to serve many similar tables (eg.
+// one per API resource) from a single handler, which is given the name of the
+// queried table.
+//
+// osquery only routes queries to the tables an extension declares when it
+// registers, along with their columns, so there is no catch-all table: the
+// names and columns must be known before Start is called, and tables
+// registered afterwards are not visible to osquery.
+func (s *ExtensionManagerServer) RegisterDynamicTables(columns map[string][]table.ColumnDefinition, gen DynamicGenerateFunc, opts ...table.TableOpt) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	plugins := make([]OsqueryPlugin, 0, len(names))
+	for _, name := range names {
+		name := name
+		generate := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return gen(ctx, name, queryContext)
+		}
+		plugins = append(plugins, table.NewPlugin(name, columns[name], generate, opts...))
+	}
+	s.RegisterPlugin(plugins...)
+}
@@ -0,0 +1,51 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterDynamicTables(t *testing.T) {
+	server := newTestServer(newTestTable("static"))
+	var queried []string
+	gen := func(ctx context.Context, name string, queryContext table.QueryContext) ([]map[string]string, error) {
+		queried = append(queried, name)
+		if name == "api_broken" {
+			return nil, errors.New("resource unavailable")
+		}
+		return []map[string]string{{"id": name + "-1"}}, nil
+	}
+	server.RegisterDynamicTables(map[string][]table.ColumnDefinition{
+		"api_users":  {table.TextColumn("id"), table.TextColumn("email")},
+		"api_groups": {table.TextColumn("id")},
+		"api_broken": {table.TextColumn("id")},
+	}, gen)
+
+	// Each table is registered with its own columns
+	registry := server.genRegistry()
+	assert.Len(t, registry["table"], 4)
+	assert.Len(t, registry["table"]["api_users"], 2)
+	assert.Len(t, registry["table"]["api_groups"], 1)
+
+	for _, name := range []string{"api_users", "api_groups"} {
+		resp, err := server.Call(context.Background(), "table", name, osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+		require.Equal(t, int32(0), resp.Status.Code)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"id": name + "-1"}}, resp.Response)
+	}
+
+	resp, err := server.Call(context.Background(), "table", "api_broken", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, "error generating table: resource unavailable", resp.Status.Message)
+
+	resp, err = server.Call(context.Background(), "table", "static", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, []string{"api_users", "api_groups", "api_broken"}, queried)
+}
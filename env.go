@@ -0,0 +1,84 @@
+package osquery
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The environment variables read by FromEnv and ClientFromEnv. Durations are
+// either a number of seconds, as for the osquery --timeout and --interval
+// flags passed to autoloaded extensions, or a Go duration (eg. "1500ms").
+const (
+	// EnvSocket is the path to the osquery extensions socket.
+	EnvSocket = "OSQUERY_EXTENSIONS_SOCKET"
+	// EnvTimeout is the timeout for connecting to the socket.
+	EnvTimeout = "OSQUERY_EXTENSIONS_TIMEOUT"
+	// EnvInterval is the interval at which the server pings osquery.
+	EnvInterval = "OSQUERY_EXTENSIONS_INTERVAL"
+)
+
+// FromEnv configures the server from the EnvSocket, EnvTimeout and
+// EnvInterval environment variables, for deployments (eg. in containers)
+// where passing flags is inconvenient. Explicit configuration takes
+// precedence: the environment is only used for the socket path if the path
+// passed to NewExtensionManagerServer is empty, and for the timeout and ping
+// interval if ServerTimeout and ServerPingInterval are not used, regardless
+// of the order of the options. Settings found in neither use the defaults.
+//
+// NewExtensionManagerServer returns an error if a variable is set to an
+// invalid duration.
+func FromEnv() ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.fromEnv = true
+	}
+}
+
+// ClientFromEnv configures the client from the EnvSocket and EnvTimeout
+// environment variables, which are only used if the path or timeout passed
+// to NewClient are empty or zero respectively.
+func ClientFromEnv() ClientOption {
+	return func(c *ExtensionManagerClient) {
+		c.fromEnv = true
+	}
+}
+
+// applyEnv sets the unset server settings from the environment.
+func (s *ExtensionManagerServer) applyEnv() error {
+	if s.sockPath == "" {
+		s.sockPath = os.Getenv(EnvSocket)
+	}
+	if s.timeout == 0 {
+		timeout, err := envDuration(EnvTimeout)
+		if err != nil {
+			return err
+		}
+		s.timeout = timeout
+	}
+	if s.pingInterval == 0 {
+		interval, err := envDuration(EnvInterval)
+		if err != nil {
+			return err
+		}
+		s.pingInterval = interval
+	}
+	return nil
+}
+
+// envDuration returns the duration in the environment variable name, or zero
+// if it is not set.
+func envDuration(name string) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return d, nil
+}
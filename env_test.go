@@ -0,0 +1,73 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromEnv(t *testing.T) {
+	client := WithClient(&MockExtensionManager{})
+
+	// Defaults, with the environment unset
+	server, err := NewExtensionManagerServer("test", "", client, FromEnv())
+	require.NoError(t, err)
+	assert.Equal(t, "", server.sockPath)
+	assert.Equal(t, defaultTimeout, server.timeout)
+	assert.Equal(t, defaultPingInterval, server.pingInterval)
+
+	t.Setenv(EnvSocket, "/var/osquery/env.em")
+	t.Setenv(EnvTimeout, "3")
+	t.Setenv(EnvInterval, "1500ms")
+
+	// The environment is ignored without FromEnv
+	server, err = NewExtensionManagerServer("test", "", client)
+	require.NoError(t, err)
+	assert.Equal(t, "", server.sockPath)
+	assert.Equal(t, defaultTimeout, server.timeout)
+	assert.Equal(t, defaultPingInterval, server.pingInterval)
+
+	server, err = NewExtensionManagerServer("test", "", client, FromEnv())
+	require.NoError(t, err)
+	assert.Equal(t, "/var/osquery/env.em", server.sockPath)
+	assert.Equal(t, 3*time.Second, server.timeout)
+	assert.Equal(t, 1500*time.Millisecond, server.pingInterval)
+
+	// Explicit configuration takes precedence, whatever the option order
+	server, err = NewExtensionManagerServer("test", "/var/osquery/explicit.em", client,
+		ServerTimeout(time.Minute), FromEnv(), ServerPingInterval(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "/var/osquery/explicit.em", server.sockPath)
+	assert.Equal(t, time.Minute, server.timeout)
+	assert.Equal(t, time.Hour, server.pingInterval)
+
+	t.Setenv(EnvInterval, "often")
+	_, err = NewExtensionManagerServer("test", "", client, FromEnv())
+	assert.EqualError(t, err, `parsing OSQUERY_EXTENSIONS_INTERVAL: time: invalid duration "often"`)
+}
+
+func TestClientFromEnv(t *testing.T) {
+	manager := &mock.ExtensionManager{
+		PingFunc: func(ctx context.Context) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+		},
+	}
+	t.Setenv(EnvSocket, serveManager(t, manager))
+	t.Setenv(EnvTimeout, "5s")
+
+	client, err := NewClient("", 0, ClientFromEnv())
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Equal(t, 5*time.Second, client.pool.timeout)
+	_, err = client.Ping()
+	assert.NoError(t, err)
+
+	t.Setenv(EnvTimeout, "soon")
+	_, err = NewClient("", 0, ClientFromEnv())
+	assert.EqualError(t, err, `parsing OSQUERY_EXTENSIONS_TIMEOUT: time: invalid duration "soon"`)
+}
@@ -0,0 +1,105 @@
+package osquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/osquery/osquery-go/transport"
+)
+
+// The following errors may be matched with errors.Is against the errors
+// returned by the client and server APIs. The returned errors wrap the
+// underlying cause, which can also be inspected with errors.Is and
+// errors.As.
+var (
+	// ErrSocketUnavailable indicates that the osquery extension socket
+	// did not become available within the timeout.
+	ErrSocketUnavailable = transport.ErrSocketUnavailable
+	// ErrSocketPathTooLong indicates that the socket path, or the path
+	// of the extension socket derived from it, is too long for a unix
+	// domain socket on the platform.
+	ErrSocketPathTooLong = transport.ErrSocketPathTooLong
+	// ErrRegistrationFailed indicates that osquery did not accept the
+	// extension registration.
+	ErrRegistrationFailed = errors.New("registering extension")
+	// ErrDeregistrationFailed indicates that osquery did not accept the
+	// extension deregistration.
+	ErrDeregistrationFailed = errors.New("deregistering extension")
+	// ErrPingFailed indicates that the osquery instance did not respond
+	// successfully to a health check.
+	ErrPingFailed = errors.New("extension ping failed")
+	// ErrCallsInterrupted indicates that the server shut down before
+	// the in-flight plugin calls completed.
+	ErrCallsInterrupted = errors.New("in-flight calls interrupted")
+	// ErrNotReady indicates that the readiness check set with
+	// WithReadinessCheck did not pass before the readiness timeout.
+	ErrNotReady = errors.New("extension not ready")
+	// ErrClosed is returned by the methods of an ExtensionManagerClient
+	// called after the client is closed.
+	ErrClosed = errors.New("client is closed")
+	// ErrNotRegistered is returned by ExtensionManagerClient.Deregister
+	// when no extension was registered through the client.
+	ErrNotRegistered = errors.New("extension is not registered")
+	// ErrPluginNotFound is returned by
+	// ExtensionManagerClient.CallRegisteredPlugin when the plugin called
+	// is not in the osquery registry.
+	ErrPluginNotFound = errors.New("plugin not found")
+
+	// ErrQueryFailed indicates that osquery returned an error status for
+	// a query.
+	ErrQueryFailed = errors.New("query returned error")
+)
+
+// OsqueryError is returned when osquery (or the plugin it routed a call to)
+// responds with a non-OK status. Use errors.As to access the status. Errors
+// for failed queries also match ErrQueryFailed.
+type OsqueryError struct {
+	// Code is the status code returned by osquery.
+	Code int
+	// Message is the status message returned by osquery.
+	Message string
+	// Query is the SQL of the failed query, if the error is for a query.
+	Query string
+	// Registry, Item and Action identify the failed call, if the error is
+	// for a call.
+	Registry string
+	Item     string
+	Action   string
+}
+
+func (e *OsqueryError) Error() string {
+	if e.Query != "" {
+		return fmt.Sprintf("query returned error: %s (status %d, query %q)", e.Message, e.Code, e.Query)
+	}
+	return fmt.Sprintf("call to %s/%s returned error: %s (status %d, action %q)", e.Registry, e.Item, e.Message, e.Code, e.Action)
+}
+
+// Is allows errors for failed queries to match ErrQueryFailed.
+func (e *OsqueryError) Is(target error) bool {
+	return target == ErrQueryFailed && e.Query != ""
+}
+
+// sentinelError pairs a sentinel error with the underlying cause so that
+// errors.Is matches both.
+type sentinelError struct {
+	sentinel error
+	cause    error
+}
+
+// wrapSentinel returns an error matching sentinel that wraps cause, with the
+// message "<sentinel>: <cause>".
+func wrapSentinel(sentinel, cause error) error {
+	return &sentinelError{sentinel: sentinel, cause: cause}
+}
+
+func (e *sentinelError) Error() string {
+	return e.sentinel.Error() + ": " + e.cause.Error()
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.cause
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return target == e.sentinel
+}
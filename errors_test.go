@@ -0,0 +1,106 @@
+package osquery
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentinelErrorWrapping(t *testing.T) {
+	cause := errors.New("boom!")
+	err := wrapSentinel(ErrRegistrationFailed, cause)
+
+	assert.Equal(t, "registering extension: boom!", err.Error())
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrPingFailed))
+	assert.Equal(t, cause, errors.Unwrap(err))
+}
+
+func TestRegistrationStatusError(t *testing.T) {
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 1, Message: "duplicate extension"}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := &ExtensionManagerServer{serverClient: mock}
+
+	err := server.Start()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.Contains(t, err.Error(), "duplicate extension")
+}
+
+func TestNewClientSocketUnavailable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osquery-go-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "missing.em")
+
+	_, err = NewClient(path, 10*time.Millisecond)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSocketUnavailable))
+}
+
+func TestSocketPathTooLong(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes do not have the unix socket path limit")
+	}
+	path := "/tmp/" + strings.Repeat("d", 200) + "/osquery.em"
+
+	_, err := NewClient(path, 10*time.Millisecond)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSocketPathTooLong))
+	assert.Contains(t, err.Error(), "use a shorter path")
+
+	// The limit leaves room for the NUL terminator of sun_path: a path at
+	// the limit can be bound, and a byte more is rejected.
+	if max := map[string]int{"linux": 107, "darwin": 103}[runtime.GOOS]; max > 0 {
+		dir, err := ioutil.TempDir("/tmp", "osq")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		atLimit := dir + "/" + strings.Repeat("s", max-len(dir)-1)
+		require.Len(t, atLimit, max)
+
+		listener, err := net.Listen("unix", atLimit)
+		require.NoError(t, err)
+		client, err := NewClient(atLimit, 5*time.Second)
+		require.NoError(t, err)
+		client.Close()
+		listener.Close()
+
+		_, err = NewClient(atLimit+"s", 10*time.Millisecond)
+		assert.True(t, errors.Is(err, ErrSocketPathTooLong))
+	}
+
+	// The extension socket is named after the osquery socket, so it may
+	// be too long while the osquery socket is not.
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: 12345}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := &ExtensionManagerServer{serverClient: mock, sockPath: "/tmp/" + strings.Repeat("d", 97) + "/os.em"}
+	err = server.Start()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSocketPathTooLong))
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+}
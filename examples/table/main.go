@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/osquery/osquery-go"
@@ -14,10 +16,23 @@ var (
 	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
 	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
 	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+	selftest = flag.Bool("selftest", false, "Exercise the table plugins and exit without connecting to osquery")
 )
 
 func main() {
 	flag.Parse()
+
+	plugin := table.NewPlugin("example_table", ExampleColumns(), ExampleGenerate)
+
+	if *selftest {
+		report, err := osquery.SelfTest(context.Background(), plugin)
+		fmt.Print(report)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *socket == "" {
 		log.Fatalln("Missing required --socket argument")
 	}
@@ -38,7 +53,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Error creating extension: %s\n", err)
 	}
-	server.RegisterPlugin(table.NewPlugin("example_table", ExampleColumns(), ExampleGenerate))
+	server.RegisterPlugin(plugin)
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
 	}
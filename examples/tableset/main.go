@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+var (
+	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
+	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
+	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+)
+
+func main() {
+	flag.Parse()
+	if *socket == "" {
+		log.Fatalln("Missing required --socket argument")
+	}
+	serverTimeout := osquery.ServerTimeout(
+		time.Second * time.Duration(*timeout),
+	)
+	serverPingInterval := osquery.ServerPingInterval(
+		time.Second * time.Duration(*interval),
+	)
+
+	server, err := osquery.NewExtensionManagerServer(
+		"example_tableset",
+		*socket,
+		serverTimeout,
+		serverPingInterval,
+	)
+	if err != nil {
+		log.Fatalf("Error creating extension: %s\n", err)
+	}
+
+	// All three tables share a single client, which is closed once when
+	// the extension shuts down.
+	client := newInventoryClient()
+	tables := table.NewSet(client)
+	tables.Add("inventory_hosts", []table.ColumnDefinition{
+		table.TextColumn("hostname"),
+		table.TextColumn("platform"),
+	}, client.generateHosts)
+	tables.Add("inventory_users", []table.ColumnDefinition{
+		table.TextColumn("username"),
+		table.TextColumn("hostname"),
+	}, client.generateUsers)
+	tables.Add("inventory_software", []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("version"),
+		table.TextColumn("hostname"),
+	}, client.generateSoftware)
+
+	for _, plugin := range tables.Plugins() {
+		server.RegisterPlugin(plugin)
+	}
+	if err := server.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inventoryClient stands in for a client of an inventory service, which would
+// typically hold a connection and credentials shared by all of the tables.
+type inventoryClient struct{}
+
+func newInventoryClient() *inventoryClient {
+	log.Println("inventory client: connected")
+	return &inventoryClient{}
+}
+
+func (c *inventoryClient) Close() error {
+	log.Println("inventory client: closed")
+	return nil
+}
+
+func (c *inventoryClient) generateHosts(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	return []map[string]string{
+		{"hostname": "web01", "platform": "linux"},
+		{"hostname": "laptop42", "platform": "darwin"},
+	}, nil
+}
+
+func (c *inventoryClient) generateUsers(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	return []map[string]string{
+		{"username": "deploy", "hostname": "web01"},
+		{"username": "alice", "hostname": "laptop42"},
+	}, nil
+}
+
+func (c *inventoryClient) generateSoftware(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	return []map[string]string{
+		{"name": "nginx", "version": "1.18.0", "hostname": "web01"},
+		{"name": "osquery", "version": "4.5.1", "hostname": "laptop42"},
+	}, nil
+}
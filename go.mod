@@ -4,7 +4,6 @@ require (
 	github.com/Microsoft/go-winio v0.4.9
 	github.com/apache/thrift v0.13.1-0.20200603211036-eac4d0c79a5f
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pkg/errors v0.8.0
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/testify v1.2.2
 	golang.org/x/sys v0.0.0-20210603125802-9665404d3644 // indirect
@@ -0,0 +1,109 @@
+//go:build integration
+// +build integration
+
+package osquery_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs a real osqueryd autoloading the table example, and
+// queries the table through the extension socket. It is only built with the
+// integration tag, and skipped unless osqueryd is found in the OSQUERYD
+// environment variable or the PATH:
+//
+//	OSQUERYD=/path/to/osqueryd go test -tags integration -run Integration -v .
+//
+// scripts/integration.sh downloads osqueryd if needed and runs it.
+func TestIntegration(t *testing.T) {
+	osqueryd := os.Getenv("OSQUERYD")
+	if osqueryd == "" {
+		var err error
+		if osqueryd, err = exec.LookPath("osqueryd"); err != nil {
+			t.Skip("osqueryd not found, set OSQUERYD to its path")
+		}
+	}
+
+	// A short directory, as the extension socket path is limited to about
+	// a hundred bytes.
+	dir, err := ioutil.TempDir("", "osq")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// osqueryd only autoloads extensions with the .ext suffix.
+	extension := filepath.Join(dir, "example_table.ext")
+	build := exec.Command(filepath.Join(runtime.GOROOT(), "bin", "go"), "build", "-o", extension, "./examples/table")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	autoload := filepath.Join(dir, "extensions.load")
+	require.NoError(t, ioutil.WriteFile(autoload, []byte(extension+"\n"), 0644))
+	config := filepath.Join(dir, "osquery.conf")
+	require.NoError(t, ioutil.WriteFile(config, []byte("{}"), 0644))
+
+	socket := filepath.Join(dir, "osquery.em")
+	cmd := exec.Command(osqueryd,
+		"--ephemeral",
+		"--disable_database",
+		"--disable_logging",
+		"--disable_events",
+		"--disable_watchdog",
+		"--config_path="+config,
+		"--database_path="+filepath.Join(dir, "osquery.db"),
+		"--pidfile="+filepath.Join(dir, "osquery.pid"),
+		"--extensions_socket="+socket,
+		"--extensions_autoload="+autoload,
+		"--extensions_timeout=10",
+		"--extensions_interval=1",
+		// The extension is owned by the user running the test, which
+		// osqueryd otherwise refuses when running as root.
+		"--allow_unsafe",
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	require.NoError(t, cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	client, err := osquery.NewClient(socket, 30*time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The table is only queryable once osqueryd started the extension
+	// and the extension registered.
+	var rows []map[string]string
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(100 * time.Millisecond) {
+		rows, err = client.QueryRows("SELECT * FROM example_table")
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("querying the extension table: %v", err)
+		}
+	}
+	assert.Equal(t, []map[string]string{{
+		"text":    "hello world",
+		"integer": "123",
+		"big_int": "-1234567890",
+		"double":  "3.14159",
+	}}, rows)
+
+	row, err := client.QueryRow("SELECT count(*) AS n FROM example_table WHERE integer = 123")
+	require.NoError(t, err)
+	assert.Equal(t, "1", row["n"])
+
+	row, err = client.QueryRow("SELECT name FROM osquery_extensions WHERE name = 'example_extension'")
+	require.NoError(t, err)
+	assert.Equal(t, "example_extension", row["name"])
+}
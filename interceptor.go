@@ -0,0 +1,38 @@
+package osquery
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// CallHandler handles a call routed to a registered plugin.
+type CallHandler func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse
+
+// CallInterceptor intercepts the calls routed to registered plugins. An
+// interceptor may inspect or modify the request, populate the context (eg.
+// with WithMetadata) and inspect or modify the response. It must call next to
+// continue routing the call to the plugin, or return a response of its own to
+// short-circuit the call.
+type CallInterceptor func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse
+
+// WithCallInterceptors adds interceptors to the calls routed to registered
+// plugins. Interceptors are run in the order they are provided, with the first
+// interceptor being the outermost.
+func WithCallInterceptors(interceptors ...CallInterceptor) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.interceptors = append(s.interceptors, interceptors...)
+	}
+}
+
+// chainInterceptors returns a CallHandler running the interceptors in order
+// before handler.
+func chainInterceptors(interceptors []CallInterceptor, handler CallHandler) CallHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+			return interceptor(ctx, registry, item, request, next)
+		}
+	}
+	return handler
+}
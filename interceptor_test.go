@@ -0,0 +1,95 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(plugin OsqueryPlugin, opts ...ServerOption) *ExtensionManagerServer {
+	registry := make(map[string](map[string]OsqueryPlugin))
+	for reg := range validRegistryNames {
+		registry[reg] = make(map[string]OsqueryPlugin)
+	}
+	server := &ExtensionManagerServer{registry: registry}
+	for _, opt := range opts {
+		opt(server)
+	}
+	server.RegisterPlugin(plugin)
+	return server
+}
+
+func TestCallInterceptorOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) CallInterceptor {
+		return func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+			calls = append(calls, name+" "+registry+"/"+item)
+			return next(ctx, registry, item, request)
+		}
+	}
+
+	plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("bar")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			calls = append(calls, "generate")
+			return []map[string]string{{"bar": "baz"}}, nil
+		},
+	)
+	server := newTestServer(plugin, WithCallInterceptors(record("first"), record("second")))
+
+	resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, []string{"first table/foo", "second table/foo", "generate"}, calls)
+}
+
+func TestCallInterceptorShortCircuit(t *testing.T) {
+	deny := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+		return osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: "denied"}}
+	}
+
+	plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("bar")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			t.Fatal("generate should not be called")
+			return nil, nil
+		},
+	)
+	server := newTestServer(plugin, WithCallInterceptors(deny))
+
+	resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, &osquery.ExtensionStatus{Code: 1, Message: "denied"}, resp.Status)
+}
+
+func TestMetadataVisibleInGenerate(t *testing.T) {
+	identify := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+		return next(WithMetadata(ctx, Metadata{"caller": "alice", "trace": "1"}), registry, item, request)
+	}
+	override := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+		return next(WithMetadata(ctx, Metadata{"trace": "2"}), registry, item, request)
+	}
+
+	var got Metadata
+	plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("bar")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			var ok bool
+			got, ok = MetadataFromContext(ctx)
+			assert.True(t, ok)
+			return nil, nil
+		},
+	)
+	server := newTestServer(plugin, WithCallInterceptors(identify, override))
+
+	_, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{"caller": "alice", "trace": "2"}, got)
+}
+
+func TestMetadataFromContextEmpty(t *testing.T) {
+	md, ok := MetadataFromContext(context.Background())
+	assert.False(t, ok)
+	assert.Nil(t, md)
+}
@@ -0,0 +1,141 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// PluginFactory creates a plugin registered with RegisterLazy.
+type PluginFactory func() (OsqueryPlugin, error)
+
+// RegisterLazy adds a table to this extension manager whose plugin is created
+// by factory on the first call to the table, rather than at startup. This
+// avoids initializing the backends of tables that are never queried.
+//
+// osquery requires the columns of the table when the extension registers, so
+// they must be provided upfront. The plugin returned by factory must be a
+// table named name, and should have the same columns. The plugin is cached
+// once created. If factory returns an error, the call returns an error status
+// and factory is called again on the next call.
+func (s *ExtensionManagerServer) RegisterLazy(name string, columns []table.ColumnDefinition, factory PluginFactory) {
+	s.RegisterPlugin(&lazyPlugin{
+		name:    name,
+		routes:  table.NewPlugin(name, columns, nil).Routes(),
+		factory: factory,
+	})
+}
+
+// lazyPlugin is a table plugin created on first use.
+type lazyPlugin struct {
+	name    string
+	routes  osquery.ExtensionPluginResponse
+	factory PluginFactory
+
+	// mu is held while creating the plugin, so that concurrent calls
+	// wait for a single initialization.
+	mu sync.Mutex
+
+	// stateMu guards plugin and shutdown. Unlike mu, it is not held while
+	// the factory runs, so that pings and shutdown do not wait for a slow
+	// factory.
+	stateMu  sync.Mutex
+	plugin   OsqueryPlugin
+	shutdown bool
+}
+
+func (l *lazyPlugin) Name() string {
+	return l.name
+}
+
+func (l *lazyPlugin) RegistryName() string {
+	return "table"
+}
+
+func (l *lazyPlugin) Routes() osquery.ExtensionPluginResponse {
+	return l.routes
+}
+
+// Ping reports OK until the plugin is created, as pings must not trigger the
+// initialization.
+func (l *lazyPlugin) Ping() osquery.ExtensionStatus {
+	if plugin := l.initialized(); plugin != nil {
+		return plugin.Ping()
+	}
+	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
+}
+
+func (l *lazyPlugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	plugin, err := l.get()
+	if err != nil {
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "initializing table: " + err.Error(),
+			},
+		}
+	}
+	return plugin.Call(ctx, request)
+}
+
+// Shutdown shuts the plugin down if it was created. A plugin created by a
+// factory still running is shut down once the factory returns, rather than
+// being used.
+func (l *lazyPlugin) Shutdown() {
+	l.stateMu.Lock()
+	l.shutdown = true
+	plugin := l.plugin
+	l.stateMu.Unlock()
+	if plugin != nil {
+		plugin.Shutdown()
+	}
+}
+
+// initialized returns the plugin, or nil if it was not yet created. It does
+// not wait for a factory in progress.
+func (l *lazyPlugin) initialized() OsqueryPlugin {
+	l.stateMu.Lock()
+	defer l.stateMu.Unlock()
+	return l.plugin
+}
+
+// get returns the plugin, creating it if needed.
+func (l *lazyPlugin) get() (OsqueryPlugin, error) {
+	if plugin := l.initialized(); plugin != nil {
+		return plugin, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stateMu.Lock()
+	plugin, shutdown := l.plugin, l.shutdown
+	l.stateMu.Unlock()
+	if plugin != nil {
+		return plugin, nil
+	}
+	if shutdown {
+		return nil, errors.New("table is shut down")
+	}
+
+	plugin, err := l.factory()
+	if err != nil {
+		return nil, err
+	}
+	if plugin.RegistryName() != "table" || plugin.Name() != l.name {
+		return nil, fmt.Errorf("factory returned %s plugin %q, expected table %q", plugin.RegistryName(), plugin.Name(), l.name)
+	}
+
+	l.stateMu.Lock()
+	if l.shutdown {
+		l.stateMu.Unlock()
+		plugin.Shutdown()
+		return nil, errors.New("table is shut down")
+	}
+	l.plugin = plugin
+	l.stateMu.Unlock()
+	return plugin, nil
+}
@@ -0,0 +1,141 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterLazy(t *testing.T) {
+	columns := []table.ColumnDefinition{table.TextColumn("foo")}
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"foo": "bar"}}, nil
+	}
+
+	var created int
+	failing := true
+	server := newTestServer(newTestTable("other"))
+	server.RegisterLazy("lazy", columns, func() (OsqueryPlugin, error) {
+		created++
+		if failing {
+			return nil, errors.New("backend unavailable")
+		}
+		return table.NewPlugin("lazy", columns, gen), nil
+	})
+
+	// Registration and pings do not create the plugin.
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "foo", "type": "TEXT", "op": "0"},
+	}, server.genRegistry()["table"]["lazy"])
+	ping, err := server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), ping.Code)
+	assert.Equal(t, 0, created)
+
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+
+	// Factory errors are returned, and retried on the next call.
+	resp, err := server.Call(context.Background(), "table", "lazy", request)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "initializing table: backend unavailable", resp.Status.Message)
+	assert.Equal(t, 1, created)
+
+	failing = false
+	for i := 0; i < 3; i++ {
+		resp, err = server.Call(context.Background(), "table", "lazy", request)
+		require.NoError(t, err)
+		require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"foo": "bar"}}, resp.Response)
+	}
+	assert.Equal(t, 2, created)
+}
+
+func TestRegisterLazyWrongPlugin(t *testing.T) {
+	server := newTestServer(newTestTable("other"))
+	server.RegisterLazy("lazy", []table.ColumnDefinition{table.TextColumn("foo")}, func() (OsqueryPlugin, error) {
+		return newTestTable("other"), nil
+	})
+
+	resp, err := server.Call(context.Background(), "table", "lazy", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Contains(t, resp.Status.Message, `expected table "lazy"`)
+}
+
+func TestRegisterLazyPingDuringFactory(t *testing.T) {
+	columns := []table.ColumnDefinition{table.TextColumn("foo")}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := newTestServer(newTestTable("other"))
+	server.RegisterLazy("lazy", columns, func() (OsqueryPlugin, error) {
+		close(started)
+		<-release
+		return newTestTable("lazy"), nil
+	})
+
+	called := make(chan struct{})
+	go func() {
+		defer close(called)
+		server.Call(context.Background(), "table", "lazy", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	}()
+	<-started
+
+	// Pings and shutdown of the plugin do not wait for the factory.
+	pinged := make(chan struct{})
+	go func() {
+		defer close(pinged)
+		ping, err := server.Ping(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), ping.Code)
+		server.registry["table"]["lazy"].Shutdown()
+	}()
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("ping blocked by the factory")
+	}
+
+	close(release)
+	<-called
+}
+
+func TestRegisterLazyShutdownDuringFactory(t *testing.T) {
+	columns := []table.ColumnDefinition{table.TextColumn("foo")}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	created := &shutdownRecorder{Plugin: newTestTable("lazy")}
+	server := newTestServer(newTestTable("other"))
+	server.RegisterLazy("lazy", columns, func() (OsqueryPlugin, error) {
+		close(started)
+		<-release
+		return created, nil
+	})
+
+	called := make(chan *osquery.ExtensionResponse)
+	go func() {
+		resp, _ := server.Call(context.Background(), "table", "lazy", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		called <- resp
+	}()
+	<-started
+	lazy := server.registry["table"]["lazy"]
+	lazy.Shutdown()
+	close(release)
+
+	// The plugin created after the shutdown is shut down, not used.
+	resp := <-called
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "initializing table: table is shut down", resp.Status.Message)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created.shutdown))
+
+	resp, err := server.Call(context.Background(), "table", "lazy", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, "initializing table: table is shut down", resp.Status.Message)
+}
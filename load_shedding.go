@@ -0,0 +1,104 @@
+package osquery
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// defaultLoadSheddingWindow is the decay window used by WithLoadShedding when
+// none is provided.
+const defaultLoadSheddingWindow = time.Minute
+
+// WithLoadShedding limits the data returned by the extension on resource
+// constrained hosts. The sizes of the responses returned by plugins are
+// accounted with exponential decay, so that each response counts for about a third
+// of its size after window, and is nearly forgotten after a few windows.
+// While the accounted bytes exceed maxBytes, generate calls to tables are
+// rejected with StatusOverloaded without calling the tables, until the
+// accounting decays back below maxBytes.
+//
+// A maxBytes of zero or less disables load shedding, which is the default. A
+// window of zero or less defaults to one minute.
+func WithLoadShedding(maxBytes int64, window time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		if maxBytes <= 0 {
+			s.responseBudget = nil
+			return
+		}
+		if window <= 0 {
+			window = defaultLoadSheddingWindow
+		}
+		s.responseBudget = &responseBudget{max: float64(maxBytes), window: window, now: time.Now}
+	}
+}
+
+// responseBudget accounts the bytes returned by plugins, decaying over time.
+type responseBudget struct {
+	max    float64
+	window time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	bytes    float64
+	updated  time.Time
+	shedding bool
+}
+
+// decay applies the decay since the last update. Callers must hold mu.
+func (b *responseBudget) decay(now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 && !b.updated.IsZero() {
+		b.bytes *= math.Exp(-float64(elapsed) / float64(b.window))
+	}
+	b.updated = now
+}
+
+// add accounts a response of n bytes.
+func (b *responseBudget) add(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decay(b.now())
+	b.bytes += float64(n)
+}
+
+// check returns whether calls should be shed, whether that changed since the
+// previous check, and the accounted bytes.
+func (b *responseBudget) check() (shed, changed bool, bytes float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.decay(b.now())
+	shed = b.bytes > b.max
+	changed = shed != b.shedding
+	b.shedding = shed
+	return shed, changed, b.bytes
+}
+
+// shedLoad returns an overloaded response if the call must be rejected by the
+// load shedding.
+func (s *ExtensionManagerServer) shedLoad(id, registry, item string, request osquery.ExtensionPluginRequest) (osquery.ExtensionResponse, bool) {
+	if registry != "table" || request["action"] != "generate" {
+		return osquery.ExtensionResponse{}, false
+	}
+	shed, changed, bytes := s.responseBudget.check()
+	if changed && shed {
+		s.log("level", "warn", "msg", "shedding generate calls", "bytes", int64(bytes), "max", int64(s.responseBudget.max), "request_id", id)
+	} else if changed {
+		s.log("level", "info", "msg", "stopped shedding generate calls", "bytes", int64(bytes), "max", int64(s.responseBudget.max))
+	}
+	if !shed {
+		return osquery.ExtensionResponse{}, false
+	}
+
+	response := osquery.ExtensionResponse{
+		Status: &osquery.ExtensionStatus{
+			Code:    StatusOverloaded,
+			Message: "extension overloaded: too much data returned recently",
+		},
+	}
+	if s.metrics != nil {
+		s.recordCall(id, registry, item, request, response, 0)
+	}
+	return response, true
+}
@@ -0,0 +1,70 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadShedding(t *testing.T) {
+	var generated int
+	plugin := table.NewPlugin("big", []table.ColumnDefinition{table.TextColumn("data")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			generated++
+			// 1023 bytes once serialized.
+			return []map[string]string{{"data": strings.Repeat("a", 1000)}}, nil
+		},
+	)
+	logger := &testLogger{}
+	server := newTestServer(plugin, WithLoadShedding(1500, time.Minute), WithLogger(logger))
+	now := time.Unix(1600000000, 0)
+	server.responseBudget.now = func() time.Time { return now }
+
+	generate := func() *osquery.ExtensionResponse {
+		resp, err := server.Call(context.Background(), "table", "big", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Below the threshold.
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, 2, generated)
+
+	// 2046 bytes were returned, so calls are shed without generating.
+	resp := generate()
+	assert.Equal(t, StatusOverloaded, resp.Status.Code)
+	assert.Equal(t, "extension overloaded: too much data returned recently", resp.Status.Message)
+	assert.Equal(t, StatusOverloaded, generate().Status.Code)
+	assert.Equal(t, 2, generated)
+
+	// Other actions are not shed, but their responses are accounted.
+	resp, err := server.Call(context.Background(), "table", "big", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+
+	// After a window, the accounting decayed to about a third.
+	now = now.Add(time.Minute)
+	assert.Equal(t, int32(0), generate().Status.Code)
+	assert.Equal(t, 3, generated)
+
+	lines := logger.lines()
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], fmt.Sprint("level", "warn", "msg", "shedding generate calls", "bytes", 2046, "max", 1500)), lines[0])
+	assert.Equal(t, fmt.Sprint("level", "info", "msg", "stopped shedding generate calls", "bytes", 778, "max", 1500), lines[1])
+}
+
+func TestLoadSheddingDisabled(t *testing.T) {
+	server := newTestServer(newTestTable("t"), WithLoadShedding(1500, time.Minute), WithLoadShedding(0, 0))
+	assert.Nil(t, server.responseBudget)
+
+	server = newTestServer(newTestTable("t"), WithLoadShedding(1500, 0))
+	assert.Equal(t, defaultLoadSheddingWindow, server.responseBudget.window)
+}
@@ -0,0 +1,74 @@
+package osquery
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// Logger is used by the library to log events that are not otherwise
+// surfaced to the caller, such as warnings found during registration. It is
+// compatible with the go-kit log.Logger interface. Log is called with
+// alternating keys and values, including a "level" key (eg. "warn") and a
+// "msg" key.
+type Logger interface {
+	Log(keyvals ...interface{}) error
+}
+
+// WithLogger sets the Logger used by the server. By default nothing is
+// logged.
+func WithLogger(logger Logger) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.logger = logger
+	}
+}
+
+type loggerKey struct{}
+
+// LoggerFromContext returns a Logger for the plugin call, when ctx is the
+// context of a call served by an ExtensionManagerServer with a logger set by
+// WithLogger. The lines logged are tagged with the plugin ("plugin" key, eg.
+// "table/processes") and the ID of the call ("request_id" key), appended to
+// the keyvals, so that they can be correlated with the lines logged by the
+// server. Otherwise, the returned Logger discards everything.
+func LoggerFromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return logger
+	}
+	return nopLogger{}
+}
+
+func withLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// callLogger tags the lines logged during a plugin call.
+type callLogger struct {
+	logger    Logger
+	plugin    string
+	requestID string
+}
+
+func (l callLogger) Log(keyvals ...interface{}) error {
+	tagged := make([]interface{}, 0, len(keyvals)+4)
+	tagged = append(tagged, keyvals...)
+	return l.logger.Log(append(tagged, "plugin", l.plugin, "request_id", l.requestID)...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(keyvals ...interface{}) error { return nil }
+
+// log logs keyvals with the configured logger, if any.
+func (s *ExtensionManagerServer) log(keyvals ...interface{}) {
+	if s.logger != nil {
+		s.logger.Log(keyvals...)
+	}
+}
+
+// isUnknownAction returns true if response is the error status returned by
+// the plugins in this library for an action they do not handle.
+func isUnknownAction(request osquery.ExtensionPluginRequest, response osquery.ExtensionResponse) bool {
+	return response.Status != nil && response.Status.Code != 0 &&
+		response.Status.Message == "unknown action: "+request["action"]
+}
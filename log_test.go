@@ -0,0 +1,48 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	var id string
+	plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			id, _ = RequestIDFromContext(ctx)
+			LoggerFromContext(ctx).Log("level", "info", "msg", "generating", "rows", 0)
+			return nil, nil
+		},
+	)
+	logger := &testLogger{}
+	server := newTestServer(plugin, WithLogger(logger))
+
+	_, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		fmt.Sprint("level", "info", "msg", "generating", "rows", 0, "plugin", "table/foo", "request_id", id),
+	}, logger.lines())
+}
+
+func TestLoggerFromContextNoLogger(t *testing.T) {
+	assert.NoError(t, LoggerFromContext(context.Background()).Log("level", "info", "msg", "discarded"))
+
+	called := false
+	plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			called = true
+			assert.NoError(t, LoggerFromContext(ctx).Log("level", "info", "msg", "discarded"))
+			return nil, nil
+		},
+	)
+	server := newTestServer(plugin)
+	_, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.True(t, called)
+}
@@ -0,0 +1,98 @@
+package osquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/thrift/lib/go/thrift"
+)
+
+// ErrMessageTooLarge indicates that a message read from the socket exceeded
+// the configured maximum size. The connection is closed, as the remainder of
+// the message is not read.
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+// WithMaxMessageSize sets the maximum size in bytes of the messages the server
+// reads, both for requests from osquery and for responses to the calls the
+// server makes to osquery. A connection sending a larger message is closed. By
+// default the size is not limited.
+func WithMaxMessageSize(size int64) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.maxMessageSize = size
+	}
+}
+
+// WithMaxResponseSize sets the maximum size in bytes of the responses the
+// client reads from osquery. A call receiving a larger response fails with an
+// error mentioning ErrMessageTooLarge, and the connection is closed. By
+// default the size is not limited.
+func WithMaxResponseSize(size int64) ClientOption {
+	return func(c *ExtensionManagerClient) {
+		c.maxResponseSize = size
+	}
+}
+
+// protocolFactory returns the factory for the binary protocol used to
+// communicate with osquery, limiting the size of the messages read if max is
+// positive. osquery serves and dials extension sockets with the Thrift binary
+// protocol only, and Thrift has no protocol negotiation, so other protocols
+// (eg. compact) cannot be used with osquery.
+func protocolFactory(max int64) thrift.TProtocolFactory {
+	if max > 0 {
+		return limitedProtocolFactory{max: max}
+	}
+	return thrift.NewTBinaryProtocolFactoryDefault()
+}
+
+type limitedProtocolFactory struct {
+	max int64
+}
+
+func (f limitedProtocolFactory) GetProtocol(trans thrift.TTransport) thrift.TProtocol {
+	limited := &limitedTransport{TTransport: trans, max: f.max}
+	return &limitedProtocol{
+		TProtocol: thrift.NewTBinaryProtocolTransport(limited),
+		trans:     limited,
+	}
+}
+
+// limitedProtocol resets the count of bytes read at the start of each
+// message.
+type limitedProtocol struct {
+	thrift.TProtocol
+	trans *limitedTransport
+}
+
+func (p *limitedProtocol) ReadMessageBegin() (string, thrift.TMessageType, int32, error) {
+	p.trans.n = 0
+	return p.TProtocol.ReadMessageBegin()
+}
+
+// limitedTransport fails reads once max bytes have been read since the count
+// was last reset. Reads are capped so that no bytes beyond the limit are
+// consumed.
+type limitedTransport struct {
+	thrift.TTransport
+	max int64
+	n   int64
+}
+
+func (t *limitedTransport) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	remaining := t.max - t.n
+	if remaining <= 0 {
+		return 0, t.tooLarge()
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := t.TTransport.Read(p)
+	t.n += int64(n)
+	return n, err
+}
+
+func (t *limitedTransport) tooLarge() error {
+	return fmt.Errorf("%w: limit is %d bytes", ErrMessageTooLarge, t.max)
+}
@@ -0,0 +1,131 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeStringMessage writes a message containing a single string of size
+// bytes, returning the total size of the message.
+func writeStringMessage(t *testing.T, buf *thrift.TMemoryBuffer, size int) int {
+	start := buf.Len()
+	prot := thrift.NewTBinaryProtocolTransport(buf)
+	require.NoError(t, prot.WriteMessageBegin("test", thrift.CALL, 1))
+	require.NoError(t, prot.WriteString(strings.Repeat("a", size)))
+	require.NoError(t, prot.WriteMessageEnd())
+	return buf.Len() - start
+}
+
+func readStringMessage(prot thrift.TProtocol) (string, error) {
+	if _, _, _, err := prot.ReadMessageBegin(); err != nil {
+		return "", err
+	}
+	return prot.ReadString()
+}
+
+func TestLimitedProtocol(t *testing.T) {
+	// Find the size of a message so that the limit can be set to exactly
+	// fit it.
+	size := writeStringMessage(t, thrift.NewTMemoryBuffer(), 100000)
+
+	buf := thrift.NewTMemoryBuffer()
+	writeStringMessage(t, buf, 100000)
+	writeStringMessage(t, buf, 100000)
+	prot := protocolFactory(int64(size)).GetProtocol(buf)
+
+	// Messages at the limit can be read, and the count is reset for each
+	// message.
+	for i := 0; i < 2; i++ {
+		s, err := readStringMessage(prot)
+		require.NoError(t, err)
+		assert.Len(t, s, 100000)
+	}
+
+	buf = thrift.NewTMemoryBuffer()
+	writeStringMessage(t, buf, 100001)
+	prot = protocolFactory(int64(size)).GetProtocol(buf)
+	_, err := readStringMessage(prot)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrMessageTooLarge.Error())
+	// No bytes beyond the limit are consumed
+	assert.Equal(t, 1, buf.Len())
+}
+
+func TestClientMaxResponseSize(t *testing.T) {
+	manager := &mock.ExtensionManager{
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: []map[string]string{{"data": strings.Repeat("a", len(sql))}},
+			}, nil
+		},
+	}
+	path := serveManager(t, manager)
+
+	client, err := NewClient(path, 5*time.Second, WithMaxResponseSize(1024))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.QueryRows(strings.Repeat("a", 500))
+	assert.NoError(t, err)
+
+	_, err = client.QueryRows(strings.Repeat("a", 2000))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrMessageTooLarge.Error())
+
+	// The broken connection is discarded
+	_, err = client.QueryRows(strings.Repeat("a", 10))
+	assert.NoError(t, err)
+}
+
+func TestServerMaxMessageSize(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.Nil(t, err)
+	defer os.Remove(tempPath.Name())
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, UUID: 0}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := newTestServer(newTestTable("foo"), WithMaxMessageSize(1024))
+	server.serverClient = mock
+	server.sockPath = tempPath.Name()
+	go server.Start()
+	server.waitStarted()
+	defer server.Shutdown(context.Background())
+
+	addr, err := net.ResolveUnixAddr("unix", fmt.Sprintf("%s.%d", tempPath.Name(), 0))
+	require.Nil(t, err)
+	trans := thrift.NewTSocketFromAddrTimeout(addr, 5*time.Second, 5*time.Second)
+	require.NoError(t, trans.Open())
+	defer trans.Close()
+	client := osquery.NewExtensionClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault())
+
+	resp, err := client.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+
+	// The server closes the connection when the request is too large
+	_, err = client.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{
+		"action": "columns",
+		"data":   strings.Repeat("a", 2000),
+	})
+	assert.Error(t, err)
+}
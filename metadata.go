@@ -0,0 +1,33 @@
+package osquery
+
+import "context"
+
+// Metadata carries request scoped values from CallInterceptors to plugins (eg.
+// a resolved caller identity or a tracing baggage item). It is distinct from
+// the osquery query context, which describes the query constraints.
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// WithMetadata returns a copy of ctx carrying md. Any metadata already in ctx
+// is preserved, with the values in md taking precedence, so that several
+// interceptors may each add their own values.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := Metadata{}
+	if parent, ok := MetadataFromContext(ctx); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// MetadataFromContext returns the Metadata carried by ctx, if any. The returned
+// map must not be modified; use WithMetadata to add values.
+func MetadataFromContext(ctx context.Context) (Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
@@ -0,0 +1,61 @@
+package osquery
+
+import (
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// CallMetrics contains the details recorded for a single plugin call handled
+// by the ExtensionManagerServer.
+type CallMetrics struct {
+	// Registry is the registry name of the called plugin.
+	Registry string
+	// Item is the name of the called plugin.
+	Item string
+	// Action is the "action" value from the request, if any.
+	Action string
+	// RequestID is the ID of the call (see RequestIDFromContext).
+	RequestID string
+	// Duration is the time spent in the plugin Call.
+	Duration time.Duration
+	// StatusCode is the status code returned by the plugin.
+	StatusCode int32
+	// Rows is the number of rows in the response.
+	Rows int
+	// Bytes is the approximate size of the response once serialized for
+	// osquery.
+	Bytes int
+}
+
+// MetricsRecorder receives metrics for the plugin calls handled by the
+// ExtensionManagerServer. RecordCall is invoked synchronously after each
+// call, so implementations should be fast and must be safe for concurrent
+// use.
+type MetricsRecorder interface {
+	RecordCall(CallMetrics)
+}
+
+// WithMetricsRecorder sets the MetricsRecorder that receives metrics for each
+// plugin call.
+func WithMetricsRecorder(recorder MetricsRecorder) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.metrics = recorder
+	}
+}
+
+// responseSize approximates the size of the thrift binary encoding of the
+// rows in a response. Each row is encoded as a map header followed by the
+// length-prefixed keys and values.
+func responseSize(response osquery.ExtensionPluginResponse) int {
+	// list header: element type + size
+	size := 5
+	for _, row := range response {
+		// map header: key type + value type + size
+		size += 6
+		for k, v := range row {
+			size += 4 + len(k) + 4 + len(v)
+		}
+	}
+	return size
+}
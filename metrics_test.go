@@ -0,0 +1,65 @@
+package osquery
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockMetricsRecorder struct {
+	mu    sync.Mutex
+	calls []CallMetrics
+}
+
+func (m *mockMetricsRecorder) RecordCall(c CallMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, c)
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	rows := []map[string]string{
+		{"text": "hello"},
+		{"text": "world"},
+		{"text": "!"},
+	}
+	plugin := table.NewPlugin("metrics", []table.ColumnDefinition{table.TextColumn("text")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return rows, nil
+		},
+	)
+
+	recorder := &mockMetricsRecorder{}
+	server := &ExtensionManagerServer{
+		registry: map[string](map[string]OsqueryPlugin){"table": {}},
+		metrics:  recorder,
+	}
+	server.RegisterPlugin(plugin)
+
+	resp, err := server.Call(context.Background(), "table", "metrics", osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": "{}",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code)
+
+	require.Len(t, recorder.calls, 1)
+	m := recorder.calls[0]
+	assert.Equal(t, "table", m.Registry)
+	assert.Equal(t, "metrics", m.Item)
+	assert.Equal(t, "generate", m.Action)
+	assert.Equal(t, int32(0), m.StatusCode)
+	assert.Equal(t, len(rows), m.Rows)
+	assert.Equal(t, responseSize(rows), m.Bytes)
+	assert.True(t, m.Bytes > 0)
+}
+
+func TestResponseSize(t *testing.T) {
+	assert.Equal(t, 5, responseSize(nil))
+	assert.Equal(t, 5+6+4+1+4+2, responseSize(osquery.ExtensionPluginResponse{{"a": "bc"}}))
+}
@@ -6,7 +6,7 @@ import "github.com/osquery/osquery-go/gen/osquery"
 
 var _ ExtensionManager = (*MockExtensionManager)(nil)
 
-type CloseFunc func()
+type CloseFunc func() error
 
 type PingFunc func() (*osquery.ExtensionStatus, error)
 
@@ -53,9 +53,9 @@ type MockExtensionManager struct {
 	GetQueryColumnsFuncInvoked bool
 }
 
-func (m *MockExtensionManager) Close() {
+func (m *MockExtensionManager) Close() error {
 	m.CloseFuncInvoked = true
-	m.CloseFunc()
+	return m.CloseFunc()
 }
 
 func (m *MockExtensionManager) Ping() (*osquery.ExtensionStatus, error) {
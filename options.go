@@ -0,0 +1,133 @@
+package osquery
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// OsqueryOptions contains the runtime options (flags) of the osquery instance,
+// as returned by ExtensionManager.Options. The commonly used options are
+// available as typed fields, and all options with Get.
+//
+// The typed fields are left at their zero value when osquery does not report
+// the option or its value cannot be parsed. Most options are reported by every
+// osquery version, but plugin specific options (eg. logger_path, for the
+// filesystem logger) may be missing when the plugin is not compiled in.
+type OsqueryOptions struct {
+	// ConfigPlugin is the active config plugin (config_plugin).
+	ConfigPlugin string
+	// LoggerPlugin is the active logger plugins, comma separated
+	// (logger_plugin).
+	LoggerPlugin string
+	// DatabasePath is the path of the osquery database (database_path).
+	DatabasePath string
+	// ExtensionsSocket is the path of the extensions socket
+	// (extensions_socket).
+	ExtensionsSocket string
+	// LoggerPath is the directory of the filesystem logger logs
+	// (logger_path).
+	LoggerPath string
+	// ConfigRefresh is the interval at which the config is refreshed
+	// (config_refresh).
+	ConfigRefresh time.Duration
+	// DistributedInterval is the interval at which distributed queries are
+	// checked for (distributed_interval).
+	DistributedInterval time.Duration
+	// DisableDistributed is true if distributed queries are disabled
+	// (disable_distributed).
+	DisableDistributed bool
+	// DisableEvents is true if the event publishers are disabled
+	// (disable_events).
+	DisableEvents bool
+	// Verbose is true if verbose logging is enabled (verbose).
+	Verbose bool
+
+	raw osquery.InternalOptionList
+}
+
+// NewOsqueryOptions parses the options returned by ExtensionManager.Options.
+func NewOsqueryOptions(raw osquery.InternalOptionList) *OsqueryOptions {
+	o := &OsqueryOptions{raw: raw}
+	o.ConfigPlugin, _ = o.Get("config_plugin")
+	o.LoggerPlugin, _ = o.Get("logger_plugin")
+	o.DatabasePath, _ = o.Get("database_path")
+	o.ExtensionsSocket, _ = o.Get("extensions_socket")
+	o.LoggerPath, _ = o.Get("logger_path")
+	o.ConfigRefresh = o.seconds("config_refresh")
+	o.DistributedInterval = o.seconds("distributed_interval")
+	o.DisableDistributed = o.bool("disable_distributed")
+	o.DisableEvents = o.bool("disable_events")
+	o.Verbose = o.bool("verbose")
+	return o
+}
+
+// Get returns the value of the named option, and whether osquery reported it.
+func (o *OsqueryOptions) Get(name string) (string, bool) {
+	opt, ok := o.raw[name]
+	if !ok || opt == nil {
+		return "", false
+	}
+	return opt.Value, true
+}
+
+func (o *OsqueryOptions) seconds(name string) time.Duration {
+	value, _ := o.Get(name)
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+func (o *OsqueryOptions) bool(name string) bool {
+	value, _ := o.Get(name)
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// OptionsCache caches the options of osquery, refreshing them from osquery
+// once they are older than the refresh interval.
+type OptionsCache struct {
+	client  ExtensionManager
+	refresh time.Duration
+
+	// now returns the current time. It is a field to allow tests to
+	// replace it.
+	now func() time.Time
+
+	mu        sync.Mutex
+	options   *OsqueryOptions
+	fetchedAt time.Time
+}
+
+// NewOptionsCache creates an OptionsCache fetching the options with client,
+// and refreshing them when they are older than refresh. A refresh of zero
+// fetches the options once.
+func NewOptionsCache(client ExtensionManager, refresh time.Duration) *OptionsCache {
+	return &OptionsCache{client: client, refresh: refresh, now: time.Now}
+}
+
+// Options returns the options of osquery, fetching them if they were never
+// fetched or are due for a refresh. If refreshing fails, the error is
+// returned along with the previously fetched options, if any.
+func (c *OptionsCache) Options() (*OsqueryOptions, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.options != nil && (c.refresh == 0 || now.Sub(c.fetchedAt) < c.refresh) {
+		return c.options, nil
+	}
+
+	raw, err := c.client.Options()
+	if err != nil {
+		return c.options, fmt.Errorf("fetching osquery options: %w", err)
+	}
+	c.options = NewOsqueryOptions(raw)
+	c.fetchedAt = now
+	return c.options, nil
+}
@@ -0,0 +1,83 @@
+package osquery
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryOptions(t *testing.T) {
+	options := NewOsqueryOptions(osquery.InternalOptionList{
+		"config_plugin":        {Value: "tls", DefaultValue: "filesystem", Type: "string"},
+		"logger_plugin":        {Value: "filesystem,tls", DefaultValue: "filesystem", Type: "string"},
+		"config_refresh":       {Value: "300", DefaultValue: "0", Type: "uint32"},
+		"distributed_interval": {Value: "60", DefaultValue: "60", Type: "uint32"},
+		"disable_events":       {Value: "true", DefaultValue: "false", Type: "bool"},
+		"verbose":              {Value: "false", DefaultValue: "false", Type: "bool"},
+		"database_path":        {Value: "/var/osquery/osquery.db", Type: "string"},
+		"custom_flag":          {Value: "foo", Type: "string"},
+	})
+
+	assert.Equal(t, "tls", options.ConfigPlugin)
+	assert.Equal(t, "filesystem,tls", options.LoggerPlugin)
+	assert.Equal(t, 5*time.Minute, options.ConfigRefresh)
+	assert.Equal(t, time.Minute, options.DistributedInterval)
+	assert.True(t, options.DisableEvents)
+	assert.False(t, options.Verbose)
+	assert.Equal(t, "/var/osquery/osquery.db", options.DatabasePath)
+	assert.Empty(t, options.LoggerPath)
+
+	value, ok := options.Get("custom_flag")
+	assert.True(t, ok)
+	assert.Equal(t, "foo", value)
+	_, ok = options.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestOptionsCacheRefresh(t *testing.T) {
+	var fetches int
+	var fail bool
+	mock := &MockExtensionManager{
+		OptionsFunc: func() (osquery.InternalOptionList, error) {
+			if fail {
+				return nil, errors.New("boom")
+			}
+			fetches++
+			return osquery.InternalOptionList{
+				"config_refresh": {Value: strconv.Itoa(fetches)},
+			}, nil
+		},
+	}
+	now := time.Unix(0, 0)
+	cache := NewOptionsCache(mock, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	// Fetched on first use, then cached.
+	options, err := cache.Options()
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, options.ConfigRefresh)
+	now = now.Add(30 * time.Second)
+	options, err = cache.Options()
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, options.ConfigRefresh)
+	assert.Equal(t, 1, fetches)
+
+	// Refreshed once older than the interval.
+	now = now.Add(30 * time.Second)
+	options, err = cache.Options()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, options.ConfigRefresh)
+	assert.Equal(t, 2, fetches)
+
+	// A failed refresh returns the previous options.
+	fail = true
+	now = now.Add(time.Minute)
+	options, err = cache.Options()
+	assert.Error(t, err)
+	assert.Equal(t, 2*time.Second, options.ConfigRefresh)
+}
@@ -0,0 +1,121 @@
+package osquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// OsqueryClient is the part of the osquery extension manager API used by
+// most programs embedding osquery-go. Unlike ExtensionManager, it uses no
+// types from the Thrift generated package, so that the generated code
+// remains an implementation detail. ExtensionManagerClient implements it;
+// code accepting an OsqueryClient can be tested with a fake implementation.
+type OsqueryClient interface {
+	// QueryRows runs the query and returns the resulting rows.
+	QueryRows(sql string) ([]map[string]string, error)
+	// QueryColumns returns the columns of the results of the query, in
+	// order, without running it.
+	QueryColumns(sql string) ([]table.ColumnSchema, error)
+	// CallPlugin calls the plugin of the registry, either in osquery or in
+	// another extension, and returns the response rows.
+	CallPlugin(registry, item string, request map[string]string) ([]map[string]string, error)
+	// HealthCheck pings osquery, returning an error if it does not respond
+	// successfully.
+	HealthCheck() error
+	// RegisterPlugins registers an extension with the plugins, returning
+	// the UUID assigned by osquery. The plugins must be served by the
+	// extension, as osquery calls them on the socket of the extension.
+	RegisterPlugins(name, version string, plugins ...OsqueryPlugin) (int64, error)
+	// OsqueryOptions returns the options osquery was started with.
+	OsqueryOptions() (*OsqueryOptions, error)
+}
+
+var _ OsqueryClient = (*ExtensionManagerClient)(nil)
+
+// QueryColumns returns the columns of the results of the query, in order,
+// without running it. Errors returned by osquery are returned as an
+// *OsqueryError.
+func (c *ExtensionManagerClient) QueryColumns(sql string) ([]table.ColumnSchema, error) {
+	res, err := c.GetQueryColumns(sql)
+	if err != nil {
+		return nil, fmt.Errorf("transport error in query columns: %w", err)
+	}
+	if res.Status == nil {
+		return nil, errors.New("query columns returned nil status")
+	}
+	if res.Status.Code != 0 {
+		return nil, &OsqueryError{
+			Code:    int(res.Status.Code),
+			Message: res.Status.Message,
+			Query:   sql,
+		}
+	}
+
+	// osquery returns a row per column, mapping its name to its type.
+	columns := make([]table.ColumnSchema, 0, len(res.Response))
+	for _, row := range res.Response {
+		for name, typ := range row {
+			columns = append(columns, table.ColumnSchema{Name: name, Type: table.ColumnType(typ)})
+		}
+	}
+	return columns, nil
+}
+
+// CallPlugin is CallExtension with the request as a plain map.
+func (c *ExtensionManagerClient) CallPlugin(registry, item string, request map[string]string) ([]map[string]string, error) {
+	return c.CallExtension(registry, item, request)
+}
+
+// HealthCheck pings osquery. Errors, including a non-zero status, are
+// returned wrapping ErrPingFailed.
+func (c *ExtensionManagerClient) HealthCheck() error {
+	status, err := c.Ping()
+	if err != nil {
+		return wrapSentinel(ErrPingFailed, err)
+	}
+	if status == nil {
+		return wrapSentinel(ErrPingFailed, errors.New("nil status"))
+	}
+	if status.Code != 0 {
+		return wrapSentinel(ErrPingFailed, fmt.Errorf("status %d: %s", status.Code, status.Message))
+	}
+	return nil
+}
+
+// RegisterPlugins registers an extension with the plugins using
+// RegisterExtension, and returns the UUID assigned by osquery. Errors,
+// including a non-zero status, are returned wrapping ErrRegistrationFailed.
+func (c *ExtensionManagerClient) RegisterPlugins(name, version string, plugins ...OsqueryPlugin) (int64, error) {
+	registry := osquery.ExtensionRegistry{}
+	for _, plugin := range plugins {
+		if registry[plugin.RegistryName()] == nil {
+			registry[plugin.RegistryName()] = osquery.ExtensionRouteTable{}
+		}
+		registry[plugin.RegistryName()][plugin.Name()] = plugin.Routes()
+	}
+
+	status, err := c.RegisterExtension(&osquery.InternalExtensionInfo{Name: name, Version: version}, registry)
+	if err != nil {
+		return 0, wrapSentinel(ErrRegistrationFailed, err)
+	}
+	if status == nil {
+		return 0, wrapSentinel(ErrRegistrationFailed, errors.New("nil status"))
+	}
+	if status.Code != 0 {
+		return 0, wrapSentinel(ErrRegistrationFailed, fmt.Errorf("status %d: %s", status.Code, status.Message))
+	}
+	return int64(status.UUID), nil
+}
+
+// OsqueryOptions requests the options osquery was started with, parsed with
+// NewOsqueryOptions.
+func (c *ExtensionManagerClient) OsqueryOptions() (*OsqueryOptions, error) {
+	raw, err := c.Options()
+	if err != nil {
+		return nil, fmt.Errorf("transport error in options: %w", err)
+	}
+	return NewOsqueryOptions(raw), nil
+}
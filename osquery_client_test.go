@@ -0,0 +1,176 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOsqueryClient implements OsqueryClient without a connection to
+// osquery.
+type fakeOsqueryClient struct {
+	tables map[string][]map[string]string
+}
+
+func (f *fakeOsqueryClient) QueryRows(sql string) ([]map[string]string, error) {
+	return f.CallPlugin("table", sql, map[string]string{"action": "generate"})
+}
+
+func (f *fakeOsqueryClient) QueryColumns(sql string) ([]table.ColumnSchema, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeOsqueryClient) CallPlugin(registry, item string, request map[string]string) ([]map[string]string, error) {
+	rows, ok := f.tables[item]
+	if registry != "table" || !ok {
+		return nil, &OsqueryError{Code: 1, Message: "unknown plugin", Registry: registry, Item: item}
+	}
+	return rows, nil
+}
+
+func (f *fakeOsqueryClient) HealthCheck() error { return nil }
+
+func (f *fakeOsqueryClient) RegisterPlugins(name, version string, plugins ...OsqueryPlugin) (int64, error) {
+	return 1, nil
+}
+
+func (f *fakeOsqueryClient) OsqueryOptions() (*OsqueryOptions, error) {
+	return NewOsqueryOptions(nil), nil
+}
+
+// countUsers is an example of code depending only on OsqueryClient.
+func countUsers(client OsqueryClient) (int, error) {
+	if err := client.HealthCheck(); err != nil {
+		return 0, err
+	}
+	rows, err := client.QueryRows("users")
+	if err != nil {
+		return 0, fmt.Errorf("querying users: %w", err)
+	}
+	return len(rows), nil
+}
+
+func TestOsqueryClientFake(t *testing.T) {
+	client := &fakeOsqueryClient{tables: map[string][]map[string]string{
+		"users": {{"username": "alice"}, {"username": "bob"}},
+	}}
+	count, err := countUsers(client)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	client.tables = nil
+	_, err = countUsers(client)
+	var osqueryErr *OsqueryError
+	require.True(t, errors.As(err, &osqueryErr))
+	assert.Equal(t, "users", osqueryErr.Item)
+}
+
+func TestQueryColumns(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+
+	mock.GetQueryColumnsFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0},
+			Response: []map[string]string{{"pid": "BIGINT"}, {"name": "TEXT"}},
+		}, nil
+	}
+	columns, err := client.QueryColumns("select pid, name from processes")
+	require.NoError(t, err)
+	assert.Equal(t, []table.ColumnSchema{
+		{Name: "pid", Type: table.ColumnTypeBigInt},
+		{Name: "name", Type: table.ColumnTypeText},
+	}, columns)
+
+	mock.GetQueryColumnsFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table: foo"},
+		}, nil
+	}
+	_, err = client.QueryColumns("select * from foo")
+	var osqueryErr *OsqueryError
+	require.True(t, errors.As(err, &osqueryErr))
+	assert.Equal(t, "select * from foo", osqueryErr.Query)
+
+	mock.GetQueryColumnsFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return nil, errors.New("boom!")
+	}
+	_, err = client.QueryColumns("select 1")
+	assert.Error(t, err)
+}
+
+func TestHealthCheck(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+
+	mock.PingFunc = func(ctx context.Context) (*osquery.ExtensionStatus, error) {
+		return &osquery.ExtensionStatus{Code: 0}, nil
+	}
+	assert.NoError(t, client.HealthCheck())
+
+	mock.PingFunc = func(ctx context.Context) (*osquery.ExtensionStatus, error) {
+		return &osquery.ExtensionStatus{Code: 1, Message: "unhealthy"}, nil
+	}
+	err := client.HealthCheck()
+	assert.True(t, errors.Is(err, ErrPingFailed))
+	assert.Contains(t, err.Error(), "unhealthy")
+
+	mock.PingFunc = func(ctx context.Context) (*osquery.ExtensionStatus, error) {
+		return nil, errors.New("boom!")
+	}
+	assert.True(t, errors.Is(client.HealthCheck(), ErrPingFailed))
+}
+
+func TestRegisterPlugins(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+
+	var info *osquery.InternalExtensionInfo
+	var registry osquery.ExtensionRegistry
+	mock.RegisterExtensionFunc = func(ctx context.Context, i *osquery.InternalExtensionInfo, r osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+		info, registry = i, r
+		return &osquery.ExtensionStatus{Code: 0, UUID: 42}, nil
+	}
+	plugin := newTestTable("foo")
+	uuid, err := client.RegisterPlugins("test", "1.0.0", plugin)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), uuid)
+	assert.Equal(t, &osquery.InternalExtensionInfo{Name: "test", Version: "1.0.0"}, info)
+	assert.Equal(t, osquery.ExtensionRegistry{
+		"table": {"foo": plugin.Routes()},
+	}, registry)
+
+	mock.RegisterExtensionFunc = func(ctx context.Context, i *osquery.InternalExtensionInfo, r osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+		return &osquery.ExtensionStatus{Code: 1, Message: "duplicate name"}, nil
+	}
+	_, err = client.RegisterPlugins("test", "1.0.0", plugin)
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.Contains(t, err.Error(), "duplicate name")
+}
+
+func TestOsqueryOptionsClient(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+
+	mock.OptionsFunc = func(ctx context.Context) (osquery.InternalOptionList, error) {
+		return osquery.InternalOptionList{
+			"config_plugin": &osquery.InternalOptionInfo{Value: "tls"},
+		}, nil
+	}
+	options, err := client.OsqueryOptions()
+	require.NoError(t, err)
+	assert.Equal(t, "tls", options.ConfigPlugin)
+
+	mock.OptionsFunc = func(ctx context.Context) (osquery.InternalOptionList, error) {
+		return nil, errors.New("boom!")
+	}
+	_, err = client.OsqueryOptions()
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package osquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// OsqueryLogger is a Logger that sends each log line to osquery as a status
+// log, so that it is handled by osquery's logger plugin alongside osquery's own
+// status logs.
+//
+// Errors sending the log are returned from Log, and must not be logged to the
+// same OsqueryLogger, as logging the failure would recurse. Similarly, an
+// extension serving the logger plugin that osquery is configured with must not
+// log from that plugin to an OsqueryLogger, as osquery would route every log
+// line back to the extension.
+type OsqueryLogger struct {
+	client         ExtensionManager
+	plugin         string
+	hostIdentifier string
+}
+
+// NewOsqueryLogger creates a Logger that sends status logs using client to the
+// named osquery logger plugin. The plugin is typically the value of osquery's
+// --logger_plugin flag (eg. "filesystem"), which can be found in the result of
+// client.Options().
+//
+// Status logs carry the host identifier, like those of osquery. It defaults
+// to the hostname, as does osquery's --host_identifier flag, and can be
+// changed with SetHostIdentifier.
+func NewOsqueryLogger(client ExtensionManager, plugin string) *OsqueryLogger {
+	hostname, _ := os.Hostname()
+	return &OsqueryLogger{client: client, plugin: plugin, hostIdentifier: hostname}
+}
+
+// SetHostIdentifier sets the host identifier of the status logs, eg. to match
+// the identifier osquery uses when --host_identifier is not "hostname". It
+// must be called before the logger is used.
+func (l *OsqueryLogger) SetHostIdentifier(id string) {
+	l.hostIdentifier = id
+}
+
+// statusLogLine is the JSON representation of a status log used by osquery.
+type statusLogLine struct {
+	Severity       int    `json:"s"`
+	Filename       string `json:"f"`
+	Line           int    `json:"i"`
+	Message        string `json:"m"`
+	HostIdentifier string `json:"h"`
+	CalendarTime   string `json:"c"`
+	UnixTime       int64  `json:"u"`
+}
+
+// Severities of osquery status logs.
+const (
+	severityInfo    = 0
+	severityWarning = 1
+	severityError   = 2
+)
+
+// Log formats keyvals as a logfmt style message and sends it to osquery. The
+// "level" key is used to set the severity of the status log.
+func (l *OsqueryLogger) Log(keyvals ...interface{}) error {
+	severity := severityInfo
+	var parts []string
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		var value interface{} = "(MISSING)"
+		if i+1 < len(keyvals) {
+			value = keyvals[i+1]
+		}
+		if key == "level" {
+			switch fmt.Sprint(value) {
+			case "warn", "warning":
+				severity = severityWarning
+			case "error":
+				severity = severityError
+			}
+			continue
+		}
+		parts = append(parts, key+"="+formatLogValue(value))
+	}
+
+	now := time.Now().UTC()
+	logJSON, err := json.Marshal([]statusLogLine{{
+		Severity:       severity,
+		Filename:       "osquery-go",
+		Message:        strings.Join(parts, " "),
+		HostIdentifier: l.hostIdentifier,
+		CalendarTime:   now.Format(time.UnixDate),
+		UnixTime:       now.Unix(),
+	}})
+	if err != nil {
+		return fmt.Errorf("marshaling status log: %w", err)
+	}
+
+	resp, err := l.client.Call("logger", l.plugin, map[string]string{
+		"status": "true",
+		"log":    string(logJSON),
+	})
+	if err != nil {
+		return fmt.Errorf("sending status log: %w", err)
+	}
+	if resp.Status != nil && resp.Status.Code != 0 {
+		return fmt.Errorf("sending status log: %s", resp.Status.Message)
+	}
+	return nil
+}
+
+// formatLogValue formats v, quoting it if it contains spaces or quotes.
+func formatLogValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if strings.ContainsAny(s, " \"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
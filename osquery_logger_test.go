@@ -0,0 +1,82 @@
+package osquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryLogger(t *testing.T) {
+	var registry, item string
+	var request osquery.ExtensionPluginRequest
+	mock := &MockExtensionManager{
+		CallFunc: func(reg string, it string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+			registry, item, request = reg, it, req
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}, nil
+		},
+	}
+	log := NewOsqueryLogger(mock, "filesystem")
+
+	err := log.Log("level", "warn", "msg", "table name collides", "table", "users")
+	require.NoError(t, err)
+	assert.Equal(t, "logger", registry)
+	assert.Equal(t, "filesystem", item)
+	assert.Equal(t, "true", request["status"])
+
+	var lines []map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(request["log"]), &lines))
+	require.Len(t, lines, 1)
+	assert.Equal(t, float64(severityWarning), lines[0]["s"])
+	assert.Equal(t, `msg="table name collides" table=users`, lines[0]["m"])
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	assert.Equal(t, hostname, lines[0]["h"])
+	assert.ElementsMatch(t, []string{"s", "f", "i", "m", "h", "c", "u"}, keys(lines[0]))
+
+	// The status log can be parsed by a logger plugin
+	var logged []string
+	plugin := logger.NewPlugin("test", func(ctx context.Context, typ logger.LogType, log string) error {
+		assert.Equal(t, logger.LogTypeStatus, typ)
+		logged = append(logged, log)
+		return nil
+	})
+	resp := plugin.Call(context.Background(), request)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Len(t, logged, 1)
+
+	log.SetHostIdentifier("host-uuid")
+	require.NoError(t, log.Log("msg", "hello"))
+	require.NoError(t, json.Unmarshal([]byte(request["log"]), &lines))
+	assert.Equal(t, "host-uuid", lines[0]["h"])
+}
+
+func keys(m map[string]interface{}) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestOsqueryLoggerErrors(t *testing.T) {
+	mock := &MockExtensionManager{
+		CallFunc: func(registry string, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+			return nil, errors.New("boom!")
+		},
+	}
+	err := NewOsqueryLogger(mock, "filesystem").Log("msg", "hello")
+	assert.EqualError(t, err, "sending status log: boom!")
+
+	mock.CallFunc = func(registry string, item string, req osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 1, Message: "unknown logger"}}, nil
+	}
+	err = NewOsqueryLogger(mock, "missing").Log("msg", "hello")
+	assert.EqualError(t, err, "sending status log: unknown logger")
+}
@@ -0,0 +1,70 @@
+package osquery
+
+import (
+	"context"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// WithOsqueryTimeout sets the time osquery waits for the extension to respond
+// to a call (eg. on the osquery side, the timeout of its extension client),
+// so that the extension responds before osquery gives up on it and restarts
+// it. Calls are given a deadline of timeout minus margin, or of the call
+// timeout (see WithCallTimeout) if that is shorter. If a plugin has not
+// returned by the deadline, the call responds with an error status without
+// waiting for the plugin, which keeps running in the background until it
+// returns.
+//
+// The margin should allow for the time taken to send the response. A margin
+// that is not less than timeout is ignored.
+func WithOsqueryTimeout(timeout, margin time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.osqueryTimeout = timeout
+		s.osqueryTimeoutMargin = margin
+	}
+}
+
+// osqueryDeadline returns the time within which calls must respond, or zero
+// if the osquery timeout is not set.
+func osqueryDeadline(timeout, margin time.Duration) time.Duration {
+	if margin <= 0 || margin >= timeout {
+		return timeout
+	}
+	return timeout - margin
+}
+
+// callBeforeDeadline runs call, returning a StatusDeadlineExceeded (or
+// StatusCanceled) status if ctx is done before call returns. The call is
+// tracked as in-flight until it returns. If the server started shutting down
+// since the call was accepted, call is not run, as the shutdown would not
+// wait for it.
+func (s *ExtensionManagerServer) callBeforeDeadline(ctx context.Context, call func(context.Context) osquery.ExtensionResponse) osquery.ExtensionResponse {
+	if !s.calls.add() {
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 1, Message: "extension is shutting down"},
+		}
+	}
+	done := make(chan osquery.ExtensionResponse, 1)
+	go func() {
+		defer s.calls.done()
+		done <- call(ctx)
+	}()
+
+	select {
+	case response := <-done:
+		return response
+	case <-ctx.Done():
+		code := contextErrorCode(ctx.Err())
+		message := "call did not complete before the deadline: "
+		if code == StatusCanceled {
+			message = "call canceled: "
+		}
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    code,
+				Message: message + ctx.Err().Error(),
+			},
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package osquery
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryDeadline(t *testing.T) {
+	var testCases = []struct {
+		timeout, margin, expected time.Duration
+	}{
+		{3 * time.Second, 500 * time.Millisecond, 2500 * time.Millisecond},
+		{3 * time.Second, 0, 3 * time.Second},
+		// Margins not less than the timeout are ignored
+		{3 * time.Second, 3 * time.Second, 3 * time.Second},
+		{3 * time.Second, 5 * time.Second, 3 * time.Second},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, osqueryDeadline(tt.timeout, tt.margin))
+	}
+}
+
+func TestOsqueryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	var deadline time.Time
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		defer close(returned)
+		deadline, _ = ctx.Deadline()
+		// Ignore ctx, as a plugin stuck in a blocking call would
+		<-release
+		return nil, nil
+	}
+	plugin := table.NewPlugin("stuck", []table.ColumnDefinition{table.TextColumn("foo")}, gen)
+	server := newTestServer(plugin, WithOsqueryTimeout(200*time.Millisecond, 150*time.Millisecond))
+
+	start := time.Now()
+	resp, err := server.Call(context.Background(), "table", "stuck", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	elapsed := time.Since(start)
+	assert.Equal(t, StatusDeadlineExceeded, resp.Status.Code)
+	assert.Equal(t, "call did not complete before the deadline: context deadline exceeded", resp.Status.Message)
+	assert.True(t, elapsed >= 50*time.Millisecond)
+	assert.True(t, elapsed < 200*time.Millisecond, "responded after %s", elapsed)
+
+	// The plugin is still tracked as in-flight until it returns
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, server.calls.wait(ctx))
+	close(release)
+	<-returned
+	assert.NoError(t, server.calls.wait(context.Background()))
+	assert.WithinDuration(t, start.Add(50*time.Millisecond), deadline, 20*time.Millisecond)
+}
+
+func TestOsqueryTimeoutShorterCallTimeout(t *testing.T) {
+	var remaining time.Duration
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		// Runs in another goroutine, so require must not be used
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		remaining = time.Until(deadline)
+		return []map[string]string{{"foo": "bar"}}, nil
+	}
+	plugin := table.NewPlugin("fast", []table.ColumnDefinition{table.TextColumn("foo")}, gen)
+	server := newTestServer(plugin, WithOsqueryTimeout(3*time.Second, time.Second), WithCallTimeout(time.Second))
+
+	resp, err := server.Call(context.Background(), "table", "fast", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"foo": "bar"}}, resp.Response)
+	assert.True(t, remaining <= time.Second && remaining > 900*time.Millisecond, "remaining %s", remaining)
+}
+
+// shutdownRecorder is a table recording whether it was shut down.
+type shutdownRecorder struct {
+	*table.Plugin
+	shutdown int32
+}
+
+func (p *shutdownRecorder) Shutdown() {
+	atomic.StoreInt32(&p.shutdown, 1)
+}
+
+func TestOsqueryTimeoutShutdownWaits(t *testing.T) {
+	release := make(chan struct{})
+	var returnedShutdown int32 = -1
+	var plugin *shutdownRecorder
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		<-release
+		atomic.StoreInt32(&returnedShutdown, atomic.LoadInt32(&plugin.shutdown))
+		return nil, nil
+	}
+	plugin = &shutdownRecorder{Plugin: table.NewPlugin("stuck", []table.ColumnDefinition{table.TextColumn("foo")}, gen)}
+	server := newTestServer(plugin, WithOsqueryTimeout(100*time.Millisecond, 50*time.Millisecond))
+	server.serverClient = &MockExtensionManager{
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+
+	resp, err := server.Call(context.Background(), "table", "stuck", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, StatusDeadlineExceeded, resp.Status.Code)
+
+	// Shutdown waits for the timed out call before shutting down plugins
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned before the timed out call completed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&plugin.shutdown))
+
+	close(release)
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the timed out call completed")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&returnedShutdown))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&plugin.shutdown))
+}
+
+func TestCallBeforeDeadlineShuttingDown(t *testing.T) {
+	server := newTestServer(newTestTable("foo"))
+	// The call was accepted before the shutdown closed the tracker
+	require.True(t, server.calls.add())
+	server.calls.close()
+
+	called := false
+	resp := server.callBeforeDeadline(context.Background(), func(ctx context.Context) osquery.ExtensionResponse {
+		called = true
+		return osquery.ExtensionResponse{}
+	})
+	assert.False(t, called)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "extension is shutting down", resp.Status.Message)
+
+	// The accepted call is still in-flight
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, server.calls.wait(ctx))
+	server.calls.done()
+	assert.NoError(t, server.calls.wait(context.Background()))
+}
+
+func TestCallBeforeDeadlineCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := newTestServer(newTestTable("foo"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp := server.callBeforeDeadline(ctx, func(ctx context.Context) osquery.ExtensionResponse {
+		<-release
+		return osquery.ExtensionResponse{}
+	})
+	assert.Equal(t, StatusCanceled, resp.Status.Code)
+	assert.Equal(t, "call canceled: context canceled", resp.Status.Message)
+}
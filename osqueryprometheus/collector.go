@@ -0,0 +1,94 @@
+// Package osqueryprometheus provides a Prometheus collector for the metrics
+// recorded by an osquery extension.
+//
+// The Collector implements osquery.MetricsRecorder and prometheus.Collector:
+//
+//	collector := osqueryprometheus.NewCollector("myextension")
+//	prometheus.MustRegister(collector)
+//	server, err := osquery.NewExtensionManagerServer(name, socket,
+//		osquery.WithMetricsRecorder(collector),
+//	)
+//
+// The following metrics are exported (prefixed with the namespace, if any):
+//
+//	osquery_extension_calls_total{plugin,action,status}        counter
+//	osquery_extension_call_errors_total{plugin,action}         counter
+//	osquery_extension_call_duration_seconds{plugin,action}     histogram
+//	osquery_extension_call_rows_total{plugin,action}           counter
+//
+// The plugin label is "<registry>/<name>" (eg. "table/processes") and the
+// status label is the status code returned by the plugin.
+package osqueryprometheus
+
+import (
+	"strconv"
+
+	"github.com/osquery/osquery-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector records osquery extension call metrics as Prometheus metrics.
+type Collector struct {
+	calls    *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	rows     *prometheus.CounterVec
+}
+
+var _ osquery.MetricsRecorder = (*Collector)(nil)
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector creates a Collector with metric names prefixed by namespace.
+// The namespace may be empty.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "osquery_extension_calls_total",
+			Help:      "Number of plugin calls handled by the extension.",
+		}, []string{"plugin", "action", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "osquery_extension_call_errors_total",
+			Help:      "Number of plugin calls that returned a non-zero status.",
+		}, []string{"plugin", "action"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "osquery_extension_call_duration_seconds",
+			Help:      "Time spent handling plugin calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"plugin", "action"}),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "osquery_extension_call_rows_total",
+			Help:      "Number of rows returned by plugin calls.",
+		}, []string{"plugin", "action"}),
+	}
+}
+
+// RecordCall implements osquery.MetricsRecorder.
+func (c *Collector) RecordCall(m osquery.CallMetrics) {
+	plugin := m.Registry + "/" + m.Item
+	c.calls.WithLabelValues(plugin, m.Action, strconv.Itoa(int(m.StatusCode))).Inc()
+	if m.StatusCode != 0 {
+		c.errors.WithLabelValues(plugin, m.Action).Inc()
+	}
+	c.duration.WithLabelValues(plugin, m.Action).Observe(m.Duration.Seconds())
+	c.rows.WithLabelValues(plugin, m.Action).Add(float64(m.Rows))
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.calls.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+	c.rows.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.calls.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+	c.rows.Collect(ch)
+}
@@ -0,0 +1,53 @@
+package osqueryprometheus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	collector := NewCollector("test")
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	collector.RecordCall(osquery.CallMetrics{
+		Registry: "table", Item: "processes", Action: "generate",
+		Duration: 10 * time.Millisecond, Rows: 3,
+	})
+	collector.RecordCall(osquery.CallMetrics{
+		Registry: "table", Item: "processes", Action: "generate",
+		Duration: 20 * time.Millisecond, Rows: 2,
+	})
+	collector.RecordCall(osquery.CallMetrics{
+		Registry: "table", Item: "processes", Action: "generate",
+		Duration: time.Millisecond, StatusCode: 1,
+	})
+
+	expected := `
+# HELP test_osquery_extension_calls_total Number of plugin calls handled by the extension.
+# TYPE test_osquery_extension_calls_total counter
+test_osquery_extension_calls_total{action="generate",plugin="table/processes",status="0"} 2
+test_osquery_extension_calls_total{action="generate",plugin="table/processes",status="1"} 1
+# HELP test_osquery_extension_call_errors_total Number of plugin calls that returned a non-zero status.
+# TYPE test_osquery_extension_call_errors_total counter
+test_osquery_extension_call_errors_total{action="generate",plugin="table/processes"} 1
+# HELP test_osquery_extension_call_rows_total Number of rows returned by plugin calls.
+# TYPE test_osquery_extension_call_rows_total counter
+test_osquery_extension_call_rows_total{action="generate",plugin="table/processes"} 5
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"test_osquery_extension_calls_total",
+		"test_osquery_extension_call_errors_total",
+		"test_osquery_extension_call_rows_total",
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "test_osquery_extension_call_duration_seconds"))
+}
@@ -0,0 +1,11 @@
+module github.com/osquery/osquery-go/osqueryprometheus
+
+go 1.13
+
+require (
+	github.com/osquery/osquery-go v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.11.1
+	github.com/stretchr/testify v1.4.0
+)
+
+replace github.com/osquery/osquery-go => ../
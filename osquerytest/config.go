@@ -0,0 +1,55 @@
+package osquerytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	osquery "github.com/osquery/osquery-go"
+)
+
+// GenerateConfig requests the configuration from a config plugin, as osquery
+// does when it loads its config, and returns the config of each source
+// decoded from its JSON. The request goes through the same dispatch path as
+// the other helpers of this package (see NewInProcess).
+//
+// An error is returned if the plugin returns an error status, or if the
+// config of a source is not a valid JSON object, which osquery would reject.
+func GenerateConfig(plugin osquery.OsqueryPlugin) (map[string]map[string]interface{}, error) {
+	if plugin.RegistryName() != "config" {
+		return nil, fmt.Errorf("%s plugin %q is not a config plugin", plugin.RegistryName(), plugin.Name())
+	}
+
+	client, teardown := NewInProcess(plugin)
+	defer teardown()
+
+	resp, err := client.Call("config", plugin.Name(), map[string]string{"action": "genConfig"})
+	if err != nil {
+		return nil, fmt.Errorf("calling plugin: %w", err)
+	}
+	if resp.Status.Code != 0 {
+		return nil, fmt.Errorf("genConfig returned status %d: %s", resp.Status.Code, resp.Status.Message)
+	}
+	if len(resp.Response) != 1 {
+		return nil, fmt.Errorf("genConfig returned %d rows, expected 1", len(resp.Response))
+	}
+
+	sources := make([]string, 0, len(resp.Response[0]))
+	for source := range resp.Response[0] {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	configs := make(map[string]map[string]interface{}, len(sources))
+	for _, source := range sources {
+		var config map[string]interface{}
+		if err := json.Unmarshal([]byte(resp.Response[0][source]), &config); err != nil {
+			return nil, fmt.Errorf("config of source %q is not valid JSON: %w", source, err)
+		}
+		if config == nil {
+			return nil, fmt.Errorf("config of source %q is not a JSON object", source)
+		}
+		configs[source] = config
+	}
+	return configs, nil
+}
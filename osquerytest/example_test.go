@@ -0,0 +1,46 @@
+package osquerytest_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/osquery/osquery-go/osquerytest"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+func ExampleNewInProcess() {
+	plugin := table.NewPlugin("greetings", []table.ColumnDefinition{
+		table.TextColumn("greeting"),
+	}, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"greeting": "hello world"}}, nil
+	})
+
+	client, teardown := osquerytest.NewInProcess(plugin)
+	defer teardown()
+
+	row, err := client.QueryRow("SELECT * FROM greetings")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(row["greeting"])
+	// Output: hello world
+}
+
+func ExampleGenerateConfig() {
+	plugin := config.NewPlugin("static", func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{
+			"main": `{"schedule":{"uptime":{"query":"SELECT * FROM uptime","interval":60}}}`,
+		}, nil
+	})
+
+	configs, err := osquerytest.GenerateConfig(plugin)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	schedule := configs["main"]["schedule"].(map[string]interface{})
+	fmt.Println(schedule["uptime"].(map[string]interface{})["query"])
+	// Output: SELECT * FROM uptime
+}
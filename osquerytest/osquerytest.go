@@ -0,0 +1,193 @@
+// Package osquerytest provides utilities for testing osquery extensions
+// without running osquery.
+package osquerytest
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"sync"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	osquery "github.com/osquery/osquery-go"
+	gen "github.com/osquery/osquery-go/gen/osquery"
+)
+
+// NewInProcess serves the provided plugins from an extension connected to a
+// fake osquery extension manager, all within the current process. The
+// returned client is connected to the fake manager, and the returned function
+// tears everything down.
+//
+// All communication happens over in-memory pipes using the same Thrift
+// protocol as osquery, so a query made with the client is dispatched by the
+// ExtensionManagerServer to the plugin just as it would be in production.
+//
+// The fake manager only supports queries of the form "SELECT * FROM <table>"
+// against the provided table plugins, and calls to any of the provided
+// plugins. The returned client is not safe for concurrent use.
+func NewInProcess(plugins ...osquery.OsqueryPlugin) (*osquery.ExtensionManagerClient, func()) {
+	m := &manager{plugins: map[string]map[string]osquery.OsqueryPlugin{}}
+	for _, plugin := range plugins {
+		if m.plugins[plugin.RegistryName()] == nil {
+			m.plugins[plugin.RegistryName()] = map[string]osquery.OsqueryPlugin{}
+		}
+		m.plugins[plugin.RegistryName()][plugin.Name()] = plugin
+	}
+
+	var wg sync.WaitGroup
+	var conns []net.Conn
+	// pipe returns a transport connected to processor over an in-memory
+	// pipe.
+	pipe := func(processor thrift.TProcessor) thrift.TTransport {
+		client, server := net.Pipe()
+		conns = append(conns, client, server)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(processor, server)
+		}()
+		return thrift.NewTSocketFromConnTimeout(client, 0)
+	}
+	newClient := func() *osquery.ExtensionManagerClient {
+		trans := pipe(gen.NewExtensionManagerProcessor(m))
+		return &osquery.ExtensionManagerClient{
+			Client: gen.NewExtensionManagerClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault()),
+		}
+	}
+
+	server, err := osquery.NewExtensionManagerServer("osquerytest", "", osquery.WithClient(newClient()))
+	if err != nil {
+		// Not reachable, as the server does not connect to a socket
+		// when a client is provided.
+		panic(err)
+	}
+	server.RegisterPlugin(plugins...)
+
+	m.extension = gen.NewExtensionClientFactory(
+		pipe(gen.NewExtensionProcessor(server)),
+		thrift.NewTBinaryProtocolFactoryDefault(),
+	)
+
+	client := newClient()
+	teardown := func() {
+		server.Shutdown(context.Background())
+		for _, conn := range conns {
+			conn.Close()
+		}
+		wg.Wait()
+	}
+	return client, teardown
+}
+
+// serve processes requests from conn until it is closed.
+func serve(processor thrift.TProcessor, conn net.Conn) {
+	trans := thrift.NewTSocketFromConnTimeout(conn, 0)
+	prot := thrift.NewTBinaryProtocolFactoryDefault().GetProtocol(trans)
+	for {
+		ok, err := processor.Process(context.Background(), prot, prot)
+		if err != nil || !ok {
+			return
+		}
+	}
+}
+
+var selectAllRegexp = regexp.MustCompile(`(?i)^\s*select\s+\*\s+from\s+(\w+)\s*;?\s*$`)
+
+// manager is a fake osquery extension manager, routing calls to the
+// extension serving the plugins.
+type manager struct {
+	plugins map[string]map[string]osquery.OsqueryPlugin
+
+	// mu guards extension, as the Thrift client is not safe for
+	// concurrent use.
+	mu        sync.Mutex
+	extension *gen.ExtensionClient
+}
+
+func (m *manager) Ping(ctx context.Context) (*gen.ExtensionStatus, error) {
+	return &gen.ExtensionStatus{Code: 0, Message: "OK"}, nil
+}
+
+func (m *manager) Call(ctx context.Context, registry string, item string, request gen.ExtensionPluginRequest) (*gen.ExtensionResponse, error) {
+	if m.plugins[registry][item] == nil {
+		return errorResponse("Unknown registry item: " + registry + "/" + item), nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.extension.Call(ctx, registry, item, request)
+}
+
+func (m *manager) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (m *manager) Extensions(ctx context.Context) (gen.InternalExtensionList, error) {
+	return gen.InternalExtensionList{
+		1: &gen.InternalExtensionInfo{Name: "osquerytest"},
+	}, nil
+}
+
+func (m *manager) RegisterExtension(ctx context.Context, info *gen.InternalExtensionInfo, registry gen.ExtensionRegistry) (*gen.ExtensionStatus, error) {
+	return &gen.ExtensionStatus{Code: 0, Message: "OK", UUID: 1}, nil
+}
+
+func (m *manager) DeregisterExtension(ctx context.Context, uuid gen.ExtensionRouteUUID) (*gen.ExtensionStatus, error) {
+	return &gen.ExtensionStatus{Code: 0, Message: "OK"}, nil
+}
+
+func (m *manager) Options(ctx context.Context) (gen.InternalOptionList, error) {
+	return gen.InternalOptionList{}, nil
+}
+
+func (m *manager) Query(ctx context.Context, sql string) (*gen.ExtensionResponse, error) {
+	name, resp := m.parseQuery(sql)
+	if resp != nil {
+		return resp, nil
+	}
+
+	return m.Call(ctx, "table", name, gen.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": "{}",
+	})
+}
+
+func (m *manager) GetQueryColumns(ctx context.Context, sql string) (*gen.ExtensionResponse, error) {
+	name, resp := m.parseQuery(sql)
+	if resp != nil {
+		return resp, nil
+	}
+
+	// osquery responds with one row per column, mapping the column name
+	// to its type.
+	var columns gen.ExtensionPluginResponse
+	for _, route := range m.plugins["table"][name].Routes() {
+		if route["id"] == "column" {
+			columns = append(columns, map[string]string{route["name"]: route["type"]})
+		}
+	}
+	return &gen.ExtensionResponse{
+		Status:   &gen.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: columns,
+	}, nil
+}
+
+// parseQuery returns the name of the table selected by sql, or an error
+// response if the query is not supported.
+func (m *manager) parseQuery(sql string) (string, *gen.ExtensionResponse) {
+	match := selectAllRegexp.FindStringSubmatch(sql)
+	if match == nil {
+		return "", errorResponse("osquerytest only supports queries of the form SELECT * FROM <table>")
+	}
+	if m.plugins["table"][match[1]] == nil {
+		return "", errorResponse("no such table: " + match[1])
+	}
+	return match[1], nil
+}
+
+func errorResponse(message string) *gen.ExtensionResponse {
+	return &gen.ExtensionResponse{
+		Status: &gen.ExtensionStatus{Code: 1, Message: message},
+	}
+}
@@ -0,0 +1,117 @@
+package osquerytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gen "github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTable() *table.Plugin {
+	return table.NewPlugin("animals", []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.IntegerColumn("legs"),
+	}, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{
+			{"name": "cat", "legs": "4"},
+			{"name": "bird", "legs": "2"},
+		}, nil
+	})
+}
+
+func TestInProcessQuery(t *testing.T) {
+	client, teardown := NewInProcess(testTable())
+	defer teardown()
+
+	rows, err := client.QueryRows("select * from animals;")
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{
+		{"name": "cat", "legs": "4"},
+		{"name": "bird", "legs": "2"},
+	}, rows)
+
+	_, err = client.QueryRows("select * from plants")
+	assert.EqualError(t, err, `query returned error: no such table: plants (status 1, query "select * from plants")`)
+
+	_, err = client.QueryRows("select name from animals where legs = 2")
+	assert.Error(t, err)
+}
+
+func TestInProcessGetQueryColumns(t *testing.T) {
+	client, teardown := NewInProcess(testTable())
+	defer teardown()
+
+	resp, err := client.GetQueryColumns("SELECT * FROM animals")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, gen.ExtensionPluginResponse{{"name": "TEXT"}, {"legs": "INTEGER"}}, resp.Response)
+}
+
+func TestInProcessCall(t *testing.T) {
+	var logged string
+	plugin := logger.NewPlugin("memory", func(ctx context.Context, typ logger.LogType, log string) error {
+		logged = log
+		return nil
+	})
+	client, teardown := NewInProcess(plugin)
+	defer teardown()
+
+	resp, err := client.Call("logger", "memory", map[string]string{"string": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, "hello", logged)
+
+	resp, err = client.Call("logger", "missing", map[string]string{"string": "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+}
+
+func TestGenerateConfig(t *testing.T) {
+	plugin := config.NewPlugin("static", func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{
+			"main":  `{"options":{"host_identifier":"uuid"},"schedule":{"uptime":{"query":"SELECT * FROM uptime","interval":60}}}`,
+			"packs": `{}`,
+		}, nil
+	})
+
+	configs, err := GenerateConfig(plugin)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"main": {
+			"options":  map[string]interface{}{"host_identifier": "uuid"},
+			"schedule": map[string]interface{}{"uptime": map[string]interface{}{"query": "SELECT * FROM uptime", "interval": float64(60)}},
+		},
+		"packs": {},
+	}, configs)
+}
+
+func TestGenerateConfigErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		configs  map[string]string
+		genErr   error
+		expected string
+	}{
+		{"invalid JSON", map[string]string{"main": `{"options":`}, nil, `config of source "main" is not valid JSON: unexpected end of JSON input`},
+		{"not an object", map[string]string{"main": `[]`}, nil, `config of source "main" is not valid JSON: json: cannot unmarshal array into Go value of type map[string]interface {}`},
+		{"null", map[string]string{"main": `null`}, nil, `config of source "main" is not a JSON object`},
+		{"generate error", nil, errors.New("backend unavailable"), "genConfig returned status 1: error getting config: backend unavailable"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := config.NewPlugin("static", func(ctx context.Context) (map[string]string, error) {
+				return tt.configs, tt.genErr
+			})
+			_, err := GenerateConfig(plugin)
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+
+	_, err := GenerateConfig(testTable())
+	assert.EqualError(t, err, `table plugin "animals" is not a config plugin`)
+}
@@ -0,0 +1,186 @@
+// Package carver creates an osquery file carving plugin.
+//
+// osquery carves files by archiving them and splitting the archive into
+// fixed size blocks, which are then delivered one at a time:
+//
+//  1. A "start" request announces the carve with the carve_id, the
+//     request_id of the originating query, the total carve_size in bytes,
+//     the block_size and the block_count. The plugin responds with a
+//     session_id identifying the upload.
+//  2. A "continue" request is sent for each block, in order, with the
+//     session_id, request_id, the zero-based block_id and the base64
+//     encoded block data. The carve is complete once block_count blocks
+//     have been received.
+//
+// This mirrors the protocol used by osquery with the carver_start_endpoint
+// and carver_continue_endpoint of the TLS plugin. Note that osquery core
+// ships carves to those TLS endpoints and does not consult a "carver"
+// registry, so a carver plugin is only reached by callers that route through
+// the extension manager (eg. another extension using the ExtensionManager
+// Call API). osquery versions that do not know the registry may refuse to
+// register it, so carver plugins are best served from a dedicated extension.
+package carver
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// Carve contains the details of a carve announced by a start request.
+type Carve struct {
+	// CarveID is the identifier osquery assigned to the carve.
+	CarveID string
+	// RequestID is the identifier of the query that requested the carve.
+	RequestID string
+	// CarveSize is the total size in bytes of the carved archive.
+	CarveSize int64
+	// BlockSize is the size in bytes of each block (the last block may be
+	// smaller).
+	BlockSize int64
+	// BlockCount is the number of blocks that will be sent.
+	BlockCount int64
+}
+
+// Block contains a single block of carve data.
+type Block struct {
+	// SessionID is the session identifier returned by the StartFunc.
+	SessionID string
+	// RequestID is the identifier of the query that requested the carve.
+	RequestID string
+	// BlockID is the zero-based index of the block.
+	BlockID int64
+	// Data is the decoded block content.
+	Data []byte
+}
+
+// StartFunc begins a new carve session, returning the session identifier that
+// osquery will include in each block of the carve. The context argument can
+// optionally be used for cancellation in long-running operations.
+type StartFunc func(ctx context.Context, carve Carve) (sessionID string, err error)
+
+// BlockFunc stores a single block of a carve session. The context argument can
+// optionally be used for cancellation in long-running operations.
+type BlockFunc func(ctx context.Context, block Block) error
+
+// Plugin is an osquery carver plugin. Plugin implements the OsqueryPlugin
+// interface.
+type Plugin struct {
+	name  string
+	start StartFunc
+	block BlockFunc
+}
+
+// NewPlugin takes the carve session functions and returns a struct
+// implementing the OsqueryPlugin interface. Use this to wrap the appropriate
+// functions into an osquery plugin.
+func NewPlugin(name string, start StartFunc, block BlockFunc) *Plugin {
+	return &Plugin{name: name, start: start, block: block}
+}
+
+func (t *Plugin) Name() string {
+	return t.name
+}
+
+// Registry name for carver plugins
+const carverRegistryName = "carver"
+
+func (t *Plugin) RegistryName() string {
+	return carverRegistryName
+}
+
+// Routes returns no routes. osquery only uses routes to describe the columns
+// and attributes of table plugins; carver plugins are routed by name alone.
+func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
+	return osquery.ExtensionPluginResponse{}
+}
+
+func (t *Plugin) Ping() osquery.ExtensionStatus {
+	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
+}
+
+// Key that the request method is stored under
+const requestActionKey = "action"
+
+// Action value used when a carve is started
+const startAction = "start"
+
+// Action value used when a block is sent
+const continueAction = "continue"
+
+func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	switch request[requestActionKey] {
+	case startAction:
+		carve := Carve{
+			CarveID:   request["carve_id"],
+			RequestID: request["request_id"],
+		}
+		var err error
+		for _, field := range []struct {
+			key string
+			dst *int64
+		}{
+			{"carve_size", &carve.CarveSize},
+			{"block_size", &carve.BlockSize},
+			{"block_count", &carve.BlockCount},
+		} {
+			if *field.dst, err = strconv.ParseInt(request[field.key], 10, 64); err != nil {
+				return errorResponse("invalid " + field.key + ": " + err.Error())
+			}
+		}
+
+		sessionID, err := t.start(ctx, carve)
+		if err != nil {
+			return errorResponse("error starting carve: " + err.Error())
+		}
+
+		return osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: osquery.ExtensionPluginResponse{{"session_id": sessionID}},
+		}
+
+	case continueAction:
+		blockID, err := strconv.ParseInt(request["block_id"], 10, 64)
+		if err != nil {
+			return errorResponse("invalid block_id: " + err.Error())
+		}
+		data, err := base64.StdEncoding.DecodeString(request["data"])
+		if err != nil {
+			return errorResponse("error decoding block data: " + err.Error())
+		}
+
+		err = t.block(ctx, Block{
+			SessionID: request["session_id"],
+			RequestID: request["request_id"],
+			BlockID:   blockID,
+			Data:      data,
+		})
+		if err != nil {
+			return errorResponse("error writing block: " + err.Error())
+		}
+
+		return osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: osquery.ExtensionPluginResponse{},
+		}
+
+	case "":
+		return errorResponse("missing 'action' in request")
+
+	default:
+		return errorResponse("unknown action: " + request["action"])
+	}
+}
+
+func (t *Plugin) Shutdown() {}
+
+func errorResponse(message string) osquery.ExtensionResponse {
+	return osquery.ExtensionResponse{
+		Status: &osquery.ExtensionStatus{
+			Code:    1,
+			Message: message,
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package carver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var StatusOK = osquery.ExtensionStatus{Code: 0, Message: "OK"}
+
+func TestCarverPlugin(t *testing.T) {
+	var started Carve
+	received := map[string]*bytes.Buffer{}
+	plugin := NewPlugin("mock",
+		func(ctx context.Context, carve Carve) (string, error) {
+			started = carve
+			received["session1"] = &bytes.Buffer{}
+			return "session1", nil
+		},
+		func(ctx context.Context, block Block) error {
+			buf, ok := received[block.SessionID]
+			if !ok {
+				return errors.New("unknown session")
+			}
+			buf.Write(block.Data)
+			return nil
+		},
+	)
+
+	// Basic methods
+	assert.Equal(t, "carver", plugin.RegistryName())
+	assert.Equal(t, "mock", plugin.Name())
+	assert.Equal(t, StatusOK, plugin.Ping())
+	assert.Equal(t, osquery.ExtensionPluginResponse{}, plugin.Routes())
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":      "start",
+		"carve_id":    "carve1",
+		"request_id":  "req1",
+		"carve_size":  "10",
+		"block_size":  "6",
+		"block_count": "2",
+	})
+	require.Equal(t, &StatusOK, resp.Status)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"session_id": "session1"}}, resp.Response)
+	assert.Equal(t, Carve{CarveID: "carve1", RequestID: "req1", CarveSize: 10, BlockSize: 6, BlockCount: 2}, started)
+
+	for i, data := range []string{"hello ", "carv"} {
+		resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":     "continue",
+			"session_id": "session1",
+			"request_id": "req1",
+			"block_id":   strconv.Itoa(i),
+			"data":       base64.StdEncoding.EncodeToString([]byte(data)),
+		})
+		require.Equal(t, &StatusOK, resp.Status)
+	}
+	assert.Equal(t, "hello carv", received["session1"].String())
+}
+
+func TestCarverPluginErrors(t *testing.T) {
+	var called bool
+	plugin := NewPlugin("mock",
+		func(ctx context.Context, carve Carve) (string, error) {
+			called = true
+			return "", errors.New("foobar")
+		},
+		func(ctx context.Context, block Block) error {
+			called = true
+			return errors.New("foobar")
+		},
+	)
+
+	// Call with bad actions
+	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{}).Status.Code)
+	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "bad"}).Status.Code)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), nil).Status.Message)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": ""}).Status.Message)
+
+	// Call with malformed requests
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "start", "carve_size": "10", "block_size": "x"})
+	assert.Equal(t, "invalid block_size: strconv.ParseInt: parsing \"x\": invalid syntax", resp.Status.Message)
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "continue", "block_id": "0", "data": "!!"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.False(t, called)
+
+	// Call with good actions but callbacks fail
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "start", "carve_size": "1", "block_size": "1", "block_count": "1"})
+	assert.True(t, called)
+	assert.Equal(t, "error starting carve: foobar", resp.Status.Message)
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "continue", "block_id": "0", "data": ""})
+	assert.Equal(t, "error writing block: foobar", resp.Status.Message)
+}
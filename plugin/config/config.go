@@ -40,6 +40,8 @@ func (t *Plugin) RegistryName() string {
 	return configRegistryName
 }
 
+// Routes returns no routes. osquery only uses routes to describe the columns
+// and attributes of table plugins; config plugins are routed by name alone.
 func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
 	return osquery.ExtensionPluginResponse{}
 }
@@ -54,6 +56,9 @@ const requestActionKey = "action"
 // Action value used when config is requested
 const genConfigAction = "genConfig"
 
+// Action value used when a pack is requested by name
+const genPackAction = "genPack"
+
 func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
 	switch request[requestActionKey] {
 	case genConfigAction:
@@ -72,6 +77,23 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			Response: osquery.ExtensionPluginResponse{configs},
 		}
 
+	case genPackAction:
+		// Packs are only supported as part of the generated configs.
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "action not supported: " + genPackAction,
+			},
+		}
+
+	case "":
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "missing 'action' in request",
+			},
+		}
+
 	default:
 		return osquery.ExtensionResponse{
 			Status: &osquery.ExtensionStatus{
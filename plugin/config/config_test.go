@@ -45,9 +45,17 @@ func TestConfigPluginErrors(t *testing.T) {
 	assert.False(t, called)
 	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "bad"}).Status.Code)
 	assert.False(t, called)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), nil).Status.Message)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": ""}).Status.Message)
+
+	// Packs are not requested separately
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genPack", "name": "pack", "value": ""})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "action not supported: genPack", resp.Status.Message)
+	assert.False(t, called)
 
 	// Call with good action but generate fails
-	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "genConfig"})
 	assert.True(t, called)
 	assert.Equal(t, int32(1), resp.Status.Code)
 	assert.Equal(t, "error getting config: foobar", resp.Status.Message)
@@ -30,6 +30,30 @@ type GetQueriesResult struct {
 	AccelerateSeconds int `json:"accelerate,omitempty"`
 }
 
+// AddQuery adds the query to run with the name, which identifies its results.
+func (r *GetQueriesResult) AddQuery(name, sql string) {
+	if r.Queries == nil {
+		r.Queries = map[string]string{}
+	}
+	r.Queries[name] = sql
+}
+
+// SetDiscovery sets the discovery query of the query with the name, so that
+// osquery only runs the query if the discovery query returns at least one
+// row, eg. to only run a query on the hosts where a package is installed:
+//
+//	result.AddQuery("nginx_config", "SELECT * FROM file WHERE path = '/etc/nginx/nginx.conf'")
+//	result.SetDiscovery("nginx_config", "SELECT 1 FROM deb_packages WHERE name = 'nginx'")
+//
+// The name must be the name of a query added to the result, as osquery
+// ignores the discovery queries of other names.
+func (r *GetQueriesResult) SetDiscovery(name, sql string) {
+	if r.Discovery == nil {
+		r.Discovery = map[string]string{}
+	}
+	r.Discovery[name] = sql
+}
+
 // GetQueriesFunc returns the queries that should be executed.
 // The returned map should include the query name as the keys, and the query
 // text as values. Results will be returned corresponding to the provided name.
@@ -78,6 +102,9 @@ func (t *Plugin) RegistryName() string {
 	return distributedRegistryName
 }
 
+// Routes returns no routes. osquery only uses routes to describe the columns
+// and attributes of table plugins; distributed plugins are routed by name
+// alone.
 func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
 	return osquery.ExtensionPluginResponse{}
 }
@@ -278,6 +305,14 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			Response: osquery.ExtensionPluginResponse{},
 		}
 
+	case "":
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "missing 'action' in request",
+			},
+		}
+
 	default:
 		return osquery.ExtensionResponse{
 			Status: &osquery.ExtensionStatus{
@@ -98,6 +98,36 @@ func TestDistributedPluginAccelerateDiscovery(t *testing.T) {
 	}
 }
 
+func TestGetQueriesResultDiscovery(t *testing.T) {
+	var result GetQueriesResult
+	result.AddQuery("nginx_config", "select * from file where path = '/etc/nginx/nginx.conf'")
+	result.AddQuery("uptime", "select * from uptime")
+	result.SetDiscovery("nginx_config", "select 1 from deb_packages where name = 'nginx'")
+
+	buf, err := json.Marshal(&result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"queries": {
+			"nginx_config": "select * from file where path = '/etc/nginx/nginx.conf'",
+			"uptime": "select * from uptime"
+		},
+		"discovery": {
+			"nginx_config": "select 1 from deb_packages where name = 'nginx'"
+		}
+	}`, string(buf))
+
+	var decoded GetQueriesResult
+	require.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, result, decoded)
+
+	// Results without discovery queries omit the discovery object.
+	var plain GetQueriesResult
+	plain.AddQuery("uptime", "select * from uptime")
+	buf, err = json.Marshal(&plain)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"queries": {"uptime": "select * from uptime"}}`, string(buf))
+}
+
 func TestDistributedPluginErrors(t *testing.T) {
 	var getCalled, writeCalled bool
 	plugin := NewPlugin(
@@ -119,6 +149,8 @@ func TestDistributedPluginErrors(t *testing.T) {
 	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "bad"}).Status.Code)
 	assert.False(t, getCalled)
 	assert.False(t, writeCalled)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), nil).Status.Message)
+	assert.Equal(t, "missing 'action' in request", plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": ""}).Status.Message)
 
 	// Call with good action but getQueries fails
 	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "getQueries"})
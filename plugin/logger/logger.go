@@ -41,8 +41,10 @@ func (t *Plugin) RegistryName() string {
 	return "logger"
 }
 
+// Routes returns no routes. osquery only uses routes to describe the columns
+// and attributes of table plugins; logger plugins are routed by name alone.
 func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
-	return []map[string]string{}
+	return osquery.ExtensionPluginResponse{}
 }
 
 func (t *Plugin) Ping() osquery.ExtensionStatus {
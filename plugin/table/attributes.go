@@ -0,0 +1,50 @@
+package table
+
+import "strconv"
+
+// TableAttribute is a hint about the behavior of a table, sent to osquery in
+// the routes of the table. Attributes are bit flags which may be combined.
+//
+// Tables served by an extension are read-only generators, so there is no
+// attribute for these.
+type TableAttribute int
+
+// The following attributes are defined by TableAttributes in osquery tables.h.
+const (
+	// TableAttributeUtility marks a table that does not access the host
+	// (bit 1).
+	TableAttributeUtility TableAttribute = 1
+	// TableAttributeCacheable allows osquery to cache the results of the
+	// table between queries run within the same schedule step (bit 2).
+	TableAttributeCacheable TableAttribute = 2
+	// TableAttributeEventBased marks a table whose rows are generated
+	// from buffered events (bit 4).
+	TableAttributeEventBased TableAttribute = 4
+	// TableAttributeUserBased marks a table inspecting user-owned content,
+	// so that osquery may iterate over users (bit 8).
+	TableAttributeUserBased TableAttribute = 8
+)
+
+// WithAttributes sets the attributes of the table. The attributes are sent to
+// osquery in a route of the form {"id": "attributes", "attributes": "<bits>"},
+// where bits is the decimal value of the combined attributes. No route is sent
+// if the table has no attributes.
+func WithAttributes(attrs ...TableAttribute) TableOpt {
+	return func(t *Plugin) {
+		for _, attr := range attrs {
+			t.attributes |= attr
+		}
+	}
+}
+
+// attributesRoute returns the route declaring the attributes of the table, or
+// nil if it has none.
+func (t *Plugin) attributesRoute() map[string]string {
+	if t.attributes == 0 {
+		return nil
+	}
+	return map[string]string{
+		"id":         "attributes",
+		"attributes": strconv.Itoa(int(t.attributes)),
+	}
+}
@@ -0,0 +1,33 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableAttributesRoutes(t *testing.T) {
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return nil, nil
+	}
+	column := map[string]string{"id": "column", "name": "foo", "type": "TEXT", "op": "0"}
+
+	var testCases = []struct {
+		attrs    []TableAttribute
+		expected osquery.ExtensionPluginResponse
+	}{
+		{nil, osquery.ExtensionPluginResponse{column}},
+		{[]TableAttribute{TableAttributeUtility}, osquery.ExtensionPluginResponse{column, {"id": "attributes", "attributes": "1"}}},
+		{[]TableAttribute{TableAttributeCacheable}, osquery.ExtensionPluginResponse{column, {"id": "attributes", "attributes": "2"}}},
+		{[]TableAttribute{TableAttributeEventBased}, osquery.ExtensionPluginResponse{column, {"id": "attributes", "attributes": "4"}}},
+		{[]TableAttribute{TableAttributeUserBased}, osquery.ExtensionPluginResponse{column, {"id": "attributes", "attributes": "8"}}},
+		{[]TableAttribute{TableAttributeCacheable, TableAttributeUserBased}, osquery.ExtensionPluginResponse{column, {"id": "attributes", "attributes": "10"}}},
+	}
+
+	for _, tt := range testCases {
+		plugin := NewPlugin("foo", []ColumnDefinition{TextColumn("foo")}, gen, WithAttributes(tt.attrs...))
+		assert.Equal(t, tt.expected, plugin.Routes())
+	}
+}
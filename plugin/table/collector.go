@@ -0,0 +1,66 @@
+package table
+
+import (
+	"context"
+	"sync"
+)
+
+// RowCollector gathers the rows produced by several goroutines into the
+// response of a single generate call, eg. for a table scanning many
+// directories concurrently. Add may be called concurrently. The rows are in
+// the order the calls to Add complete, which is unspecified across
+// goroutines: osquery does not rely on the order of the rows, and queries
+// needing one use ORDER BY.
+type RowCollector struct {
+	// rows receives the rows of a collector created with
+	// NewStreamRowCollector.
+	rows chan<- map[string]string
+	ctx  context.Context
+
+	mu        sync.Mutex
+	collected []map[string]string
+}
+
+// NewRowCollector returns a RowCollector buffering the rows added, to be
+// returned by a GenerateFunc with Rows once all the goroutines adding rows
+// are done.
+func NewRowCollector() *RowCollector {
+	return &RowCollector{}
+}
+
+// NewStreamRowCollector returns a RowCollector sending the rows added on the
+// rows channel of a StreamGenerateFunc, so that rows flow to the plugin as
+// they are produced instead of being buffered. Add blocks while the stream
+// buffer is full. The StreamGenerateFunc must wait for the goroutines adding
+// rows to be done before returning.
+func NewStreamRowCollector(ctx context.Context, rows chan<- map[string]string) *RowCollector {
+	return &RowCollector{rows: rows, ctx: ctx}
+}
+
+// Add adds a row. For a collector created with NewStreamRowCollector, it
+// returns the ctx error, without adding the row, once the ctx is done, and
+// the goroutine should stop producing rows. Otherwise it always returns nil.
+func (c *RowCollector) Add(row map[string]string) error {
+	if c.rows != nil {
+		select {
+		case c.rows <- row:
+			return nil
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.collected = append(c.collected, row)
+	return nil
+}
+
+// Rows returns the rows added to a collector created with NewRowCollector.
+// It returns nil for collectors created with NewStreamRowCollector, which do
+// not keep the rows.
+func (c *RowCollector) Rows() []map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]map[string]string(nil), c.collected...)
+}
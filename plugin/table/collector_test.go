@@ -0,0 +1,94 @@
+package table
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// produce adds rows numbered 0 to producers*perProducer-1 to c, from
+// producers goroutines.
+func produce(c *RowCollector, producers, perProducer int) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, producers)
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perProducer; j++ {
+				if err := c.Add(map[string]string{"n": strconv.Itoa(i*perProducer + j)}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func sortedValues(rows []map[string]string) []int {
+	var values []int
+	for _, row := range rows {
+		n, _ := strconv.Atoi(row["n"])
+		values = append(values, n)
+	}
+	sort.Ints(values)
+	return values
+}
+
+func sequence(n int) []int {
+	values := make([]int, n)
+	for i := range values {
+		values[i] = i
+	}
+	return values
+}
+
+func TestRowCollector(t *testing.T) {
+	plugin := NewPlugin("parallel", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			c := NewRowCollector()
+			if err := produce(c, 8, 100); err != nil {
+				return nil, err
+			}
+			return c.Rows(), nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, sequence(800), sortedValues(resp.Response))
+}
+
+func TestStreamRowCollector(t *testing.T) {
+	plugin := NewStreamingPlugin("parallel", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, rows chan<- map[string]string) error {
+			c := NewStreamRowCollector(ctx, rows)
+			err := produce(c, 8, 100)
+			assert.Nil(t, c.Rows())
+			return err
+		},
+		WithStreamBuffer(4),
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, sequence(800), sortedValues(resp.Response))
+}
+
+func TestStreamRowCollectorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Nothing reads the channel, so Add only returns once ctx is done.
+	c := NewStreamRowCollector(ctx, make(chan map[string]string))
+	assert.Equal(t, context.Canceled, c.Add(map[string]string{"n": "0"}))
+}
@@ -0,0 +1,74 @@
+package table
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithDeduplication removes exact duplicate rows (rows with the same columns
+// and values) from the rows generated by the table, keeping the first
+// occurrence of each row. The order of the remaining rows is preserved.
+//
+// Deduplication holds a key for every distinct row in memory for the
+// duration of the call, and building the key sorts the columns of each row,
+// so it adds noticeable overhead to tables generating many rows. Tables able
+// to avoid producing duplicates (eg. by deduplicating the inputs of a glob)
+// should do so instead.
+func WithDeduplication() TableOpt {
+	return func(t *Plugin) {
+		t.dedup = true
+	}
+}
+
+// rowSet records the rows seen during a call.
+type rowSet struct {
+	seen map[string]bool
+	keys []string
+}
+
+// add returns true if row was not already added.
+func (s *rowSet) add(row map[string]string) bool {
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	key := s.key(row)
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	return true
+}
+
+// key encodes row unambiguously, with length prefixed columns and values
+// sorted by column.
+func (s *rowSet) key(row map[string]string) string {
+	s.keys = s.keys[:0]
+	for k := range row {
+		s.keys = append(s.keys, k)
+	}
+	sort.Strings(s.keys)
+
+	var b strings.Builder
+	for _, k := range s.keys {
+		for _, str := range [2]string{k, row[k]} {
+			b.WriteString(strconv.Itoa(len(str)))
+			b.WriteByte(':')
+			b.WriteString(str)
+		}
+	}
+	return b.String()
+}
+
+// dedupRows returns rows without duplicates. The rows slice is not modified,
+// as the table may retain it.
+func dedupRows(rows []map[string]string) []map[string]string {
+	var set rowSet
+	unique := make([]map[string]string, 0, len(rows))
+	for _, row := range rows {
+		if set.add(row) {
+			unique = append(unique, row)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,55 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplication(t *testing.T) {
+	rows := []map[string]string{
+		{"path": "/etc/hosts", "size": "10"},
+		{"path": "/etc/passwd", "size": "20"},
+		{"path": "/etc/hosts", "size": "10"},
+		{"path": "/etc/hosts", "size": "11"},
+		{"path": "/etc/hosts"},
+		{"path": "/etc/passwd", "size": "20"},
+		// Not confused with {"path": "/etc/hosts", "size": "10"}
+		{"path": "/etc/hosts\x00size", "size": "10"},
+	}
+	expected := osquery.ExtensionPluginResponse{rows[0], rows[1], rows[3], rows[4], rows[6]}
+	columns := []ColumnDefinition{TextColumn("path"), BigIntColumn("size")}
+
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return rows, nil
+	}
+	stream := func(ctx context.Context, queryContext QueryContext, out chan<- map[string]string) error {
+		for _, row := range rows {
+			out <- row
+		}
+		return nil
+	}
+	plugins := map[string]*Plugin{
+		"generate": NewPlugin("files", columns, gen, WithDeduplication()),
+		"stream":   NewStreamingPlugin("files", columns, stream, WithDeduplication()),
+	}
+
+	for name, plugin := range plugins {
+		t.Run(name, func(t *testing.T) {
+			resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+			require.Equal(t, int32(0), resp.Status.Code)
+			assert.Equal(t, expected, resp.Response)
+		})
+	}
+
+	// The rows returned by the table are not modified
+	assert.Len(t, rows, 7)
+	assert.Equal(t, "/etc/passwd", rows[1]["path"])
+
+	// Without the option duplicates are returned
+	resp := NewPlugin("files", columns, gen).Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Len(t, resp.Response, 7)
+}
@@ -0,0 +1,100 @@
+package table
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PublishFunc adds an event to a table created with NewEventTable. The row
+// holds the column values of the event. Its "time" column is set to the
+// current time, in seconds since the epoch, unless the row has one. The row
+// must not be modified once published.
+type PublishFunc func(row map[string]string)
+
+// NewEventTable creates an event-based table, whose rows are the events
+// published by the extension (eg. from a goroutine watching a source of
+// events) rather than generated when the table is queried.
+//
+// osquery builds its own event-based tables on its event publisher and
+// subscriber registries, which are internal to osquery: extensions cannot
+// register publishers or subscribers, and osquery routes no calls for them to
+// extensions. NewEventTable provides the equivalent within the extension.
+// The events are buffered in memory, up to capacity events after which the
+// oldest are dropped, and each generate call returns all the buffered events,
+// oldest first. Events are not removed when read, so scheduled queries should
+// use differential results or constraints on the time column to only see new
+// events.
+//
+// The table has the TableAttributeEventBased attribute, and a "time" BigInt
+// column is added to columns if it has none.
+func NewEventTable(name string, columns []ColumnDefinition, capacity int, opts ...TableOpt) (*Plugin, PublishFunc) {
+	if !hasColumn(columns, "time") {
+		columns = append(append([]ColumnDefinition(nil), columns...), BigIntColumn("time"))
+	}
+
+	buffer := &eventBuffer{capacity: capacity}
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return buffer.rows(), nil
+	}
+	opts = append([]TableOpt{WithAttributes(TableAttributeEventBased)}, opts...)
+	return NewPlugin(name, columns, gen, opts...), buffer.publish
+}
+
+func hasColumn(columns []ColumnDefinition, name string) bool {
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// eventBuffer is a ring buffer of the last capacity events published.
+type eventBuffer struct {
+	capacity int
+
+	mu     sync.Mutex
+	events []map[string]string
+	next   int // index of the oldest event, once the buffer is full
+}
+
+func (b *eventBuffer) publish(row map[string]string) {
+	if _, ok := row["time"]; !ok {
+		event := make(map[string]string, len(row)+1)
+		for k, v := range row {
+			event[k] = v
+		}
+		event["time"] = strconv.FormatInt(time.Now().Unix(), 10)
+		row = event
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.capacity <= 0 {
+		return
+	}
+	if len(b.events) < b.capacity {
+		b.events = append(b.events, row)
+		return
+	}
+	b.events[b.next] = row
+	b.next = (b.next + 1) % b.capacity
+}
+
+// rows returns copies of the buffered events, oldest first.
+func (b *eventBuffer) rows() []map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	rows := make([]map[string]string, 0, len(b.events))
+	for i := range b.events {
+		event := b.events[(b.next+i)%len(b.events)]
+		row := make(map[string]string, len(event))
+		for k, v := range event {
+			row[k] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,79 @@
+package table
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventTable(t *testing.T) {
+	plugin, publish := NewEventTable("file_events", []ColumnDefinition{TextColumn("path")}, 3)
+
+	assert.Contains(t, plugin.Routes(), map[string]string{"id": "attributes", "attributes": "4"})
+	assert.Equal(t, []ColumnDefinition{TextColumn("path"), BigIntColumn("time")}, plugin.columns)
+
+	generate := func() osquery.ExtensionPluginResponse {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+		return resp.Response
+	}
+	assert.Empty(t, generate())
+
+	publish(map[string]string{"path": "/a"})
+	rows := generate()
+	require.Len(t, rows, 1)
+	assert.Equal(t, "/a", rows[0]["path"])
+	_, err := strconv.ParseInt(rows[0]["time"], 10, 64)
+	assert.NoError(t, err)
+
+	// The oldest events are dropped past the capacity, and events are
+	// returned oldest first.
+	for i := 0; i < 4; i++ {
+		publish(map[string]string{"path": strconv.Itoa(i), "time": strconv.Itoa(100 + i)})
+	}
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"path": "1", "time": "101"},
+		{"path": "2", "time": "102"},
+		{"path": "3", "time": "103"},
+	}, generate())
+	// Events are not removed when read, and modifying the rows returned
+	// does not affect the buffered events.
+	rows = generate()
+	rows[0]["path"] = "modified"
+	assert.Equal(t, "1", generate()[0]["path"])
+}
+
+func TestEventTableTimeColumn(t *testing.T) {
+	columns := []ColumnDefinition{IntegerColumn("time"), TextColumn("path")}
+	plugin, _ := NewEventTable("file_events", columns, 10)
+	assert.Equal(t, columns, plugin.columns)
+}
+
+func TestEventTableConcurrent(t *testing.T) {
+	plugin, publish := NewEventTable("events", []ColumnDefinition{TextColumn("n")}, 16)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				publish(map[string]string{"n": strconv.Itoa(j)})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+				assert.Equal(t, int32(0), resp.Status.Code)
+				assert.True(t, len(resp.Response) <= 16)
+			}
+		}()
+	}
+	wg.Wait()
+}
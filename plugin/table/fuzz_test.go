@@ -0,0 +1,101 @@
+//go:build go1.18
+// +build go1.18
+
+package table
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func FuzzParseQueryContext(f *testing.F) {
+	// Query contexts sent by osquery, with both the stringy (< 3.0) and
+	// typed (>= 3.0) operators.
+	for _, seed := range []string{
+		`{}`,
+		`{"constraints":[]}`,
+		`{"constraints":[{"name":"domain","list":"","affinity":"TEXT"}]}`,
+		`{"constraints":[{"name":"domain","list":[{"op":"2","expr":"kolide.co"}],"affinity":"TEXT"},{"name":"email","list":"","affinity":"TEXT"}]}`,
+		`{"constraints":[{"name":"domain","list":[{"op":2,"expr":"kolide.co"}],"affinity":"TEXT"},{"name":"email","list":[],"affinity":"TEXT"}]}`,
+		`{"constraints":[{"name":"path","list":[{"op":65,"expr":"%foobar"}],"affinity":"TEXT"},{"name":"query","list":[{"op":2,"expr":"kMDItemFSName = \"google*\""}],"affinity":"TEXT"}]}`,
+		`{"constraints":[{"name":"pid","list":[{"op":4,"expr":"100"},{"op":16,"expr":"200"}],"affinity":"INTEGER"}]}`,
+		`{"constraints":[{"name":"pid","list":[{"op":1,"expr":""}],"affinity":"BIGINT"}]}`,
+		`{"constraints":[{"name":"foo","list":["bar", "baz"],"affinity":"TEXT"}]`,
+		// Corner cases of the JSON decoding.
+		`{"Constraints":[{"NAME":"aé\"b","list":[{"op":2,"expr":"😀 \/"}],"affinity":null}]}`,
+		`{"constraints":[{"name":"a","list":null},null],"other":[{},[],1.5e3,-0,true,false,null]}`,
+		`{"constraints":[{"name":"a","list":"x"},{"name":"b","list":""}],"constraints":[{"list":[]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":2,"op":"16","expr":"x","expr":"y"}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":true,"expr":"x"}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":2,"expr":null}]}]}`,
+		`{"constraints":[{"name":"a","list":[null]}]}`,
+		`{"constraints":[{"name":"a"}]}`,
+		"{\"constraints\":[{\"name\":\"\xff\",\"list\":\"\"}]}",
+		` null `,
+		`[]`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, ctxJSON string) {
+		queryContext, err := parseQueryContext(ctxJSON)
+		if err != nil {
+			if queryContext != nil {
+				t.Errorf("non-nil query context returned with error %v", err)
+			}
+			return
+		}
+		if queryContext == nil {
+			t.Fatal("nil query context returned without error")
+		}
+		if queryContext.Constraints == nil {
+			t.Error("nil constraints map")
+		}
+		for name, list := range queryContext.Constraints {
+			if list.Constraints == nil {
+				t.Errorf("nil constraint list for column %q", name)
+			}
+		}
+		// Rendering the parsed context must not panic either.
+		_ = queryContext.String()
+
+		// The parsed context, sent back as osquery would, parses to the
+		// same context.
+		reparsed, err := parseQueryContext(encodeQueryContext(queryContext))
+		if err != nil {
+			t.Fatalf("parsing the encoded context: %v", err)
+		}
+		if !reflect.DeepEqual(queryContext, reparsed) {
+			t.Fatalf("parsed %v, then %v once encoded", queryContext, reparsed)
+		}
+	})
+}
+
+// encodeQueryContext encodes the query context as osquery sends it, with
+// stringy operators as they round trip exactly.
+func encodeQueryContext(queryContext *QueryContext) string {
+	type constraint struct {
+		Op   string `json:"op"`
+		Expr string `json:"expr"`
+	}
+	type constraintList struct {
+		Name     string       `json:"name"`
+		Affinity string       `json:"affinity"`
+		List     []constraint `json:"list"`
+	}
+	var lists []constraintList
+	for name, list := range queryContext.Constraints {
+		cList := constraintList{Name: name, Affinity: string(list.Affinity), List: []constraint{}}
+		for _, c := range list.Constraints {
+			cList.List = append(cList.List, constraint{Op: strconv.Itoa(int(c.Operator)), Expr: c.Expression})
+		}
+		lists = append(lists, cList)
+	}
+	encoded, err := json.Marshal(map[string]interface{}{"constraints": lists})
+	if err != nil {
+		panic(err)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,55 @@
+package table
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NewInfoTable creates a table returning a single row describing the
+// extension, typically named "<extension>_info". The row contains the
+// provided info (eg. version and build commit) as TEXT columns, sorted by
+// name, along with:
+//
+//	started_at  BIGINT  unix time at which the table was created
+//	uptime      BIGINT  seconds elapsed since started_at
+//
+// The info map is copied. An error is returned if info contains a started_at
+// or uptime key, as those columns are computed.
+func NewInfoTable(name string, info map[string]string, opts ...TableOpt) (*Plugin, error) {
+	return newInfoTable(name, info, time.Now, opts...)
+}
+
+func newInfoTable(name string, info map[string]string, now func() time.Time, opts ...TableOpt) (*Plugin, error) {
+	keys := make([]string, 0, len(info))
+	for k := range info {
+		if k == "started_at" || k == "uptime" {
+			return nil, fmt.Errorf("info table %s: %q is a reserved column", name, k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	columns := make([]ColumnDefinition, 0, len(keys)+2)
+	row := make(map[string]string, len(keys)+2)
+	for _, k := range keys {
+		columns = append(columns, TextColumn(k))
+		row[k] = info[k]
+	}
+	columns = append(columns, TimeColumn("started_at"), BigIntColumn("uptime"))
+
+	started := now()
+	row["started_at"] = strconv.FormatInt(started.Unix(), 10)
+
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		result := make(map[string]string, len(row))
+		for k, v := range row {
+			result[k] = v
+		}
+		result["uptime"] = strconv.FormatInt(int64(now().Sub(started)/time.Second), 10)
+		return []map[string]string{result}, nil
+	}
+	return NewPlugin(name, columns, gen, opts...), nil
+}
@@ -0,0 +1,53 @@
+package table
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoTable(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+	info := map[string]string{"version": "1.2.3", "commit": "abc123"}
+	plugin, err := newInfoTable("myext_info", info, clock)
+	require.NoError(t, err)
+
+	// The provided map is copied
+	info["version"] = "changed"
+
+	assert.Equal(t, "myext_info", plugin.Name())
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "commit", "type": "TEXT", "op": "0"},
+		{"id": "column", "name": "version", "type": "TEXT", "op": "0"},
+		{"id": "column", "name": "started_at", "type": "BIGINT", "op": "0"},
+		{"id": "column", "name": "uptime", "type": "BIGINT", "op": "0"},
+	}, plugin.Routes())
+
+	now = now.Add(90 * time.Second)
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"commit": "abc123", "version": "1.2.3", "started_at": "1600000000", "uptime": "90"},
+	}, resp.Response)
+}
+
+func TestInfoTableReservedColumns(t *testing.T) {
+	for _, key := range []string{"uptime", "started_at"} {
+		plugin, err := NewInfoTable("myext_info", map[string]string{"version": "1.2.3", key: "1"})
+		assert.Nil(t, plugin)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `"`+key+`" is a reserved column`)
+		}
+	}
+
+	plugin, err := NewInfoTable("myext_info", nil)
+	require.NoError(t, err)
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Len(t, resp.Response, 1)
+	assert.Equal(t, "0", resp.Response[0]["uptime"])
+}
@@ -0,0 +1,75 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PageGenerateFunc generates one page of the rows of a table. It is called
+// with an empty cursor for the first page, and returns the rows of the page
+// along with the cursor of the next page. An empty next cursor marks the last
+// page. Cursors are opaque to the plugin: they are only passed back to the
+// function, so they may be offsets, continuation tokens returned by an API,
+// etc.
+type PageGenerateFunc func(ctx context.Context, queryContext QueryContext, cursor string) (rows []map[string]string, next string, err error)
+
+// NewPagedPlugin creates a table plugin generating rows with a
+// PageGenerateFunc, for tables backed by a paged source such as a paginated
+// API.
+//
+// The osquery extension protocol has no way of returning the results of a
+// query across several calls: the rows of a generate call are sent to osquery
+// in a single response. The plugin therefore fetches all the pages of a query
+// before responding, stopping as soon as ctx is done. To keep the response
+// bounded, use WithMaxRows: the call fails once the pages fetched hold more
+// rows than allowed, instead of truncating the results silently.
+func NewPagedPlugin(name string, columns []ColumnDefinition, gen PageGenerateFunc, opts ...TableOpt) *Plugin {
+	t := NewPlugin(name, columns, nil, opts...)
+	t.generate = func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return generatePages(ctx, queryContext, gen, t.maxRows)
+	}
+	return t
+}
+
+// WithMaxRows limits the number of rows a paged table may return in response
+// to a generate call. A generate call fails once the pages fetched hold more
+// than n rows, without fetching the remaining pages. A limit of 0, the
+// default, fetches all the pages. It has no effect on tables not created with
+// NewPagedPlugin.
+func WithMaxRows(n int) TableOpt {
+	return func(t *Plugin) {
+		if n >= 0 {
+			t.maxRows = n
+		}
+	}
+}
+
+// generatePages calls gen until it returns the last page, returning the rows
+// of all the pages.
+func generatePages(ctx context.Context, queryContext QueryContext, gen PageGenerateFunc, maxRows int) ([]map[string]string, error) {
+	var rows []map[string]string
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, next, err := gen(ctx, queryContext, cursor)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, page...)
+		if maxRows > 0 && len(rows) > maxRows {
+			return nil, fmt.Errorf("more than %d rows generated", maxRows)
+		}
+
+		if next == "" {
+			return rows, nil
+		}
+		if next == cursor {
+			return nil, errors.New("page cursor did not advance: " + cursor)
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,121 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedSource serves total rows in pages of size rows, using the offset of
+// the page as the cursor.
+type pagedSource struct {
+	total, size int
+	cursors     []string
+}
+
+func (p *pagedSource) page(ctx context.Context, queryContext QueryContext, cursor string) ([]map[string]string, string, error) {
+	p.cursors = append(p.cursors, cursor)
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var rows []map[string]string
+	for i := offset; i < offset+p.size && i < p.total; i++ {
+		rows = append(rows, map[string]string{"n": strconv.Itoa(i)})
+	}
+	next := ""
+	if offset+p.size < p.total {
+		next = strconv.Itoa(offset + p.size)
+	}
+	return rows, next, nil
+}
+
+func TestPagedPlugin(t *testing.T) {
+	source := &pagedSource{total: 10, size: 3}
+	plugin := NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")}, source.page)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, resp.Response, 10)
+	for i, row := range resp.Response {
+		assert.Equal(t, strconv.Itoa(i), row["n"])
+	}
+	assert.Equal(t, []string{"", "3", "6", "9"}, source.cursors)
+}
+
+func TestPagedPluginError(t *testing.T) {
+	calls := 0
+	plugin := NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, cursor string) ([]map[string]string, string, error) {
+			calls++
+			if cursor == "" {
+				return []map[string]string{{"n": "0"}}, "next", nil
+			}
+			return nil, "", errors.New("boom")
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: boom", resp.Status.Message)
+	assert.Empty(t, resp.Response)
+	assert.Equal(t, 2, calls)
+}
+
+func TestPagedPluginMaxRows(t *testing.T) {
+	source := &pagedSource{total: 10, size: 3}
+	plugin := NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")}, source.page, WithMaxRows(5))
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: more than 5 rows generated", resp.Status.Message)
+	assert.Empty(t, resp.Response)
+	// The remaining pages are not fetched once the limit is exceeded.
+	assert.Equal(t, []string{"", "3"}, source.cursors)
+
+	source = &pagedSource{total: 10, size: 3}
+	plugin = NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")}, source.page, WithMaxRows(10))
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Len(t, resp.Response, 10)
+}
+
+func TestPagedPluginCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	plugin := NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, cursor string) ([]map[string]string, string, error) {
+			calls++
+			cancel()
+			return []map[string]string{{"n": cursor}}, cursor + "x", nil
+		},
+	)
+
+	resp := plugin.Call(ctx, osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: "+context.Canceled.Error(), resp.Status.Message)
+	assert.Equal(t, 1, calls)
+}
+
+func TestPagedPluginCursorNotAdvancing(t *testing.T) {
+	plugin := NewPagedPlugin("paged", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, cursor string) ([]map[string]string, string, error) {
+			return nil, "same", nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: page cursor did not advance: same", resp.Status.Message)
+}
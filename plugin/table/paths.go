@@ -0,0 +1,117 @@
+package table
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ErrNoPathConstraint is returned by ExpandPaths when the query has no
+// constraint that can be expanded into paths.
+var ErrNoPathConstraint = errors.New("query must constrain the path with =, LIKE or GLOB")
+
+// ExpandPaths resolves the constraints on column into the paths a table
+// should generate rows for, as osquery does for its file and hash tables:
+//
+//   - equality constraints are returned as is, whether the path exists or
+//     not,
+//   - GLOB patterns are expanded on the filesystem,
+//   - LIKE patterns are expanded on the filesystem with "%" matching any
+//     characters and "_" any single character within a path component. As in
+//     osquery, a trailing "%%" also matches the contents of subdirectories,
+//     recursively.
+//
+// The paths of all constraints are returned in order, without duplicates.
+// osquery applies the constraints to the generated rows, so the paths may be
+// a superset of the matching ones; in particular, LIKE is case-insensitive
+// in SQLite while the expansion follows the case sensitivity of the
+// filesystem.
+//
+// ErrNoPathConstraint is returned if column has none of these constraints, so
+// that tables do not scan the whole filesystem.
+func (q QueryContext) ExpandPaths(column string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	found := false
+	for _, c := range q.Constraints[column].Constraints {
+		var matches []string
+		var err error
+		switch c.Operator {
+		case OperatorEquals:
+			matches = []string{c.Expression}
+		case OperatorGlob:
+			matches, err = filepath.Glob(c.Expression)
+		case OperatorLike:
+			matches, err = expandLike(c.Expression)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("expanding %q: %w", c.Expression, err)
+		}
+		found = true
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	if !found {
+		return nil, ErrNoPathConstraint
+	}
+	return paths, nil
+}
+
+// expandLike expands a LIKE pattern on the filesystem.
+func expandLike(pattern string) ([]string, error) {
+	recursive := strings.HasSuffix(pattern, "%%")
+	if recursive {
+		pattern = strings.TrimSuffix(pattern, "%")
+	}
+	matches, err := filepath.Glob(likeToGlob(pattern))
+	if err != nil || !recursive {
+		return matches, err
+	}
+
+	var paths []string
+	for _, match := range matches {
+		// Walk does not follow symbolic links, so it cannot loop.
+		// Unreadable directories are skipped.
+		filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+			if err == nil {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+	}
+	return paths, nil
+}
+
+// likeToGlob converts a LIKE pattern to the equivalent filepath.Match
+// pattern, escaping the characters that are special to the latter.
+func likeToGlob(pattern string) string {
+	var glob strings.Builder
+	for _, r := range pattern {
+		switch {
+		case r == '%':
+			glob.WriteByte('*')
+		case r == '_':
+			glob.WriteByte('?')
+		case r == '*' || r == '?' || r == '[':
+			// Backslash escapes are not supported on Windows, while
+			// character classes are.
+			glob.WriteByte('[')
+			glob.WriteRune(r)
+			glob.WriteByte(']')
+		case r == '\\' && runtime.GOOS != "windows":
+			glob.WriteString(`\\`)
+		default:
+			glob.WriteRune(r)
+		}
+	}
+	return glob.String()
+}
@@ -0,0 +1,100 @@
+package table
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pathsContext(constraints ...Constraint) QueryContext {
+	return QueryContext{Constraints: map[string]ConstraintList{
+		"path": {Affinity: ColumnTypeText, Constraints: constraints},
+	}}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "osquery-go-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.txt", "b.log", "we[ir]d.txt", filepath.Join("sub", "c.txt"), filepath.Join("sub", "deep", "d.txt")} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	}
+	join := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+
+	for _, tt := range []struct {
+		name        string
+		constraints []Constraint
+		expected    []string
+	}{
+		{
+			"equality",
+			[]Constraint{{OperatorEquals, filepath.Join(dir, "a.txt")}, {OperatorEquals, filepath.Join(dir, "missing")}},
+			join("a.txt", "missing"),
+		},
+		{
+			"glob",
+			[]Constraint{{OperatorGlob, filepath.Join(dir, "*.txt")}},
+			join("a.txt", "we[ir]d.txt"),
+		},
+		{
+			"like",
+			[]Constraint{{OperatorLike, filepath.Join(dir, "%.log")}},
+			join("b.log"),
+		},
+		{
+			"like single character",
+			[]Constraint{{OperatorLike, filepath.Join(dir, "s_b", "%")}},
+			join(filepath.Join("sub", "c.txt"), filepath.Join("sub", "deep")),
+		},
+		{
+			"like escapes glob characters",
+			[]Constraint{{OperatorLike, filepath.Join(dir, "we[ir]%")}},
+			join("we[ir]d.txt"),
+		},
+		{
+			"like recursive",
+			[]Constraint{{OperatorLike, filepath.Join(dir, "sub", "%%")}},
+			join(filepath.Join("sub", "c.txt"), filepath.Join("sub", "deep"), filepath.Join("sub", "deep", "d.txt")),
+		},
+		{
+			"deduplicated",
+			[]Constraint{{OperatorEquals, filepath.Join(dir, "a.txt")}, {OperatorGlob, filepath.Join(dir, "a.*")}, {OperatorGreaterThan, "a"}},
+			join("a.txt"),
+		},
+		{
+			"no matches",
+			[]Constraint{{OperatorGlob, filepath.Join(dir, "*.none")}},
+			nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := pathsContext(tt.constraints...).ExpandPaths("path")
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, paths)
+		})
+	}
+}
+
+func TestExpandPathsErrors(t *testing.T) {
+	_, err := QueryContext{}.ExpandPaths("path")
+	assert.Equal(t, ErrNoPathConstraint, err)
+
+	_, err = pathsContext(Constraint{OperatorGreaterThan, "/etc"}).ExpandPaths("path")
+	assert.Equal(t, ErrNoPathConstraint, err)
+
+	_, err = pathsContext(Constraint{OperatorGlob, "/etc/["}).ExpandPaths("path")
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrNoPathConstraint, err)
+}
@@ -0,0 +1,74 @@
+package table
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// String renders the constraints as a SQL-like predicate, such as
+// "path = '/etc' AND size > 100". Columns are sorted by name. The result is
+// informational (eg. for logging) and is not guaranteed to be valid SQL.
+func (q QueryContext) String() string {
+	columns := make([]string, 0, len(q.Constraints))
+	for column := range q.Constraints {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var terms []string
+	for _, column := range columns {
+		list := q.Constraints[column]
+		for _, c := range list.Constraints {
+			terms = append(terms, c.predicate(column, list.Affinity))
+		}
+	}
+	return strings.Join(terms, " AND ")
+}
+
+func (c Constraint) predicate(column string, affinity ColumnType) string {
+	if c.Operator == OperatorUnique {
+		return "UNIQUE(" + column + ")"
+	}
+	return column + " " + c.Operator.String() + " " + formatExpression(c.Expression, affinity)
+}
+
+// String returns the SQL representation of the operator.
+func (o Operator) String() string {
+	switch o {
+	case OperatorEquals:
+		return "="
+	case OperatorGreaterThan:
+		return ">"
+	case OperatorLessThanOrEquals:
+		return "<="
+	case OperatorLessThan:
+		return "<"
+	case OperatorGreaterThanOrEquals:
+		return ">="
+	case OperatorMatch:
+		return "MATCH"
+	case OperatorLike:
+		return "LIKE"
+	case OperatorGlob:
+		return "GLOB"
+	case OperatorRegexp:
+		return "REGEXP"
+	case OperatorUnique:
+		return "UNIQUE"
+	default:
+		return "OP(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// formatExpression quotes expr unless the affinity is numeric and expr is a
+// number.
+func formatExpression(expr string, affinity ColumnType) string {
+	switch affinity {
+	case ColumnTypeInteger, ColumnTypeBigInt, ColumnTypeDouble:
+		if _, err := strconv.ParseFloat(expr, 64); err == nil {
+			return expr
+		}
+	}
+	return "'" + strings.Replace(expr, "'", "''", -1) + "'"
+}
@@ -0,0 +1,42 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryContextString(t *testing.T) {
+	var testCases = []struct {
+		ctx      QueryContext
+		expected string
+	}{
+		{
+			QueryContext{},
+			"",
+		},
+		{
+			QueryContext{map[string]ConstraintList{
+				"path": {ColumnTypeText, []Constraint{{OperatorEquals, "/etc"}}},
+				"size": {ColumnTypeBigInt, []Constraint{{OperatorGreaterThan, "100"}, {OperatorLessThanOrEquals, "2000"}}},
+			}},
+			"path = '/etc' AND size > 100 AND size <= 2000",
+		},
+		{
+			QueryContext{map[string]ConstraintList{
+				"name":  {ColumnTypeText, []Constraint{{OperatorLike, "%o'brien%"}, {OperatorGlob, "a*"}, {OperatorRegexp, "^a"}, {OperatorMatch, "foo"}}},
+				"count": {ColumnTypeInteger, []Constraint{{OperatorLessThan, "5"}, {OperatorGreaterThanOrEquals, "x"}}},
+				"score": {ColumnTypeDouble, []Constraint{{OperatorUnique, ""}, {Operator(3), "1.5"}}},
+			}},
+			"count < 5 AND count >= 'x' AND " +
+				"name LIKE '%o''brien%' AND name GLOB 'a*' AND name REGEXP '^a' AND name MATCH 'foo' AND " +
+				"UNIQUE(score) AND score OP(3) 1.5",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.ctx.String())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package table
+
+// Pushdown contains the constraints of a query that a table can use to limit
+// the rows it generates, as returned by QueryContext.Pushdown.
+type Pushdown struct {
+	// Values maps each of the requested columns with equality constraints
+	// to the values they are constrained to, in order and without
+	// duplicates. As for osquery's own tables, several values (eg. from
+	// "path IN ('/a', '/b')") mean the table should generate the rows for
+	// each of them.
+	Values map[string][]string
+	// Unsupported maps columns to the constraints on them that cannot be
+	// pushed down: every constraint on other columns, and non-equality
+	// constraints on the requested columns. osquery applies these itself
+	// to the generated rows.
+	Unsupported map[string][]Constraint
+}
+
+// Has returns true if column has equality constraints that can be pushed down.
+func (p Pushdown) Has(column string) bool {
+	return len(p.Values[column]) > 0
+}
+
+// Pushdown resolves the equality constraints on the provided columns, which
+// the table is able to filter on. Tables should generate the rows matching
+// the returned values for the columns present in Values, and all rows
+// otherwise.
+func (q QueryContext) Pushdown(columns ...string) Pushdown {
+	supported := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		supported[column] = true
+	}
+
+	p := Pushdown{Values: map[string][]string{}, Unsupported: map[string][]Constraint{}}
+	for column, list := range q.Constraints {
+		seen := map[string]bool{}
+		for _, c := range list.Constraints {
+			if !supported[column] || c.Operator != OperatorEquals {
+				p.Unsupported[column] = append(p.Unsupported[column], c)
+				continue
+			}
+			if !seen[c.Expression] {
+				seen[c.Expression] = true
+				p.Values[column] = append(p.Values[column], c.Expression)
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,41 @@
+package table
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryContextPushdown(t *testing.T) {
+	qc := QueryContext{Constraints: map[string]ConstraintList{
+		"path": {Affinity: ColumnTypeText, Constraints: []Constraint{
+			{Operator: OperatorEquals, Expression: "/etc"},
+			{Operator: OperatorEquals, Expression: "/tmp"},
+			{Operator: OperatorEquals, Expression: "/etc"},
+			{Operator: OperatorLike, Expression: "/%"},
+		}},
+		"pid": {Affinity: ColumnTypeInteger, Constraints: []Constraint{
+			{Operator: OperatorGreaterThan, Expression: "100"},
+		}},
+		"name": {Affinity: ColumnTypeText, Constraints: []Constraint{
+			{Operator: OperatorEquals, Expression: "osqueryd"},
+		}},
+		"uid": {Affinity: ColumnTypeInteger, Constraints: []Constraint{}},
+	}}
+
+	p := qc.Pushdown("path", "pid")
+	assert.Equal(t, map[string][]string{"path": {"/etc", "/tmp"}}, p.Values)
+	assert.Equal(t, map[string][]Constraint{
+		"path": {{Operator: OperatorLike, Expression: "/%"}},
+		"pid":  {{Operator: OperatorGreaterThan, Expression: "100"}},
+		"name": {{Operator: OperatorEquals, Expression: "osqueryd"}},
+	}, p.Unsupported)
+	assert.True(t, p.Has("path"))
+	assert.False(t, p.Has("pid"))
+	assert.False(t, p.Has("name"))
+
+	p = QueryContext{}.Pushdown("path")
+	assert.Empty(t, p.Values)
+	assert.Empty(t, p.Unsupported)
+	assert.False(t, p.Has("path"))
+}
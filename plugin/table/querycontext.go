@@ -0,0 +1,117 @@
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// The following types and functions exist for parsing of the queryContext
+// JSON and are not made public.
+type queryContextJSON struct {
+	Constraints []constraintListJSON `json:"constraints"`
+}
+
+type constraintListJSON struct {
+	Name     string          `json:"name"`
+	Affinity string          `json:"affinity"`
+	List     json.RawMessage `json:"list"`
+}
+
+// constraintJSON is a constraint of a list. The operator is kept raw, as
+// osquery sends it as a string before 3.0 and as a number since.
+type constraintJSON struct {
+	Op   json.RawMessage `json:"op"`
+	Expr json.RawMessage `json:"expr"`
+}
+
+// ParseQueryContext parses the JSON query context sent by osquery in the
+// "context" key of generate requests. It allows plugins that are not created
+// with NewPlugin, and wrappers of table plugins, to read the constraints of
+// the query.
+func ParseQueryContext(ctxJSON string) (QueryContext, error) {
+	queryContext, err := parseQueryContext(ctxJSON)
+	if err != nil {
+		return QueryContext{}, err
+	}
+	return *queryContext, nil
+}
+
+func parseQueryContext(ctxJSON string) (*QueryContext, error) {
+	var parsed queryContextJSON
+
+	err := json.Unmarshal([]byte(ctxJSON), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling context JSON: %w", err)
+	}
+
+	ctx := QueryContext{make(map[string]ConstraintList, len(parsed.Constraints))}
+	for _, cList := range parsed.Constraints {
+		constraints, err := parseConstraintList(cList.List)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx.Constraints[cList.Name] = ConstraintList{
+			Affinity:    ColumnType(cList.Affinity),
+			Constraints: constraints,
+		}
+	}
+
+	return &ctx, nil
+}
+
+func parseConstraintList(constraints json.RawMessage) ([]Constraint, error) {
+	var str string
+	err := json.Unmarshal(constraints, &str)
+	if err == nil {
+		// string indicates empty list
+		return []Constraint{}, nil
+	}
+
+	var cList []constraintJSON
+	err = json.Unmarshal(constraints, &cList)
+	if err != nil {
+		// cannot do anything with other types
+		return nil, fmt.Errorf("unexpected context list: %s", string(constraints))
+	}
+
+	cl := make([]Constraint, 0, len(cList))
+	for _, c := range cList {
+		var op Operator
+		switch {
+		case len(c.Op) > 0 && c.Op[0] == '"': // osquery < 3.0 with stringy types
+			var opStr string
+			if err := json.Unmarshal(c.Op, &opStr); err != nil {
+				return nil, fmt.Errorf("parsing operator: %w", err)
+			}
+			opInt, err := strconv.Atoi(opStr)
+			if err != nil {
+				return nil, fmt.Errorf("parsing operator int: %s", opStr)
+			}
+			op = Operator(opInt)
+		case len(c.Op) > 0 && (c.Op[0] == '-' || c.Op[0] >= '0' && c.Op[0] <= '9'): // osquery > 3.0 with strong types
+			var opFloat float64
+			if err := json.Unmarshal(c.Op, &opFloat); err != nil {
+				return nil, fmt.Errorf("parsing operator: %w", err)
+			}
+			op = Operator(opFloat)
+		default:
+			return nil, fmt.Errorf("cannot parse operator: %s", string(c.Op))
+		}
+
+		if len(c.Expr) == 0 || c.Expr[0] != '"' {
+			return nil, fmt.Errorf("expr should be string: %s", string(c.Expr))
+		}
+		var expr string
+		if err := json.Unmarshal(c.Expr, &expr); err != nil {
+			return nil, fmt.Errorf("parsing expr: %w", err)
+		}
+
+		cl = append(cl, Constraint{
+			Operator:   op,
+			Expression: expr,
+		})
+	}
+	return cl, nil
+}
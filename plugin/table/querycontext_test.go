@@ -0,0 +1,119 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const benchQueryContext = `{"constraints":[{"name":"domain","list":[{"op":2,"expr":"kolide.co"},{"op":2,"expr":"osquery.io"}],"affinity":"TEXT"},{"name":"email","list":"","affinity":"TEXT"},{"name":"pid","list":[{"op":4,"expr":"100"},{"op":16,"expr":"200"}],"affinity":"INTEGER"}]}`
+
+func TestParseQueryContextPayloads(t *testing.T) {
+	var testCases = []struct {
+		json     string
+		expected map[string]ConstraintList
+	}{
+		{`{}`, map[string]ConstraintList{}},
+		{` null `, map[string]ConstraintList{}},
+		{`{"constraints":[]}`, map[string]ConstraintList{}},
+		{
+			`{"constraints":[{"name":"domain","list":"","affinity":"TEXT"},{"name":"email","list":[],"affinity":"TEXT"}]}`,
+			map[string]ConstraintList{
+				"domain": {ColumnTypeText, []Constraint{}},
+				"email":  {ColumnTypeText, []Constraint{}},
+			},
+		},
+		{
+			// A null list, like a string, is an empty list
+			`{"constraints":[{"name":"domain","list":null}]}`,
+			map[string]ConstraintList{"domain": {"", []Constraint{}}},
+		},
+		{
+			`{"constraints":[{"name":"pid","list":[{"op":"4","expr":"100"},{"op":16,"expr":"200"}],"affinity":"INTEGER"}]}`,
+			map[string]ConstraintList{
+				"pid": {ColumnTypeInteger, []Constraint{{OperatorGreaterThan, "100"}, {OperatorLessThan, "200"}}},
+			},
+		},
+		{
+			// Keys are matched case-insensitively, and strings are unescaped
+			`{"Constraints":[{"NAME":"a\u00e9\"b","list":[{"OP":65,"Expr":"\ud83d\ude00 \/"}],"affinity":null}]}`,
+			map[string]ConstraintList{"aé\"b": {"", []Constraint{{OperatorLike, "😀 /"}}}},
+		},
+		{
+			// The last of duplicate keys wins
+			`{"constraints":[{"name":"a","list":[{"op":2,"op":"16","expr":"x","expr":"y"}]}]}`,
+			map[string]ConstraintList{"a": {"", []Constraint{{OperatorLessThan, "y"}}}},
+		},
+		{
+			"{\"constraints\":[{\"name\":\"\xff\",\"list\":\"\"}]}",
+			map[string]ConstraintList{"\ufffd": {"", []Constraint{}}},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.json, func(t *testing.T) {
+			queryContext, err := parseQueryContext(tt.json)
+			require.NoError(t, err)
+			assert.Equal(t, &QueryContext{tt.expected}, queryContext)
+		})
+	}
+}
+
+func TestParseQueryContextErrors(t *testing.T) {
+	for _, ctxJSON := range []string{
+		``,
+		`[]`,
+		`{"constraints":{}}`,
+		`{"constraints":[{"name":"foo","list":["bar", "baz"],"affinity":"TEXT"}]`,
+		`{"constraints":[{"name":"a"}]}`,
+		`{"constraints":[{"name":"a","list":"x"},null]}`,
+		`{"constraints":[{"name":"a","list":{}}]}`,
+		`{"constraints":[{"name":"a","list":[null]}]}`,
+		`{"constraints":[{"name":"a","list":["x"]}]}`,
+		`{"constraints":[{"name":"a","list":[{"expr":"x"}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":true,"expr":"x"}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":"eq","expr":"x"}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":2}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":2,"expr":null}]}]}`,
+		`{"constraints":[{"name":"a","list":[{"op":2,"expr":3}]}]}`,
+	} {
+		t.Run(ctxJSON, func(t *testing.T) {
+			queryContext, err := parseQueryContext(ctxJSON)
+			assert.Error(t, err)
+			assert.Nil(t, queryContext)
+		})
+	}
+}
+
+func TestParseQueryContextListsAreIndependent(t *testing.T) {
+	queryContext, err := parseQueryContext(benchQueryContext)
+	require.NoError(t, err)
+
+	// Appending to one list must not overwrite another.
+	domain := queryContext.Constraints["domain"].Constraints
+	_ = append(domain, Constraint{OperatorLike, "%"})
+	assert.Equal(t, []Constraint{{OperatorGreaterThan, "100"}, {OperatorLessThan, "200"}}, queryContext.Constraints["pid"].Constraints)
+}
+
+func BenchmarkParseQueryContext(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseQueryContext(benchQueryContext); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTablePluginGenerate(b *testing.B) {
+	plugin := NewPlugin("bench", []ColumnDefinition{TextColumn("domain"), TextColumn("email"), IntegerColumn("pid")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		})
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": benchQueryContext}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		plugin.Call(context.Background(), request)
+	}
+}
@@ -0,0 +1,123 @@
+package table
+
+import (
+	"strconv"
+	"time"
+)
+
+// RowBuilder builds a row for a table, formatting typed values the way osquery
+// expects them for each column type.
+//
+// All values are sent to osquery as strings. osquery converts TEXT, INTEGER,
+// BIGINT and DOUBLE values from their decimal string representation, while the
+// string value of a BLOB column is passed to SQLite unmodified as the raw bytes
+// of the blob. The Thrift protocol used by extensions transfers strings as
+// length-prefixed bytes, so binary data containing NUL or non-UTF-8 bytes is
+// preserved.
+//
+// A row cannot hold NULL values, so osquery treats a column missing from the
+// row as NULL (see SetNull). An empty value is an empty string for TEXT and
+// BLOB columns, but is also read as NULL for the numeric columns, as it is
+// not a valid number.
+type RowBuilder struct {
+	row           map[string]string
+	timePrecision time.Duration
+}
+
+// NewRowBuilder creates an empty RowBuilder.
+func NewRowBuilder() *RowBuilder {
+	return &RowBuilder{row: map[string]string{}, timePrecision: time.Second}
+}
+
+// SetNull sets the value of a column to NULL, by removing it from the row.
+func (b *RowBuilder) SetNull(column string) *RowBuilder {
+	delete(b.row, column)
+	return b
+}
+
+// SetTimePrecision sets the unit of the epoch values written by SetTime, such
+// as time.Millisecond. The unit should be a factor or multiple of a second.
+// The default is time.Second, matching the unix timestamps found in the
+// osquery tables. Non-positive values are ignored.
+func (b *RowBuilder) SetTimePrecision(precision time.Duration) *RowBuilder {
+	if precision > 0 {
+		b.timePrecision = precision
+	}
+	return b
+}
+
+// SetText sets the value of a TEXT column.
+func (b *RowBuilder) SetText(column, value string) *RowBuilder {
+	b.row[column] = value
+	return b
+}
+
+// SetInteger sets the value of an INTEGER column.
+func (b *RowBuilder) SetInteger(column string, value int32) *RowBuilder {
+	b.row[column] = strconv.FormatInt(int64(value), 10)
+	return b
+}
+
+// SetBigInt sets the value of a BIGINT column.
+func (b *RowBuilder) SetBigInt(column string, value int64) *RowBuilder {
+	b.row[column] = strconv.FormatInt(value, 10)
+	return b
+}
+
+// SetDouble sets the value of a DOUBLE column.
+func (b *RowBuilder) SetDouble(column string, value float64) *RowBuilder {
+	b.row[column] = strconv.FormatFloat(value, 'f', -1, 64)
+	return b
+}
+
+// SetBool sets the value of an INTEGER column (see BooleanColumn) to 1 for
+// true, or 0 for false.
+func (b *RowBuilder) SetBool(column string, value bool) *RowBuilder {
+	if value {
+		b.row[column] = "1"
+	} else {
+		b.row[column] = "0"
+	}
+	return b
+}
+
+// SetBlob sets the value of a BLOB column to the raw bytes of value.
+func (b *RowBuilder) SetBlob(column string, value []byte) *RowBuilder {
+	b.row[column] = string(value)
+	return b
+}
+
+// SetTime sets the value of a BIGINT column (see TimeColumn) to the unix epoch
+// of value, in seconds unless configured otherwise with SetTimePrecision. The
+// epoch does not depend on the location of value. Values are truncated to the
+// precision.
+func (b *RowBuilder) SetTime(column string, value time.Time) *RowBuilder {
+	var epoch int64
+	switch p := b.timePrecision; {
+	case p == time.Second:
+		epoch = value.Unix()
+	case p%time.Second == 0:
+		epoch = floorDiv(value.Unix(), int64(p/time.Second))
+	default:
+		// Avoid UnixNano, which overflows for dates outside of the
+		// years 1678 to 2262.
+		epoch = value.Unix()*int64(time.Second/p) + int64(value.Nanosecond())/int64(p)
+	}
+	b.row[column] = strconv.FormatInt(epoch, 10)
+	return b
+}
+
+// floorDiv divides a by b, rounding towards negative infinity so that times
+// before the epoch are also truncated to the earlier value.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// Row returns the built row.
+func (b *RowBuilder) Row() map[string]string {
+	return b.row
+}
@@ -0,0 +1,97 @@
+package table
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowBuilder(t *testing.T) {
+	row := NewRowBuilder().
+		SetText("text", "hello world").
+		SetInteger("integer", -123).
+		SetBigInt("big_int", 1234567890123).
+		SetDouble("double", 3.14159).
+		SetBlob("blob", []byte{0x00, 0x01}).
+		Row()
+
+	assert.Equal(t, map[string]string{
+		"text":    "hello world",
+		"integer": "-123",
+		"big_int": "1234567890123",
+		"double":  "3.14159",
+		"blob":    "\x00\x01",
+	}, row)
+}
+
+func TestRowBuilderSetBool(t *testing.T) {
+	row := NewRowBuilder().SetBool("enabled", true).SetBool("hidden", false).Row()
+	assert.Equal(t, map[string]string{"enabled": "1", "hidden": "0"}, row)
+
+	assert.Equal(t, IntegerColumn("enabled"), BooleanColumn("enabled"))
+}
+
+func TestRowBuilderSetNull(t *testing.T) {
+	plugin := NewPlugin("nulls", []ColumnDefinition{TextColumn("name"), TextColumn("email"), IntegerColumn("uid")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return []map[string]string{
+				NewRowBuilder().SetText("name", "alice").SetText("email", "").SetNull("uid").Row(),
+				NewRowBuilder().SetText("name", "bob").SetText("email", "bob@example.com").SetNull("email").Row(),
+			}, nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code)
+	// NULL cells are missing from the response, while empty strings are
+	// kept.
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"name": "alice", "email": ""},
+		{"name": "bob"},
+	}, resp.Response)
+}
+
+func TestRowBuilderSetTime(t *testing.T) {
+	// 2021-03-04T05:06:07.891Z, in another location to check that the
+	// epoch does not depend on it.
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 891000000, time.UTC).In(time.FixedZone("UTC-8", -8*60*60))
+	before := time.Date(1969, 12, 31, 23, 59, 59, 500000000, time.UTC)
+
+	assert.Equal(t, "1614834367", NewRowBuilder().SetTime("time", ts).Row()["time"])
+	assert.Equal(t, "1614834367891", NewRowBuilder().SetTimePrecision(time.Millisecond).SetTime("time", ts).Row()["time"])
+	assert.Equal(t, "1614834367891000000", NewRowBuilder().SetTimePrecision(time.Nanosecond).SetTime("time", ts).Row()["time"])
+	assert.Equal(t, "26913906", NewRowBuilder().SetTimePrecision(time.Minute).SetTime("time", ts).Row()["time"])
+
+	assert.Equal(t, "-1", NewRowBuilder().SetTime("time", before).Row()["time"])
+	assert.Equal(t, "-500", NewRowBuilder().SetTimePrecision(time.Millisecond).SetTime("time", before).Row()["time"])
+	assert.Equal(t, "-1", NewRowBuilder().SetTimePrecision(time.Minute).SetTime("time", before).Row()["time"])
+
+	assert.Equal(t, TimeColumn("time"), BigIntColumn("time"))
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	plugin := NewPlugin("blobs", []ColumnDefinition{BlobColumn("data")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			return []map[string]string{NewRowBuilder().SetBlob("data", []byte{0x00, 0x01}).Row()}, nil
+		},
+	)
+	assert.Equal(t, "BLOB", plugin.Routes()[0]["type"])
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code)
+
+	// Serialize with the protocol used to communicate with osquery
+	buf := thrift.NewTMemoryBuffer()
+	prot := thrift.NewTBinaryProtocolTransport(buf)
+	require.NoError(t, resp.Write(prot))
+
+	var decoded osquery.ExtensionResponse
+	require.NoError(t, decoded.Read(prot))
+	require.Len(t, decoded.Response, 1)
+	assert.Equal(t, []byte{0x00, 0x01}, []byte(decoded.Response[0]["data"]))
+}
@@ -0,0 +1,163 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Schema is a snapshot of the columns of a set of tables. It can be encoded as
+// JSON and committed, so that a later snapshot can be compared with it using
+// DiffSchemas (eg. in CI) to catch breaking changes.
+type Schema struct {
+	Tables []TableSchema `json:"tables"`
+}
+
+// TableSchema is the schema of a single table.
+type TableSchema struct {
+	Name    string         `json:"name"`
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// ColumnSchema is the schema of a single column.
+type ColumnSchema struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+}
+
+// SchemaOf returns the schema of the provided tables.
+func SchemaOf(plugins ...*Plugin) Schema {
+	var schema Schema
+	for _, plugin := range plugins {
+		table := TableSchema{Name: plugin.name}
+		for _, col := range plugin.columns {
+			table.Columns = append(table.Columns, ColumnSchema{Name: col.Name, Type: col.Type})
+		}
+		schema.Tables = append(schema.Tables, table)
+	}
+	return schema
+}
+
+// ColumnChange describes a column added, removed or retyped between two
+// schemas. OldType is empty for an added column, and NewType for a removed
+// column.
+type ColumnChange struct {
+	Table   string
+	Column  string
+	OldType ColumnType
+	NewType ColumnType
+}
+
+// SchemaDiff describes the changes between two schemas. The tables and
+// columns are sorted by name. The columns of added and removed tables are not
+// listed.
+type SchemaDiff struct {
+	AddedTables    []string
+	RemovedTables  []string
+	AddedColumns   []ColumnChange
+	RemovedColumns []ColumnChange
+	RetypedColumns []ColumnChange
+}
+
+// DiffSchemas returns the changes from the before schema to the after schema.
+func DiffSchemas(before, after Schema) SchemaDiff {
+	var diff SchemaDiff
+	beforeTables := schemaTables(before)
+	afterTables := schemaTables(after)
+
+	for _, name := range sortedTableNames(beforeTables) {
+		if _, ok := afterTables[name]; !ok {
+			diff.RemovedTables = append(diff.RemovedTables, name)
+		}
+	}
+	for _, name := range sortedTableNames(afterTables) {
+		afterColumns := afterTables[name]
+		beforeColumns, ok := beforeTables[name]
+		if !ok {
+			diff.AddedTables = append(diff.AddedTables, name)
+			continue
+		}
+
+		for _, col := range sortedColumnNames(beforeColumns) {
+			oldType := beforeColumns[col]
+			newType, ok := afterColumns[col]
+			switch {
+			case !ok:
+				diff.RemovedColumns = append(diff.RemovedColumns, ColumnChange{Table: name, Column: col, OldType: oldType})
+			case newType != oldType:
+				diff.RetypedColumns = append(diff.RetypedColumns, ColumnChange{Table: name, Column: col, OldType: oldType, NewType: newType})
+			}
+		}
+		for _, col := range sortedColumnNames(afterColumns) {
+			if _, ok := beforeColumns[col]; !ok {
+				diff.AddedColumns = append(diff.AddedColumns, ColumnChange{Table: name, Column: col, NewType: afterColumns[col]})
+			}
+		}
+	}
+	return diff
+}
+
+// Empty returns true if the schemas are identical.
+func (d SchemaDiff) Empty() bool {
+	return !d.Breaking() && len(d.AddedTables) == 0 && len(d.AddedColumns) == 0
+}
+
+// Breaking returns true if the diff contains changes that may break queries
+// written against the before schema: removed tables and columns, and retyped
+// columns.
+func (d SchemaDiff) Breaking() bool {
+	return len(d.RemovedTables) > 0 || len(d.RemovedColumns) > 0 || len(d.RetypedColumns) > 0
+}
+
+// String returns a human readable description of the diff, with one line per
+// change.
+func (d SchemaDiff) String() string {
+	var buf bytes.Buffer
+	for _, name := range d.AddedTables {
+		fmt.Fprintf(&buf, "added table %s\n", name)
+	}
+	for _, name := range d.RemovedTables {
+		fmt.Fprintf(&buf, "removed table %s\n", name)
+	}
+	for _, c := range d.AddedColumns {
+		fmt.Fprintf(&buf, "added column %s.%s %s\n", c.Table, c.Column, c.NewType)
+	}
+	for _, c := range d.RemovedColumns {
+		fmt.Fprintf(&buf, "removed column %s.%s %s\n", c.Table, c.Column, c.OldType)
+	}
+	for _, c := range d.RetypedColumns {
+		fmt.Fprintf(&buf, "retyped column %s.%s from %s to %s\n", c.Table, c.Column, c.OldType, c.NewType)
+	}
+	return buf.String()
+}
+
+// schemaTables maps the tables of schema to their column types by name.
+func schemaTables(schema Schema) map[string]map[string]ColumnType {
+	tables := map[string]map[string]ColumnType{}
+	for _, table := range schema.Tables {
+		columns := map[string]ColumnType{}
+		for _, col := range table.Columns {
+			columns[col.Name] = col.Type
+		}
+		tables[table.Name] = columns
+	}
+	return tables
+}
+
+func sortedTableNames(tables map[string]map[string]ColumnType) []string {
+	var names []string
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedColumnNames(columns map[string]ColumnType) []string {
+	var names []string
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,70 @@
+package table
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaOf(t *testing.T) {
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return nil, nil
+	}
+	schema := SchemaOf(
+		NewPlugin("users", []ColumnDefinition{TextColumn("name"), BigIntColumn("uid")}, gen),
+	)
+
+	encoded, err := json.Marshal(schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"tables":[{"name":"users","columns":[{"name":"name","type":"TEXT"},{"name":"uid","type":"BIGINT"}]}]}`, string(encoded))
+
+	var decoded Schema
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, schema, decoded)
+}
+
+func TestDiffSchemas(t *testing.T) {
+	before := Schema{Tables: []TableSchema{
+		{Name: "users", Columns: []ColumnSchema{
+			{Name: "name", Type: ColumnTypeText},
+			{Name: "uid", Type: ColumnTypeInteger},
+			{Name: "shell", Type: ColumnTypeText},
+		}},
+		{Name: "groups", Columns: []ColumnSchema{{Name: "gid", Type: ColumnTypeBigInt}}},
+	}}
+	after := Schema{Tables: []TableSchema{
+		{Name: "users", Columns: []ColumnSchema{
+			{Name: "name", Type: ColumnTypeText},
+			{Name: "uid", Type: ColumnTypeBigInt},
+			{Name: "home", Type: ColumnTypeText},
+		}},
+		{Name: "devices", Columns: []ColumnSchema{{Name: "id", Type: ColumnTypeText}}},
+	}}
+
+	diff := DiffSchemas(before, after)
+	assert.Equal(t, SchemaDiff{
+		AddedTables:    []string{"devices"},
+		RemovedTables:  []string{"groups"},
+		AddedColumns:   []ColumnChange{{Table: "users", Column: "home", NewType: ColumnTypeText}},
+		RemovedColumns: []ColumnChange{{Table: "users", Column: "shell", OldType: ColumnTypeText}},
+		RetypedColumns: []ColumnChange{{Table: "users", Column: "uid", OldType: ColumnTypeInteger, NewType: ColumnTypeBigInt}},
+	}, diff)
+	assert.True(t, diff.Breaking())
+	assert.False(t, diff.Empty())
+	assert.Equal(t, `added table devices
+removed table groups
+added column users.home TEXT
+removed column users.shell TEXT
+retyped column users.uid from INTEGER to BIGINT
+`, diff.String())
+
+	// Additions only are not breaking
+	diff = DiffSchemas(Schema{}, after)
+	assert.False(t, diff.Breaking())
+	assert.Equal(t, []string{"devices", "users"}, diff.AddedTables)
+
+	assert.True(t, DiffSchemas(before, before).Empty())
+}
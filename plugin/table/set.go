@@ -0,0 +1,76 @@
+package table
+
+import (
+	"io"
+	"sync"
+)
+
+// Set is a group of tables generated from a shared backend (eg. several
+// tables served by one API client). The backend is closed once, either after
+// every table in the set has been shut down or by Close, whichever comes
+// first. If only some of the tables are registered, the others are never
+// shut down, so Close must be called once the extension has stopped.
+type Set struct {
+	backend io.Closer
+
+	mu      sync.Mutex
+	plugins []*Plugin
+	open    map[*Plugin]bool
+	closed  bool
+}
+
+// NewSet creates an empty set of tables sharing backend. The backend may be
+// nil if it does not need to be closed.
+func NewSet(backend io.Closer) *Set {
+	return &Set{backend: backend, open: map[*Plugin]bool{}}
+}
+
+// Add creates a table plugin in the set. The arguments are the same as for
+// NewPlugin.
+func (s *Set) Add(name string, columns []ColumnDefinition, gen GenerateFunc, opts ...TableOpt) *Plugin {
+	t := NewPlugin(name, columns, gen, opts...)
+	t.set = s
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plugins = append(s.plugins, t)
+	s.open[t] = true
+	return t
+}
+
+// Plugins returns the tables in the set, in the order they were added, for
+// registration with the extension manager server.
+func (s *Set) Plugins() []*Plugin {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Plugin(nil), s.plugins...)
+}
+
+// Close closes the backend, unless it was already closed, and returns the
+// error from closing it. Tables shut down afterwards do not close it again.
+func (s *Set) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	if s.backend != nil {
+		return s.backend.Close()
+	}
+	return nil
+}
+
+// release marks t as shut down, closing the backend once no tables remain
+// open.
+func (s *Set) release(t *Plugin) {
+	s.mu.Lock()
+	delete(s.open, t)
+	remaining := len(s.open)
+	s.mu.Unlock()
+	if remaining == 0 {
+		s.Close()
+	}
+}
@@ -0,0 +1,69 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingCloser struct {
+	closes int
+}
+
+func (c *countingCloser) Close() error {
+	c.closes++
+	return nil
+}
+
+func TestSet(t *testing.T) {
+	backend := &countingCloser{}
+	set := NewSet(backend)
+
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"foo": "bar"}}, nil
+	}
+	first := set.Add("first", []ColumnDefinition{TextColumn("foo")}, gen)
+	second := set.Add("second", []ColumnDefinition{TextColumn("foo")}, gen)
+	third := set.Add("third", []ColumnDefinition{TextColumn("foo")}, gen)
+
+	assert.Equal(t, []*Plugin{first, second, third}, set.Plugins())
+	assert.Equal(t, "second", second.Name())
+
+	// The backend stays open until every table is shut down
+	first.Shutdown()
+	second.Shutdown()
+	// Repeated shutdowns do not count twice
+	second.Shutdown()
+	assert.Equal(t, 0, backend.closes)
+
+	third.Shutdown()
+	assert.Equal(t, 1, backend.closes)
+
+	first.Shutdown()
+	third.Shutdown()
+	assert.Equal(t, 1, backend.closes)
+}
+
+func TestSetNilBackend(t *testing.T) {
+	set := NewSet(nil)
+	plugin := set.Add("foo", []ColumnDefinition{TextColumn("foo")}, nil)
+	assert.NotPanics(t, plugin.Shutdown)
+}
+
+func TestSetClose(t *testing.T) {
+	backend := &countingCloser{}
+	set := NewSet(backend)
+	registered := set.Add("registered", []ColumnDefinition{TextColumn("foo")}, nil)
+	set.Add("unregistered", []ColumnDefinition{TextColumn("foo")}, nil)
+
+	// A table that is not registered is never shut down, so the backend
+	// stays open until the set is closed.
+	registered.Shutdown()
+	assert.Equal(t, 0, backend.closes)
+
+	assert.NoError(t, set.Close())
+	assert.Equal(t, 1, backend.closes)
+	assert.NoError(t, set.Close())
+	assert.Equal(t, 1, backend.closes)
+}
@@ -0,0 +1,47 @@
+package table
+
+import (
+	"context"
+	"sync"
+)
+
+// SnapshotFunc returns the rows of a table created with NewSnapshotTable from
+// the state the table exposes.
+type SnapshotFunc func() []map[string]string
+
+// MutateFunc runs update with exclusive access to the state read by the
+// SnapshotFunc of a table created with NewSnapshotTable. Every write to the
+// state must be made within update.
+type MutateFunc func(update func())
+
+// NewSnapshotTable creates a table exposing mutable in-memory state, such as
+// counters or caches maintained by the extension. Each generate call reads
+// the state with snapshot, while writes are made through the returned
+// MutateFunc, so that queries see the state either before or after each
+// update, never in between.
+//
+// The rows are copied on read, while holding the lock: snapshot may return
+// the maps it keeps in its state, as they are not accessed once it returns.
+// Updates wait for the snapshots in progress, which should be quick.
+func NewSnapshotTable(name string, columns []ColumnDefinition, snapshot SnapshotFunc, opts ...TableOpt) (*Plugin, MutateFunc) {
+	var mu sync.RWMutex
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		mu.RLock()
+		defer mu.RUnlock()
+		rows := snapshot()
+		copied := make([]map[string]string, len(rows))
+		for i, row := range rows {
+			copied[i] = make(map[string]string, len(row))
+			for k, v := range row {
+				copied[i][k] = v
+			}
+		}
+		return copied, nil
+	}
+	mutate := func(update func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		update()
+	}
+	return NewPlugin(name, columns, gen, opts...), mutate
+}
@@ -0,0 +1,59 @@
+package table
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotTable(t *testing.T) {
+	// Each update increments both counters, so consistent snapshots
+	// always see the same value for them.
+	counters := []map[string]string{
+		{"name": "a", "value": "0"},
+		{"name": "b", "value": "0"},
+	}
+	plugin, mutate := NewSnapshotTable("counters",
+		[]ColumnDefinition{TextColumn("name"), IntegerColumn("value")},
+		func() []map[string]string { return counters },
+	)
+
+	const updates = 200
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= updates; i++ {
+			mutate(func() {
+				for _, row := range counters {
+					row["value"] = strconv.Itoa(i)
+				}
+			})
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+				if !assert.Equal(t, int32(0), resp.Status.Code) || !assert.Len(t, resp.Response, 2) {
+					return
+				}
+				assert.Equal(t, resp.Response[0]["value"], resp.Response[1]["value"])
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Rows are copied on read, so later updates do not modify them.
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Len(t, resp.Response, 2)
+	mutate(func() { counters[0]["value"] = "-1" })
+	assert.Equal(t, strconv.Itoa(updates), resp.Response[0]["value"])
+}
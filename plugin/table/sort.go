@@ -0,0 +1,69 @@
+package table
+
+import "sort"
+
+// WithSortedRows sorts the rows generated by the table, so that they are
+// returned in the same order for the same rows however they are generated,
+// eg. for reproducible output in tests. Rows are ordered by the values of the
+// key columns, in order, then by the values of the other columns of the
+// table, in the order they are declared, and then by the values of any other
+// columns in the rows, by column name. Values are compared as strings, and a
+// row lacking a column is ordered before the rows having it. With no key
+// columns, rows are ordered by all their columns.
+//
+// osquery orders the results of a query according to its ORDER BY clause,
+// so sorting only makes the order of the results deterministic for queries
+// without one.
+func WithSortedRows(keyColumns ...string) TableOpt {
+	return func(t *Plugin) {
+		t.sortRows = true
+		t.sortKeys = keyColumns
+	}
+}
+
+// sortRows returns the rows sorted by the key columns, then by the declared
+// columns, then by the other columns present in the rows. The rows slice is
+// copied, as generate functions may return a slice they retain.
+func sortRows(rows []map[string]string, keys []string, columns []ColumnDefinition) []map[string]string {
+	var order []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			order = append(order, key)
+		}
+	}
+	for _, col := range columns {
+		if !seen[col.Name] {
+			seen[col.Name] = true
+			order = append(order, col.Name)
+		}
+	}
+	var extra []string
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				extra = append(extra, k)
+			}
+		}
+	}
+	sort.Strings(extra)
+	order = append(order, extra...)
+
+	rows = append([]map[string]string(nil), rows...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, col := range order {
+			a, aok := rows[i][col]
+			b, bok := rows[j][col]
+			if aok != bok {
+				return !aok
+			}
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+	return rows
+}
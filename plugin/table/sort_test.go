@@ -0,0 +1,75 @@
+package table
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedRows(t *testing.T) {
+	generated := []map[string]string{
+		{"name": "nginx", "pid": "30"},
+		{"name": "bash", "pid": "4"},
+		{"name": "nginx", "pid": "12"},
+		{"name": "bash", "pid": "4", "extra": "x"},
+		{"pid": "1"},
+	}
+	columns := []ColumnDefinition{TextColumn("name"), TextColumn("pid")}
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return generated, nil
+	}
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+
+	resp := NewPlugin("processes", columns, gen, WithSortedRows()).Call(context.Background(), request)
+	require.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"pid": "1"},
+		{"name": "bash", "pid": "4"},
+		{"name": "bash", "pid": "4", "extra": "x"},
+		{"name": "nginx", "pid": "12"},
+		{"name": "nginx", "pid": "30"},
+	}, resp.Response)
+
+	resp = NewPlugin("processes", columns, gen, WithSortedRows("pid")).Call(context.Background(), request)
+	require.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"pid": "1"},
+		{"name": "nginx", "pid": "12"},
+		{"name": "nginx", "pid": "30"},
+		{"name": "bash", "pid": "4"},
+		{"name": "bash", "pid": "4", "extra": "x"},
+	}, resp.Response)
+
+	// The rows returned by the generate function are not reordered.
+	assert.Equal(t, "30", generated[0]["pid"])
+	assert.Equal(t, "1", generated[4]["pid"])
+}
+
+func TestSortedRowsDeterministic(t *testing.T) {
+	rows := map[string]map[string]string{
+		"a": {"name": "a"},
+		"b": {"name": "b"},
+		"c": {"name": "c"},
+		"d": {"name": "d"},
+	}
+	plugin := NewPlugin("letters", []ColumnDefinition{TextColumn("name")},
+		func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+			// Map iteration generates the rows in a random order.
+			var generated []map[string]string
+			for _, row := range rows {
+				generated = append(generated, row)
+			}
+			return generated, nil
+		},
+		WithSortedRows("name"),
+	)
+	for i := 0; i < 10; i++ {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		assert.Equal(t, osquery.ExtensionPluginResponse{
+			{"name": "a"}, {"name": "b"}, {"name": "c"}, {"name": "d"},
+		}, resp.Response)
+	}
+}
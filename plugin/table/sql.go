@@ -0,0 +1,106 @@
+package table
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// NewSQLTable creates a table generating its rows by running query against db.
+// The columns of the query results are matched by name with the declared
+// columns; other result columns are ignored. NULL values are omitted from the
+// rows, so that osquery also reads them as NULL, and times are returned as
+// unix epochs.
+//
+// Constraints may be pushed down to the query with params, which lists the
+// column whose equality constraint is bound to each placeholder of the query,
+// in order. A placeholder is bound to NULL when its column does not have
+// exactly one equality constraint, so the query should handle NULL as "no
+// constraint", eg.
+//
+//	NewSQLTable("users", db,
+//		"SELECT id, name FROM users WHERE name = COALESCE(?, name)",
+//		[]ColumnDefinition{IntegerColumn("id"), TextColumn("name")},
+//		[]string{"name"},
+//	)
+//
+// osquery still applies every constraint of the query to the generated rows.
+func NewSQLTable(name string, db *sql.DB, query string, columns []ColumnDefinition, params []string, opts ...TableOpt) *Plugin {
+	declared := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		declared[col.Name] = true
+	}
+
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		args := make([]interface{}, len(params))
+		pushdown := queryContext.Pushdown(params...)
+		for i, col := range params {
+			if values := pushdown.Values[col]; len(values) == 1 {
+				args[i] = values[0]
+			}
+		}
+
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("running query: %w", err)
+		}
+		defer rows.Close()
+		return scanSQLRows(rows, declared)
+	}
+	return NewPlugin(name, columns, gen, opts...)
+}
+
+// scanSQLRows returns the values of the declared columns in rows.
+func scanSQLRows(rows *sql.Rows, declared map[string]bool) ([]map[string]string, error) {
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("reading columns: %w", err)
+	}
+	values := make([]interface{}, len(names))
+	dest := make([]interface{}, len(names))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	var results []map[string]string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+		row := make(map[string]string, len(declared))
+		for i, name := range names {
+			if declared[name] && values[i] != nil {
+				row[name] = formatSQLValue(values[i])
+			}
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+	return results, nil
+}
+
+func formatSQLValue(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
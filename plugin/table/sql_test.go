@@ -0,0 +1,145 @@
+package table
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB is a database/sql driver returning fixed rows, filtered on the
+// column filter by the value of the first query argument, if not NULL. It
+// records the queries and arguments it is sent.
+type fakeDB struct {
+	columns []string
+	rows    [][]driver.Value
+	filter  string
+	err     error
+
+	queries []string
+	args    [][]driver.Value
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.args = append(s.db.args, args)
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+
+	filter := -1
+	for i, col := range s.db.columns {
+		if col == s.db.filter {
+			filter = i
+		}
+	}
+	var rows [][]driver.Value
+	for _, row := range s.db.rows {
+		if len(args) > 0 && args[0] != nil && filter >= 0 && row[filter] != args[0] {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return &fakeRows{columns: s.db.columns, rows: rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLTable(t *testing.T) {
+	fake := &fakeDB{
+		columns: []string{"id", "name", "score", "active", "created", "email", "internal"},
+		rows: [][]driver.Value{
+			{int64(1), "alice", 1.5, true, time.Unix(1600000000, 0), []byte("alice@example.com"), "x"},
+			{int64(2), "bob", 2.0, false, time.Unix(1600000100, 0), nil, "y"},
+		},
+		filter: "name",
+	}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+
+	query := "SELECT * FROM users WHERE name = COALESCE(?, name)"
+	plugin := NewSQLTable("users", db, query, []ColumnDefinition{
+		IntegerColumn("id"),
+		TextColumn("name"),
+		DoubleColumn("score"),
+		IntegerColumn("active"),
+		TimeColumn("created"),
+		TextColumn("email"),
+		// Not returned by the query
+		TextColumn("missing"),
+	}, []string{"name"})
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "1", "name": "alice", "score": "1.5", "active": "1", "created": "1600000000", "email": "alice@example.com"},
+		{"id": "2", "name": "bob", "score": "2", "active": "0", "created": "1600000100"},
+	}, resp.Response)
+	assert.Equal(t, []string{query}, fake.queries)
+	assert.Equal(t, []driver.Value{nil}, fake.args[0])
+
+	// An equality constraint is pushed down
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"name","affinity":"TEXT","list":[{"op":2,"expr":"bob"}]}]}`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	require.Len(t, resp.Response, 1)
+	assert.Equal(t, "bob", resp.Response[0]["name"])
+	assert.Equal(t, []driver.Value{"bob"}, fake.args[1])
+
+	// Several values can not be bound to a single placeholder
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"name","affinity":"TEXT","list":[{"op":2,"expr":"alice"},{"op":2,"expr":"bob"}]}]}`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Len(t, resp.Response, 2)
+	assert.Equal(t, []driver.Value{nil}, fake.args[2])
+
+	fake.err = errors.New("database is locked")
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: running query: database is locked", resp.Status.Message)
+}
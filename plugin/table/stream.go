@@ -0,0 +1,59 @@
+package table
+
+import "context"
+
+// defaultStreamBuffer is the number of rows buffered between a streaming
+// generate function and the plugin when no buffer size is configured.
+const defaultStreamBuffer = 64
+
+// StreamGenerateFunc generates the rows of a table by sending them on the
+// rows channel, rather than returning them all at once. The channel is
+// buffered (see WithStreamBuffer), and sends block while the buffer is full.
+// The buffer only limits the rows in flight between the generator and the
+// plugin: the plugin still collects every row into the response returned to
+// osquery, so streaming does not reduce the memory used by a generate call.
+//
+// The function must not close the rows channel. It should stop sending and
+// return once ctx is done. Rows sent before an error is returned are
+// discarded.
+type StreamGenerateFunc func(ctx context.Context, queryContext QueryContext, rows chan<- map[string]string) error
+
+// NewStreamingPlugin creates a table plugin generating rows with a
+// StreamGenerateFunc.
+func NewStreamingPlugin(name string, columns []ColumnDefinition, gen StreamGenerateFunc, opts ...TableOpt) *Plugin {
+	t := NewPlugin(name, columns, nil, opts...)
+	t.stream = gen
+	return t
+}
+
+// WithStreamBuffer sets the number of rows in flight between a streaming
+// generate function and the plugin, which collects them all. A size of 0
+// makes every send wait for the row to be consumed. The default is 64. It has
+// no effect on tables created with NewPlugin.
+func WithStreamBuffer(n int) TableOpt {
+	return func(t *Plugin) {
+		if n >= 0 {
+			t.streamBuffer = n
+		}
+	}
+}
+
+// streamRows runs gen, passing each row sent by it to consume, and returns
+// the error returned by gen. At most buffer rows are sent but not yet
+// consumed at any time.
+func streamRows(ctx context.Context, queryContext QueryContext, gen StreamGenerateFunc, buffer int, consume func(map[string]string)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows := make(chan map[string]string, buffer)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(rows)
+		errc <- gen(ctx, queryContext, rows)
+	}()
+
+	for row := range rows {
+		consume(row)
+	}
+	return <-errc
+}
@@ -0,0 +1,72 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingPlugin(t *testing.T) {
+	plugin := NewStreamingPlugin("stream", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, rows chan<- map[string]string) error {
+			for i := 0; i < 3; i++ {
+				rows <- map[string]string{"n": strconv.Itoa(i)}
+			}
+			return nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"n": "0"}, {"n": "1"}, {"n": "2"}}, resp.Response)
+}
+
+func TestStreamingPluginError(t *testing.T) {
+	plugin := NewStreamingPlugin("stream", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, rows chan<- map[string]string) error {
+			rows <- map[string]string{"n": "0"}
+			return errors.New("boom")
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: boom", resp.Status.Message)
+	assert.Empty(t, resp.Response)
+}
+
+func TestStreamRowsBackpressure(t *testing.T) {
+	const buffer = 4
+	const total = 50
+
+	var produced, consumed, maxAhead int64
+	gen := func(ctx context.Context, queryContext QueryContext, rows chan<- map[string]string) error {
+		for i := 0; i < total; i++ {
+			rows <- map[string]string{"n": strconv.Itoa(i)}
+			atomic.AddInt64(&produced, 1)
+		}
+		return nil
+	}
+	consume := func(row map[string]string) {
+		// Rows sent but not yet consumed. The row being consumed
+		// has left the buffer, so the producer may be one ahead.
+		ahead := atomic.LoadInt64(&produced) - atomic.LoadInt64(&consumed)
+		if ahead > maxAhead {
+			maxAhead = ahead
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt64(&consumed, 1)
+	}
+
+	err := streamRows(context.Background(), QueryContext{}, gen, buffer, consume)
+	require.NoError(t, err)
+	assert.Equal(t, int64(total), consumed)
+	assert.True(t, maxAhead <= buffer+1, "producer was %d rows ahead of the consumer", maxAhead)
+}
@@ -1,35 +1,95 @@
 // Package table creates an osquery table plugin.
+//
+// osquery calls the table to generate its rows each time it is queried. There
+// is no way for a table to notify osquery that its data changed.
+//
+// Tables are read-only. Writes (INSERT, UPDATE and DELETE statements) receive
+// the "readonly" status osquery expects from read-only tables, and fail with
+// a "table is read-only" error in osquery, while unknown actions return an
+// error status.
 package table
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"strconv"
 
 	"github.com/osquery/osquery-go/gen/osquery"
-	"github.com/pkg/errors"
 )
 
 // Generate returns the rows generated by the table. The ctx argument
 // should be checked for cancellation if the generation performs a
-// substantial amount of work. The queryContext argument provides the
-// deserialized JSON query context from osquery.
+// substantial amount of work. When the extension is configured with a call
+// timeout, ctx.Deadline() reports when the call times out so that the work
+// can be budgeted; otherwise ctx has no deadline. The queryContext argument
+// provides the deserialized JSON query context from osquery.
 type GenerateFunc func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error)
 
+// PingFunc returns the current health of the table. It is evaluated each time
+// the table is pinged.
+type PingFunc func() osquery.ExtensionStatus
+
 type Plugin struct {
 	name     string
 	columns  []ColumnDefinition
 	generate GenerateFunc
+	ping     PingFunc
+	shutdown func()
+	set      *Set
+
+	stream       StreamGenerateFunc
+	streamBuffer int
+
+	maxRows int
+
+	attributes TableAttribute
+
+	dedup bool
+
+	sortRows bool
+	sortKeys []string
 }
 
-func NewPlugin(name string, columns []ColumnDefinition, gen GenerateFunc) *Plugin {
-	return &Plugin{
-		name:     name,
-		columns:  columns,
-		generate: gen,
+// TableOpt allows for setting optional settings on a Plugin.
+type TableOpt func(*Plugin)
+
+// WithPing sets the function used to report the health of the table. The
+// function is called on every Ping, allowing a table to report an outage of
+// its backend and later recover. By default the table always reports OK.
+func WithPing(fn PingFunc) TableOpt {
+	return func(t *Plugin) {
+		t.ping = fn
 	}
 }
 
+// WithShutdown sets a function called when the table is shut down, once the
+// extension serving it stops (eg. when osquery asks the extension to shut
+// down), to release the resources held by the table.
+func WithShutdown(fn func()) TableOpt {
+	return func(t *Plugin) {
+		t.shutdown = fn
+	}
+}
+
+// NewPlugin creates a table plugin. It panics if a column alias collides with
+// the name or alias of another column. The columns are copied, so modifying
+// the slice afterwards does not change the table.
+func NewPlugin(name string, columns []ColumnDefinition, gen GenerateFunc, opts ...TableOpt) *Plugin {
+	if err := validateAliases(columns); err != nil {
+		panic("table " + name + ": " + err.Error())
+	}
+	t := &Plugin{
+		name:         name,
+		columns:      append([]ColumnDefinition(nil), columns...),
+		generate:     gen,
+		streamBuffer: defaultStreamBuffer,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
 func (t *Plugin) Name() string {
 	return t.name
 }
@@ -38,15 +98,38 @@ func (t *Plugin) RegistryName() string {
 	return "table"
 }
 
+// Routes returns the schema of the table sent to osquery: a route per column,
+// in the order of the columns passed to NewPlugin, which is the order of the
+// columns of the table in osquery (eg. for SELECT *), followed by the column
+// aliases and the table attributes.
 func (t *Plugin) Routes() osquery.ExtensionPluginResponse {
 	routes := []map[string]string{}
 	for _, col := range t.columns {
-		routes = append(routes, map[string]string{
-			"id":   "column",
-			"name": col.Name,
-			"type": string(col.Type),
-			"op":   "0",
-		})
+		route := map[string]string{}
+		for k, v := range col.Attributes {
+			route[k] = v
+		}
+		route["id"] = "column"
+		route["name"] = col.Name
+		route["type"] = string(col.Type)
+		op := 0
+		if col.Required {
+			op |= columnOptionRequired
+		}
+		route["op"] = strconv.Itoa(op)
+		routes = append(routes, route)
+	}
+	for _, col := range t.columns {
+		for _, alias := range col.Aliases {
+			routes = append(routes, map[string]string{
+				"id":     "columnAlias",
+				"name":   alias,
+				"target": col.Name,
+			})
+		}
+	}
+	if route := t.attributesRoute(); route != nil {
+		routes = append(routes, route)
 	}
 	return routes
 }
@@ -65,7 +148,29 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			}
 		}
 
-		rows, err := t.generate(ctx, *queryContext)
+		if col, missing := missingRequired(t.columns, *queryContext); missing {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "query must constrain the required column " + col,
+				},
+			}
+		}
+
+		var rows []map[string]string
+		if t.stream != nil {
+			var set rowSet
+			err = streamRows(ctx, *queryContext, t.stream, t.streamBuffer, func(row map[string]string) {
+				if !t.dedup || set.add(row) {
+					rows = append(rows, row)
+				}
+			})
+		} else {
+			rows, err = t.generate(ctx, *queryContext)
+			if err == nil && t.dedup {
+				rows = dedupRows(rows)
+			}
+		}
 		if err != nil {
 			return osquery.ExtensionResponse{
 				Status: &osquery.ExtensionStatus{
@@ -74,6 +179,9 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 				},
 			}
 		}
+		if t.sortRows {
+			rows = sortRows(rows, t.sortKeys, t.columns)
+		}
 
 		return osquery.ExtensionResponse{
 			Status:   &ok,
@@ -86,6 +194,22 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 			Response: t.Routes(),
 		}
 
+	case "insert", "update", "delete":
+		// osquery expects the outcome of writes in the response rather
+		// than in the status.
+		return osquery.ExtensionResponse{
+			Status:   &ok,
+			Response: osquery.ExtensionPluginResponse{{"status": "readonly"}},
+		}
+
+	case "":
+		return osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{
+				Code:    1,
+				Message: "missing 'action' in request",
+			},
+		}
+
 	default:
 		return osquery.ExtensionResponse{
 			Status: &osquery.ExtensionStatus{
@@ -98,10 +222,23 @@ func (t *Plugin) Call(ctx context.Context, request osquery.ExtensionPluginReques
 }
 
 func (t *Plugin) Ping() osquery.ExtensionStatus {
+	if t.ping != nil {
+		return t.ping()
+	}
 	return osquery.ExtensionStatus{Code: 0, Message: "OK"}
 }
 
-func (t *Plugin) Shutdown() {}
+// Shutdown alerts the table to stop, calling the function set with
+// WithShutdown, if any. Tables created by a Set release the shared backend,
+// which is closed once every table in the set is shut down.
+func (t *Plugin) Shutdown() {
+	if t.shutdown != nil {
+		t.shutdown()
+	}
+	if t.set != nil {
+		t.set.release(t)
+	}
+}
 
 // ColumnDefinition defines the relevant information for a column in a table
 // plugin. Both values are mandatory. Prefer using the *Column helpers to
@@ -109,6 +246,58 @@ func (t *Plugin) Shutdown() {}
 type ColumnDefinition struct {
 	Name string
 	Type ColumnType
+
+	// Attributes are additional key/value pairs sent to osquery in the
+	// route for the column, for attributes not otherwise supported by
+	// ColumnDefinition. They are passed through unchanged, except that the
+	// "id", "name", "type" and "op" keys are always set from the column
+	// definition.
+	Attributes map[string]string
+
+	// Aliases are additional names the column can be referenced by, eg.
+	// its previous name after a rename. Aliases must not collide with
+	// the name or alias of another column.
+	Aliases []string
+
+	// Required marks a column that queries must constrain, eg. the path
+	// of the file a table reads. It is sent to osquery as the REQUIRED
+	// column option. osquery normally rejects queries without such a
+	// constraint, but as a safeguard the generate function is not called
+	// for them, and the call returns an error status instead.
+	Required bool
+}
+
+// columnOptionRequired is the REQUIRED option of osquery ColumnOptions, sent in
+// the "op" key of the column routes.
+const columnOptionRequired = 2
+
+// missingRequired returns the first required column without constraints in
+// queryContext, if any.
+func missingRequired(columns []ColumnDefinition, queryContext QueryContext) (string, bool) {
+	for _, col := range columns {
+		if col.Required && len(queryContext.Constraints[col.Name].Constraints) == 0 {
+			return col.Name, true
+		}
+	}
+	return "", false
+}
+
+// validateAliases returns an error if a column alias collides with the name or
+// alias of another column.
+func validateAliases(columns []ColumnDefinition) error {
+	names := map[string]bool{}
+	for _, col := range columns {
+		names[col.Name] = true
+	}
+	for _, col := range columns {
+		for _, alias := range col.Aliases {
+			if names[alias] {
+				return fmt.Errorf("alias %q of column %q collides with another column", alias, col.Name)
+			}
+			names[alias] = true
+		}
+	}
+	return nil
 }
 
 // TextColumn is a helper for defining columns containing strings.
@@ -144,8 +333,34 @@ func DoubleColumn(name string) ColumnDefinition {
 	}
 }
 
+// TimeColumn is a helper for defining columns containing timestamps, as unix
+// epochs. Use RowBuilder.SetTime to set them.
+func TimeColumn(name string) ColumnDefinition {
+	return BigIntColumn(name)
+}
+
+// BooleanColumn is a helper for defining columns containing booleans. osquery
+// represents booleans as INTEGER columns holding 1 or 0, rather than "true"
+// or "false", which SQLite would not compare as booleans. Use
+// RowBuilder.SetBool to set them.
+func BooleanColumn(name string) ColumnDefinition {
+	return IntegerColumn(name)
+}
+
+// BlobColumn is a helper for defining columns containing binary data. osquery
+// hands the value of a BLOB column to SQLite as raw bytes, so values must not
+// be base64 or hex encoded. Use RowBuilder.SetBlob to set them.
+func BlobColumn(name string) ColumnDefinition {
+	return ColumnDefinition{
+		Name: name,
+		Type: ColumnTypeBlob,
+	}
+}
+
 // ColumnType is a strongly typed representation of the data type string for a
-// column definition. The named constants should be used.
+// column definition. The named constants should be used, but other types (eg.
+// introduced by a newer osquery version) are passed through to osquery
+// unchanged.
 type ColumnType string
 
 // The following column types are defined in osquery tables.h.
@@ -154,8 +369,26 @@ const (
 	ColumnTypeInteger            = "INTEGER"
 	ColumnTypeBigInt             = "BIGINT"
 	ColumnTypeDouble             = "DOUBLE"
+	ColumnTypeBlob               = "BLOB"
 )
 
+// Valid returns true if the type may be sent to osquery, which is the case for
+// any non-empty type.
+func (t ColumnType) Valid() bool {
+	return t != ""
+}
+
+// Known returns true if the type is one of the ColumnType constants. Unknown
+// types are valid, but osquery versions not supporting them may fail to
+// create the table.
+func (t ColumnType) Known() bool {
+	switch t {
+	case ColumnTypeText, ColumnTypeInteger, ColumnTypeBigInt, ColumnTypeDouble, ColumnTypeBlob:
+		return true
+	}
+	return false
+}
+
 // QueryContext contains the constraints from the WHERE clause of the query,
 // that can optionally be used to optimize the table generation. Note that the
 // osquery SQLite engine will perform the filtering with these constraints, so
@@ -195,83 +428,3 @@ const (
 	OperatorRegexp                       = 67
 	OperatorUnique                       = 1
 )
-
-// The following types and functions exist for parsing of the queryContext
-// JSON and are not made public.
-type queryContextJSON struct {
-	Constraints []constraintListJSON `json:"constraints"`
-}
-
-type constraintListJSON struct {
-	Name     string          `json:"name"`
-	Affinity string          `json:"affinity"`
-	List     json.RawMessage `json:"list"`
-}
-
-func parseQueryContext(ctxJSON string) (*QueryContext, error) {
-	var parsed queryContextJSON
-
-	err := json.Unmarshal([]byte(ctxJSON), &parsed)
-	if err != nil {
-		return nil, errors.Wrap(err, "unmarshaling context JSON")
-	}
-
-	ctx := QueryContext{map[string]ConstraintList{}}
-	for _, cList := range parsed.Constraints {
-		constraints, err := parseConstraintList(cList.List)
-		if err != nil {
-			return nil, err
-		}
-
-		ctx.Constraints[cList.Name] = ConstraintList{
-			Affinity:    ColumnType(cList.Affinity),
-			Constraints: constraints,
-		}
-	}
-
-	return &ctx, nil
-}
-
-func parseConstraintList(constraints json.RawMessage) ([]Constraint, error) {
-	var str string
-	err := json.Unmarshal(constraints, &str)
-	if err == nil {
-		// string indicates empty list
-		return []Constraint{}, nil
-	}
-
-	var cList []map[string]interface{}
-	err = json.Unmarshal(constraints, &cList)
-	if err != nil {
-		// cannot do anything with other types
-		return nil, errors.Errorf("unexpected context list: %s", string(constraints))
-	}
-
-	cl := []Constraint{}
-	for _, c := range cList {
-		var op Operator
-		switch opVal := c["op"].(type) {
-		case string: // osquery < 3.0 with stringy types
-			opInt, err := strconv.Atoi(opVal)
-			if err != nil {
-				return nil, errors.Errorf("parsing operator int: %s", c["op"])
-			}
-			op = Operator(opInt)
-		case float64: // osquery > 3.0 with strong types
-			op = Operator(opVal)
-		default:
-			return nil, errors.Errorf("cannot parse type %T", opVal)
-		}
-
-		expr, ok := c["expr"].(string)
-		if !ok {
-			return nil, errors.Errorf("expr should be string: %s", c["expr"])
-		}
-
-		cl = append(cl, Constraint{
-			Operator:   op,
-			Expression: expr,
-		})
-	}
-	return cl, nil
-}
@@ -90,6 +90,13 @@ func TestTablePluginErrors(t *testing.T) {
 	assert.False(t, called)
 	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "bad"}).Status.Code)
 	assert.False(t, called)
+	for _, request := range []osquery.ExtensionPluginRequest{nil, {}, {"action": ""}, {"context": "{}"}} {
+		resp := plugin.Call(context.Background(), request)
+		assert.Equal(t, int32(1), resp.Status.Code)
+		assert.Equal(t, "missing 'action' in request", resp.Status.Message)
+	}
+	assert.Equal(t, "unknown action: bad", plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "bad"}).Status.Message)
+	assert.False(t, called)
 
 	// Call with good action but generate fails
 	assert.Equal(t, int32(1), plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{[]}"}).Status.Code)
@@ -103,6 +110,73 @@ func TestTablePluginErrors(t *testing.T) {
 
 }
 
+func TestTablePluginRoutesOrder(t *testing.T) {
+	renamed := TextColumn("zone")
+	renamed.Aliases = []string{"region", "area"}
+	columns := []ColumnDefinition{
+		TextColumn("zebra"),
+		BigIntColumn("alpha"),
+		renamed,
+		DoubleColumn("mango"),
+		IntegerColumn("apple"),
+		BlobColumn("b"),
+		TextColumn("a"),
+	}
+	plugin := NewPlugin("mock", columns, nil)
+
+	// Modifying the columns passed does not reorder the table.
+	columns[0], columns[1] = columns[1], columns[0]
+
+	expected := []string{"zebra", "alpha", "zone", "mango", "apple", "b", "a"}
+	for i := 0; i < 10; i++ {
+		var names, aliases []string
+		for _, route := range plugin.Routes() {
+			switch route["id"] {
+			case "column":
+				names = append(names, route["name"])
+			case "columnAlias":
+				aliases = append(aliases, route["name"])
+			}
+		}
+		assert.Equal(t, expected, names)
+		assert.Equal(t, []string{"region", "area"}, aliases)
+	}
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "columns"})
+	assert.Equal(t, plugin.Routes(), resp.Response)
+}
+
+func TestTablePluginShutdown(t *testing.T) {
+	shutdowns := 0
+	plugin := NewPlugin("mock", []ColumnDefinition{TextColumn("text")}, nil, WithShutdown(func() { shutdowns++ }))
+	plugin.Shutdown()
+	assert.Equal(t, 1, shutdowns)
+
+	// Shutting down a table without a shutdown function does nothing.
+	NewPlugin("mock", []ColumnDefinition{TextColumn("text")}, nil).Shutdown()
+}
+
+func TestTablePluginReadOnly(t *testing.T) {
+	var called bool
+	plugin := NewPlugin("mock", []ColumnDefinition{TextColumn("text")},
+		func(ctx context.Context, queryCtx QueryContext) ([]map[string]string, error) {
+			called = true
+			return nil, nil
+		},
+	)
+
+	for _, action := range []string{"insert", "update", "delete"} {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+			"action":           action,
+			"json_value_array": `["foo"]`,
+			"id":               "1",
+		})
+		assert.Equal(t, &osquery.ExtensionStatus{Code: 0, Message: "OK"}, resp.Status, action)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"status": "readonly"}}, resp.Response, action)
+	}
+	assert.False(t, called)
+}
+
 func TestParseConstraintList(t *testing.T) {
 	var testCases = []struct {
 		json        string
@@ -318,3 +392,125 @@ func TestParseVaryingQueryContexts(t *testing.T) {
 		})
 	}
 }
+
+func TestTablePluginPing(t *testing.T) {
+	healthy := true
+	plugin := NewPlugin(
+		"mock",
+		[]ColumnDefinition{TextColumn("text")},
+		func(ctx context.Context, queryCtx QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		},
+		WithPing(func() osquery.ExtensionStatus {
+			if healthy {
+				return osquery.ExtensionStatus{Code: 0, Message: "OK"}
+			}
+			return osquery.ExtensionStatus{Code: 1, Message: "backend unavailable"}
+		}),
+	)
+
+	assert.Equal(t, int32(0), plugin.Ping().Code)
+	healthy = false
+	assert.Equal(t, osquery.ExtensionStatus{Code: 1, Message: "backend unavailable"}, plugin.Ping())
+	healthy = true
+	assert.Equal(t, int32(0), plugin.Ping().Code)
+}
+
+func TestTablePluginColumnAttributes(t *testing.T) {
+	col := TextColumn("path")
+	col.Attributes = map[string]string{
+		"collate": "nocase",
+		"name":    "ignored",
+		"op":      "ignored",
+	}
+	plugin := NewPlugin("files", []ColumnDefinition{col, IntegerColumn("size")}, nil)
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "path", "type": "TEXT", "op": "0", "collate": "nocase"},
+		{"id": "column", "name": "size", "type": "INTEGER", "op": "0"},
+	}, plugin.Routes())
+}
+
+func TestTablePluginColumnAliases(t *testing.T) {
+	renamed := TextColumn("username")
+	renamed.Aliases = []string{"user", "login"}
+	plugin := NewPlugin("users", []ColumnDefinition{renamed, BigIntColumn("uid")}, nil)
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "username", "type": "TEXT", "op": "0"},
+		{"id": "column", "name": "uid", "type": "BIGINT", "op": "0"},
+		{"id": "columnAlias", "name": "user", "target": "username"},
+		{"id": "columnAlias", "name": "login", "target": "username"},
+	}, plugin.Routes())
+
+	collides := TextColumn("username")
+	collides.Aliases = []string{"uid"}
+	assert.Panics(t, func() {
+		NewPlugin("users", []ColumnDefinition{collides, BigIntColumn("uid")}, nil)
+	})
+
+	first, second := TextColumn("a"), TextColumn("b")
+	first.Aliases = []string{"c"}
+	second.Aliases = []string{"c"}
+	assert.Panics(t, func() {
+		NewPlugin("users", []ColumnDefinition{first, second}, nil)
+	})
+}
+
+func TestTablePluginUnknownColumnType(t *testing.T) {
+	custom := ColumnType("UNSIGNED BIGINT")
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"size": "18446744073709551615"}}, nil
+	}
+	plugin := NewPlugin("mock", []ColumnDefinition{{Name: "size", Type: custom}}, gen)
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "size", "type": "UNSIGNED BIGINT", "op": "0"},
+	}, plugin.Routes())
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(0), resp.Status.Code)
+
+	assert.True(t, custom.Valid())
+	assert.False(t, custom.Known())
+	for _, typ := range []ColumnType{ColumnTypeText, ColumnTypeInteger, ColumnTypeBigInt, ColumnTypeDouble, ColumnTypeBlob} {
+		assert.True(t, typ.Valid())
+		assert.True(t, typ.Known())
+	}
+	assert.False(t, ColumnType("").Valid())
+	assert.False(t, ColumnType("").Known())
+}
+
+func TestTablePluginRequiredColumns(t *testing.T) {
+	var called bool
+	gen := func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		called = true
+		return []map[string]string{{"path": "/etc/hosts", "contents": "127.0.0.1 localhost"}}, nil
+	}
+	plugin := NewPlugin("file_contents", []ColumnDefinition{
+		{Name: "path", Type: ColumnTypeText, Required: true},
+		TextColumn("contents"),
+	}, gen)
+
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"id": "column", "name": "path", "type": "TEXT", "op": "2"},
+		{"id": "column", "name": "contents", "type": "TEXT", "op": "0"},
+	}, plugin.Routes())
+
+	for _, ctxJSON := range []string{
+		`{}`,
+		`{"constraints":[{"name":"path","affinity":"TEXT","list":""}]}`,
+		`{"constraints":[{"name":"contents","affinity":"TEXT","list":[{"op":2,"expr":"x"}]}]}`,
+	} {
+		resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": ctxJSON})
+		assert.Equal(t, int32(1), resp.Status.Code)
+		assert.Equal(t, "query must constrain the required column path", resp.Status.Message)
+		assert.False(t, called)
+	}
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"path","affinity":"TEXT","list":[{"op":2,"expr":"/etc/hosts"}]}]}`,
+	})
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.True(t, called)
+}
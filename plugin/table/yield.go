@@ -0,0 +1,36 @@
+package table
+
+import "context"
+
+// YieldGenerateFunc generates the rows of a table by passing them one at a
+// time to yield. yield returns an error once ctx is done, which the function
+// should return to stop generating. Rows yielded before an error is returned
+// are discarded.
+type YieldGenerateFunc func(ctx context.Context, queryContext QueryContext, yield func(row map[string]string) error) error
+
+// NewYieldingPlugin creates a table plugin generating rows with a
+// YieldGenerateFunc.
+//
+// The osquery extension protocol does not support sending rows to osquery as
+// they are produced: the rows of a generate call are returned in a single
+// response, in every osquery version. The rows yielded are therefore buffered
+// until the function returns, and osquery receives the first row no earlier
+// than with NewPlugin. Yielding rows still avoids building the rows slice in
+// the generate function, and stops the generation as soon as the call is
+// canceled.
+func NewYieldingPlugin(name string, columns []ColumnDefinition, gen YieldGenerateFunc, opts ...TableOpt) *Plugin {
+	return NewPlugin(name, columns, func(ctx context.Context, queryContext QueryContext) ([]map[string]string, error) {
+		var rows []map[string]string
+		err := gen(ctx, queryContext, func(row map[string]string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}, opts...)
+}
@@ -0,0 +1,70 @@
+package table
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestYieldingPlugin(t *testing.T) {
+	plugin := NewYieldingPlugin("yield", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, yield func(map[string]string) error) error {
+			for i := 0; i < 3; i++ {
+				if err := yield(map[string]string{"n": strconv.Itoa(i)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"n": "0"}, {"n": "1"}, {"n": "2"}}, resp.Response)
+}
+
+func TestYieldingPluginError(t *testing.T) {
+	plugin := NewYieldingPlugin("yield", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, yield func(map[string]string) error) error {
+			if err := yield(map[string]string{"n": "0"}); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: boom", resp.Status.Message)
+	assert.Empty(t, resp.Response)
+}
+
+func TestYieldingPluginCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	yielded := 0
+	plugin := NewYieldingPlugin("yield", []ColumnDefinition{TextColumn("n")},
+		func(ctx context.Context, queryContext QueryContext, yield func(map[string]string) error) error {
+			for i := 0; ; i++ {
+				if i == 2 {
+					cancel()
+				}
+				if err := yield(map[string]string{"n": strconv.Itoa(i)}); err != nil {
+					return err
+				}
+				yielded++
+			}
+		},
+	)
+
+	resp := plugin.Call(ctx, osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "error generating table: "+context.Canceled.Error(), resp.Status.Message)
+	assert.Equal(t, 2, yielded)
+}
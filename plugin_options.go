@@ -0,0 +1,50 @@
+package osquery
+
+import "time"
+
+// PluginOption configures how the server dispatches calls to a single
+// registered plugin. Options are set with RegisterPluginWithOptions, so the
+// same plugin type can be configured differently in each extension.
+type PluginOption func(*pluginConfig)
+
+// pluginConfig contains the per-plugin settings.
+type pluginConfig struct {
+	timeout         time.Duration
+	rowTransformers []RowTransformer
+}
+
+// WithPluginTimeout sets the timeout for calls to the plugin, overriding the
+// server wide timeout set with WithCallTimeout.
+func WithPluginTimeout(timeout time.Duration) PluginOption {
+	return func(c *pluginConfig) {
+		c.timeout = timeout
+	}
+}
+
+// RegisterPluginWithOptions adds an OsqueryPlugin to this extension manager,
+// configured with the provided options.
+func (s *ExtensionManagerServer) RegisterPluginWithOptions(plugin OsqueryPlugin, opts ...PluginOption) {
+	var config pluginConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.registerPlugin(plugin, &config)
+}
+
+// pluginConfigFor returns the options of the plugin. It must be called with
+// s.mutex held, as plugins may be registered concurrently with calls.
+func (s *ExtensionManagerServer) pluginConfigFor(registry, item string) pluginConfig {
+	return s.pluginConfigs[registry][item]
+}
+
+// callTimeoutFor returns the timeout for calls to a plugin with the config,
+// or zero if there is no timeout.
+func (s *ExtensionManagerServer) callTimeoutFor(config pluginConfig) time.Duration {
+	if config.timeout > 0 {
+		return config.timeout
+	}
+	return s.callTimeout
+}
@@ -0,0 +1,102 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginTimeout(t *testing.T) {
+	deadlines := map[string]time.Duration{}
+	newTable := func(name string) *table.Plugin {
+		return table.NewPlugin(name, []table.ColumnDefinition{table.TextColumn("foo")},
+			func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+				if deadline, ok := ctx.Deadline(); ok {
+					deadlines[name] = time.Until(deadline)
+				}
+				return nil, nil
+			},
+		)
+	}
+
+	server := newTestServer(newTable("default"), WithCallTimeout(time.Hour))
+	server.RegisterPluginWithOptions(newTable("short"), WithPluginTimeout(time.Minute))
+	server.RegisterPluginWithOptions(newTable("long"), WithPluginTimeout(2*time.Hour))
+	server.RegisterPluginWithOptions(newTable("unset"))
+
+	for _, name := range []string{"default", "short", "long", "unset"} {
+		_, err := server.Call(context.Background(), "table", name, osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+	}
+
+	assert.InDelta(t, time.Hour, deadlines["default"], float64(time.Second))
+	assert.InDelta(t, time.Minute, deadlines["short"], float64(time.Second))
+	assert.InDelta(t, 2*time.Hour, deadlines["long"], float64(time.Second))
+	assert.InDelta(t, time.Hour, deadlines["unset"], float64(time.Second))
+
+	// Registering again without options clears them
+	server.RegisterPlugin(newTable("short"))
+	_, err := server.Call(context.Background(), "table", "short", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, deadlines["short"], float64(time.Second))
+}
+
+func TestPluginOptionsConcurrentRegistration(t *testing.T) {
+	server := newTestServer(newTestTable("users"), WithCallTimeout(time.Hour))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			server.RegisterPluginWithOptions(newTestTable("users"), WithPluginTimeout(time.Minute))
+			server.RegisterPlugin(newTestTable("users"))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := server.Call(context.Background(), "table", "users", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+	}
+	<-done
+}
+
+func TestPluginOptionsPublishedWithPlugin(t *testing.T) {
+	server := newTestServer(newTestTable("other"))
+	deadlines := make(chan bool, 2)
+	plugin := table.NewPlugin("users", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			_, ok := ctx.Deadline()
+			deadlines <- ok
+			return nil, nil
+		})
+
+	// Block the registration while its options are applied.
+	applying, release := make(chan struct{}), make(chan struct{})
+	blocking := func(c *pluginConfig) {
+		close(applying)
+		<-release
+	}
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		server.RegisterPluginWithOptions(plugin, WithPluginTimeout(time.Minute), blocking)
+	}()
+	<-applying
+
+	// The plugin is not called before its options are set.
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+	resp, err := server.Call(context.Background(), "table", "users", request)
+	require.NoError(t, err)
+	assert.Equal(t, "Unknown registry item: users", resp.Status.Message)
+
+	close(release)
+	<-registered
+	resp, err = server.Call(context.Background(), "table", "users", request)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.True(t, <-deadlines)
+}
@@ -0,0 +1,230 @@
+package osquery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/transport"
+)
+
+// poolConn is a single connection to osquery along with the Thrift client
+// using it.
+type poolConn struct {
+	client    osquery.ExtensionManager
+	transport thrift.TTransport
+}
+
+// connPool manages the connections used by an ExtensionManagerClient. A
+// connection is only ever used by one caller at a time, as the Thrift
+// clients are not safe for concurrent use.
+type connPool struct {
+	path    string
+	timeout time.Duration
+	// maxMessageSize limits the size of the responses read, if positive.
+	maxMessageSize int64
+
+	// slots limits the number of checked out connections to the maximum
+	// pool size.
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*poolConn
+	closed bool
+
+	// dial opens a new connection. It is a field to allow tests to
+	// replace it.
+	dial func() (*poolConn, error)
+}
+
+func newConnPool(path string, timeout time.Duration, maxConns int) *connPool {
+	p := &connPool{
+		path:    path,
+		timeout: timeout,
+		slots:   make(chan struct{}, maxConns),
+	}
+	p.dial = p.dialSocket
+	return p
+}
+
+func (p *connPool) dialSocket() (*poolConn, error) {
+	trans, err := transport.Open(p.path, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client := osquery.NewExtensionManagerClientFactory(
+		trans,
+		protocolFactory(p.maxMessageSize),
+	)
+
+	return &poolConn{client: client, transport: trans}, nil
+}
+
+// get checks out a connection, blocking while the maximum number of
+// connections are in use. An idle connection is reused if available,
+// otherwise a new one is opened.
+func (p *connPool) get() (*poolConn, error) {
+	p.slots <- struct{}{}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if conn.transport.IsOpen() {
+			p.mu.Unlock()
+			return conn, nil
+		}
+		conn.transport.Close()
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool. The error returned by the call made
+// on the connection is used to determine whether the connection is still
+// healthy. Connections are discarded after any error other than an unknown
+// method reported by osquery, as the state of the stream is unknown. Other
+// application exceptions, such as a bad sequence ID or a missing result, may
+// leave unread or mismatched messages on the stream.
+func (p *connPool) put(conn *poolConn, callErr error) {
+	defer func() { <-p.slots }()
+
+	healthy := callErr == nil
+	if appErr, ok := callErr.(thrift.TApplicationException); ok && appErr.TypeId() == thrift.UNKNOWN_METHOD {
+		healthy = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || !healthy || !conn.transport.IsOpen() {
+		conn.transport.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+}
+
+// close closes all idle connections. Connections that are checked out are
+// closed when they are returned. The first error closing a connection is
+// returned.
+func (p *connPool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var err error
+	for _, conn := range p.idle {
+		if conn.transport.IsOpen() {
+			if closeErr := conn.transport.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+	}
+	p.idle = nil
+	return err
+}
+
+// pooledClient implements the Thrift ExtensionManager interface by
+// dispatching each call over a connection checked out from the pool, so that
+// it is safe for concurrent use and survives the replacement of broken
+// connections.
+type pooledClient struct {
+	pool *connPool
+}
+
+var _ osquery.ExtensionManager = pooledClient{}
+
+// do runs fn with a client checked out from the pool, returning the
+// connection to the pool afterwards.
+func (c pooledClient) do(fn func(client osquery.ExtensionManager) error) error {
+	conn, err := c.pool.get()
+	if err != nil {
+		return err
+	}
+	err = fn(conn.client)
+	c.pool.put(conn, err)
+	return err
+}
+
+func (c pooledClient) Ping(ctx context.Context) (r *osquery.ExtensionStatus, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.Ping(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) Call(ctx context.Context, registry string, item string, request osquery.ExtensionPluginRequest) (r *osquery.ExtensionResponse, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.Call(ctx, registry, item, request)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) Shutdown(ctx context.Context) error {
+	return c.do(func(client osquery.ExtensionManager) error {
+		return client.Shutdown(ctx)
+	})
+}
+
+func (c pooledClient) Extensions(ctx context.Context) (r osquery.InternalExtensionList, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.Extensions(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) Options(ctx context.Context) (r osquery.InternalOptionList, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.Options(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) RegisterExtension(ctx context.Context, info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (r *osquery.ExtensionStatus, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.RegisterExtension(ctx, info, registry)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) DeregisterExtension(ctx context.Context, uuid osquery.ExtensionRouteUUID) (r *osquery.ExtensionStatus, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.DeregisterExtension(ctx, uuid)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) Query(ctx context.Context, sql string) (r *osquery.ExtensionResponse, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.Query(ctx, sql)
+		return err
+	})
+	return r, err
+}
+
+func (c pooledClient) GetQueryColumns(ctx context.Context, sql string) (r *osquery.ExtensionResponse, err error) {
+	err = c.do(func(client osquery.ExtensionManager) (err error) {
+		r, err = client.GetQueryColumns(ctx, sql)
+		return err
+	})
+	return r, err
+}
@@ -0,0 +1,317 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/osquery/osquery-go/transport"
+)
+
+type fakeTransport struct {
+	*thrift.TMemoryBuffer
+	closed bool
+}
+
+func (f *fakeTransport) IsOpen() bool { return !f.closed }
+func (f *fakeTransport) Close() error {
+	f.closed = true
+	return nil
+}
+
+func newFakePool(maxConns int) (*connPool, *int) {
+	dials := 0
+	pool := newConnPool("", 0, maxConns)
+	pool.dial = func() (*poolConn, error) {
+		dials++
+		return &poolConn{
+			client:    &mock.ExtensionManager{},
+			transport: &fakeTransport{TMemoryBuffer: thrift.NewTMemoryBuffer()},
+		}, nil
+	}
+	return pool, &dials
+}
+
+func TestConnPoolReuse(t *testing.T) {
+	pool, dials := newFakePool(2)
+
+	conn, err := pool.get()
+	require.NoError(t, err)
+	pool.put(conn, nil)
+
+	reused, err := pool.get()
+	require.NoError(t, err)
+	assert.True(t, conn == reused)
+	assert.Equal(t, 1, *dials)
+
+	// A second concurrent checkout opens a new connection
+	second, err := pool.get()
+	require.NoError(t, err)
+	assert.False(t, conn == second)
+	assert.Equal(t, 2, *dials)
+
+	pool.put(reused, nil)
+	pool.put(second, nil)
+}
+
+func TestConnPoolDiscardsBrokenConns(t *testing.T) {
+	pool, dials := newFakePool(1)
+
+	// Unknown methods leave the connection usable
+	conn, err := pool.get()
+	require.NoError(t, err)
+	pool.put(conn, thrift.NewTApplicationException(thrift.UNKNOWN_METHOD, "unknown"))
+	assert.False(t, conn.transport.(*fakeTransport).closed)
+
+	// Other errors leave the stream in an unknown state
+	for i, callErr := range []error{
+		errors.New("broken pipe"),
+		thrift.NewTApplicationException(thrift.BAD_SEQUENCE_ID, "out of order"),
+		thrift.NewTApplicationException(thrift.MISSING_RESULT, "no result"),
+		thrift.NewTApplicationException(thrift.PROTOCOL_ERROR, "bad message"),
+	} {
+		broken, err := pool.get()
+		require.NoError(t, err)
+		assert.Equal(t, i+1, *dials)
+		pool.put(broken, callErr)
+		assert.True(t, broken.transport.(*fakeTransport).closed, callErr.Error())
+	}
+
+	replacement, err := pool.get()
+	require.NoError(t, err)
+	assert.Equal(t, 5, *dials)
+	pool.put(replacement, nil)
+}
+
+func TestConnPoolBlocksAtMax(t *testing.T) {
+	pool, _ := newFakePool(1)
+
+	conn, err := pool.get()
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		c, err := pool.get()
+		require.NoError(t, err)
+		pool.put(c, nil)
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("checkout should block while the pool is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.put(conn, errors.New("broken pipe"))
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("checkout did not unblock after connection was returned")
+	}
+}
+
+func TestConnPoolClose(t *testing.T) {
+	pool, _ := newFakePool(1)
+
+	conn, err := pool.get()
+	require.NoError(t, err)
+	pool.put(conn, nil)
+
+	pool.close()
+	assert.True(t, conn.transport.(*fakeTransport).closed)
+	_, err = pool.get()
+	assert.Error(t, err)
+}
+
+// slowManager is an osquery extension manager with a Query that takes a fixed
+// amount of time.
+type slowManager struct {
+	mock.ExtensionManager
+	delay time.Duration
+}
+
+func (m *slowManager) Query(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	time.Sleep(m.delay)
+	return &osquery.ExtensionResponse{
+		Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: []map[string]string{{"sql": sql}},
+	}, nil
+}
+
+// serveManager serves the provided extension manager handler on a unix socket,
+// returning the socket path.
+func serveManager(t testing.TB, handler osquery.ExtensionManager) string {
+	dir, err := ioutil.TempDir("", "osq")
+	require.NoError(t, err)
+	path := filepath.Join(dir, "sock")
+
+	trans, err := transport.OpenServer(path, 5*time.Second)
+	require.NoError(t, err)
+	server := thrift.NewTSimpleServer2(osquery.NewExtensionManagerProcessor(handler), trans)
+	// The logger is only defaulted by Serve
+	server.SetLogger(thrift.NopLogger)
+	require.NoError(t, server.Listen())
+	go server.AcceptLoop()
+
+	cleanup := func() {
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+	if tt, ok := t.(interface{ Cleanup(func()) }); ok {
+		tt.Cleanup(cleanup)
+	}
+	return path
+}
+
+func runConcurrentQueries(t testing.TB, client *ExtensionManagerClient, n int) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.QueryRows("select 1")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// blockingManager is an osquery extension manager with a Query that blocks
+// until released, signaling on entered once it is being served.
+type blockingManager struct {
+	mock.ExtensionManager
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingManager() *blockingManager {
+	return &blockingManager{entered: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (m *blockingManager) Query(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+	m.entered <- struct{}{}
+	<-m.release
+	return &osquery.ExtensionResponse{
+		Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+		Response: []map[string]string{{"sql": sql}},
+	}, nil
+}
+
+// waitEntered waits for n queries to be served at once.
+func (m *blockingManager) waitEntered(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-m.entered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d queries served at once, expected %d", i, n)
+		}
+	}
+}
+
+func TestClientConcurrentQueriesScale(t *testing.T) {
+	manager := newBlockingManager()
+	path := serveManager(t, manager)
+
+	// The pool serves the queries concurrently, each over its own
+	// connection.
+	pooled, err := NewClient(path, 5*time.Second, WithMaxConns(4))
+	require.NoError(t, err)
+	defer pooled.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runConcurrentQueries(t, pooled, 4)
+	}()
+	manager.waitEntered(t, 4)
+
+	// A single connection serializes the queries
+	single, err := NewClient(path, 5*time.Second)
+	require.NoError(t, err)
+	defer single.Close()
+	singleDone := make(chan struct{})
+	go func() {
+		defer close(singleDone)
+		runConcurrentQueries(t, single, 2)
+	}()
+	manager.waitEntered(t, 1)
+	select {
+	case <-manager.entered:
+		t.Fatal("queries served concurrently over a single connection")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(manager.release)
+	<-done
+	<-singleDone
+}
+
+func TestClientFieldUsesPool(t *testing.T) {
+	manager := newBlockingManager()
+	path := serveManager(t, manager)
+
+	client, err := NewClient(path, 5*time.Second, WithMaxConns(4))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// Calls made with the Client field and with the methods concurrently
+	// are each dispatched over their own connection.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Client.Query(context.Background(), "select 1")
+			if assert.NoError(t, err) {
+				assert.Equal(t, osquery.ExtensionPluginResponse{{"sql": "select 1"}}, resp.Response)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runConcurrentQueries(t, client, 2)
+	}()
+	manager.waitEntered(t, 4)
+	close(manager.release)
+	wg.Wait()
+
+	require.NoError(t, client.Close())
+	_, err = client.Client.Query(context.Background(), "select 1")
+	assert.Equal(t, ErrClosed, err)
+}
+
+func BenchmarkClientConcurrentQueries(b *testing.B) {
+	path := serveManager(b, &slowManager{delay: time.Millisecond})
+
+	for _, conns := range []int{1, 4} {
+		conns := conns
+		b.Run(fmt.Sprintf("conns=%d", conns), func(b *testing.B) {
+			client, err := NewClient(path, 5*time.Second, WithMaxConns(conns))
+			require.NoError(b, err)
+			defer client.Close()
+
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := client.QueryRows("select 1"); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
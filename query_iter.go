@@ -0,0 +1,71 @@
+//go:build go1.23
+
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// QueryRowsSeq executes the requested query and returns an iterator over the
+// resulting rows, for use with range:
+//
+//	for row, err := range client.QueryRowsSeq(ctx, sql) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// The query is only sent to osquery once iteration starts. Errors are
+// reported as for QueryRows, as a single final iteration with a nil row.
+// Iteration stops when ctx is done, yielding the context error.
+//
+// The osquery extension API returns all the rows of a query in a single
+// response, which is decoded in full before the first row is yielded.
+// Breaking out of the loop early does not reduce the work done by osquery,
+// but the rows are not retained once iteration stops.
+func (c *ExtensionManagerClient) QueryRowsSeq(ctx context.Context, sql string) iter.Seq2[map[string]string, error] {
+	return func(yield func(map[string]string, error) bool) {
+		var res *osquery.ExtensionResponse
+		err := c.do(func(client osquery.ExtensionManager) (err error) {
+			res, err = client.Query(ctx, sql)
+			return err
+		})
+		if err != nil {
+			yield(nil, fmt.Errorf("transport error in query: %w", err))
+			return
+		}
+		if res.Status == nil {
+			yield(nil, errors.New("query returned nil status"))
+			return
+		}
+		if res.Status.Code != 0 {
+			yield(nil, &OsqueryError{
+				Code:    int(res.Status.Code),
+				Message: res.Status.Message,
+				Query:   sql,
+			})
+			return
+		}
+
+		rows := res.Response
+		res = nil
+		for i := range rows {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			row := rows[i]
+			// Release each row once yielded.
+			rows[i] = nil
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
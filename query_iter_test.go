@@ -0,0 +1,117 @@
+//go:build go1.23
+
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRowsSeq(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: []map[string]string{{"n": "1"}, {"n": "2"}, {"n": "3"}},
+		}, nil
+	}
+
+	var rows []string
+	for row, err := range client.QueryRowsSeq(context.Background(), "select n") {
+		require.NoError(t, err)
+		rows = append(rows, row["n"])
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, rows)
+
+	// The query is not sent until iteration starts
+	mock.QueryFuncInvoked = false
+	seq := client.QueryRowsSeq(context.Background(), "select n")
+	assert.False(t, mock.QueryFuncInvoked)
+
+	rows = nil
+	for row, err := range seq {
+		require.NoError(t, err)
+		rows = append(rows, row["n"])
+		if len(rows) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"1", "2"}, rows)
+	assert.True(t, mock.QueryFuncInvoked)
+}
+
+func TestQueryRowsSeqErrors(t *testing.T) {
+	mock := &mock.ExtensionManager{}
+	client := &ExtensionManagerClient{Client: mock}
+
+	// collect returns the rows and errors yielded for sql.
+	collect := func(ctx context.Context, sql string) ([]map[string]string, []error) {
+		var rows []map[string]string
+		var errs []error
+		for row, err := range client.QueryRowsSeq(ctx, sql) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			rows = append(rows, row)
+		}
+		return rows, errs
+	}
+
+	// Transport related error
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return nil, errors.New("boom!")
+	}
+	rows, errs := collect(context.Background(), "select 1")
+	assert.Empty(t, rows)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "transport error in query: boom!", errs[0].Error())
+
+	// Nil status
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{}, nil
+	}
+	rows, errs = collect(context.Background(), "select 1")
+	assert.Empty(t, rows)
+	require.Len(t, errs, 1)
+
+	// Query error
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status: &osquery.ExtensionStatus{Code: 1, Message: "bad query"},
+		}, nil
+	}
+	rows, errs = collect(context.Background(), "select bad query")
+	assert.Empty(t, rows)
+	require.Len(t, errs, 1)
+	assert.True(t, errors.Is(errs[0], ErrQueryFailed))
+
+	// Context done during iteration
+	mock.QueryFunc = func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+		return &osquery.ExtensionResponse{
+			Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+			Response: []map[string]string{{"n": "1"}, {"n": "2"}},
+		}, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []map[string]string
+	var iterErr error
+	for row, err := range client.QueryRowsSeq(ctx, "select n") {
+		if err != nil {
+			iterErr = err
+			continue
+		}
+		got = append(got, row)
+		cancel()
+	}
+	assert.Equal(t, []map[string]string{{"n": "1"}}, got)
+	assert.Equal(t, context.Canceled, iterErr)
+}
@@ -0,0 +1,158 @@
+package osquery
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// identifierRegexp matches the names of osquery tables and columns.
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QuoteLiteral quotes s as an SQL string literal, doubling the single quotes
+// it contains.
+func QuoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// QuoteIdentifier quotes name as an SQL identifier. It returns an error if
+// name is not a valid osquery table or column name (letters, digits and
+// underscores, not starting with a digit).
+func QuoteIdentifier(name string) (string, error) {
+	if !identifierRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid identifier %q", name)
+	}
+	return `"` + name + `"`, nil
+}
+
+// Queryf formats an osquery SQL query, replacing the verbs of format with the
+// args, quoted as needed so that their values cannot change the structure of
+// the query. The verbs are:
+//
+//	%s  a string, as a string literal
+//	%d  an integer
+//	%f  a finite floating point number
+//	%v  a string, integer, float or bool (as 1 or 0), or nil as NULL
+//	%I  a table or column name, validated with QuoteIdentifier
+//	%%  a percent sign
+//
+// For example:
+//
+//	sql, err := osquery.Queryf("SELECT * FROM %I WHERE path = %s", "file", path)
+//
+// An error is returned for an unknown verb, an argument of the wrong type for
+// its verb, or a number of arguments that does not match the verbs.
+func Queryf(format string, args ...interface{}) (string, error) {
+	var sql strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			sql.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(format) {
+			return "", fmt.Errorf("query format ends with %%")
+		}
+		verb := format[i]
+		if verb == '%' {
+			sql.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return "", fmt.Errorf("missing argument for %%%c", verb)
+		}
+		formatted, err := formatQueryArg(verb, args[next])
+		if err != nil {
+			return "", fmt.Errorf("argument %d: %w", next+1, err)
+		}
+		next++
+		sql.WriteString(formatted)
+	}
+	if next != len(args) {
+		return "", fmt.Errorf("%d arguments for %d verbs", len(args), next)
+	}
+	return sql.String(), nil
+}
+
+func formatQueryArg(verb byte, arg interface{}) (string, error) {
+	switch verb {
+	case 's':
+		if s, ok := arg.(string); ok {
+			return QuoteLiteral(s), nil
+		}
+	case 'd':
+		if s, ok := formatInteger(arg); ok {
+			return s, nil
+		}
+	case 'f':
+		if f, ok := arg.(float64); ok {
+			return formatFloat(f)
+		}
+		if f, ok := arg.(float32); ok {
+			return formatFloat(float64(f))
+		}
+	case 'v':
+		switch v := arg.(type) {
+		case nil:
+			return "NULL", nil
+		case string:
+			return QuoteLiteral(v), nil
+		case bool:
+			if v {
+				return "1", nil
+			}
+			return "0", nil
+		case float64:
+			return formatFloat(v)
+		case float32:
+			return formatFloat(float64(v))
+		}
+		if s, ok := formatInteger(arg); ok {
+			return s, nil
+		}
+	case 'I':
+		if s, ok := arg.(string); ok {
+			return QuoteIdentifier(s)
+		}
+	default:
+		return "", fmt.Errorf("unknown verb %%%c", verb)
+	}
+	return "", fmt.Errorf("cannot format %T with %%%c", arg, verb)
+}
+
+func formatInteger(arg interface{}) (string, bool) {
+	switch v := arg.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 10), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	}
+	return "", false
+}
+
+func formatFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("cannot format %v", f)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
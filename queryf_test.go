@@ -0,0 +1,72 @@
+package osquery
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteLiteral(t *testing.T) {
+	assert.Equal(t, `''`, QuoteLiteral(""))
+	assert.Equal(t, `'/tmp/file'`, QuoteLiteral("/tmp/file"))
+	assert.Equal(t, `'O''Brien'`, QuoteLiteral("O'Brien"))
+	assert.Equal(t, `''' OR 1=1; --'`, QuoteLiteral("' OR 1=1; --"))
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	quoted, err := QuoteIdentifier("osquery_info")
+	require.NoError(t, err)
+	assert.Equal(t, `"osquery_info"`, quoted)
+
+	for _, name := range []string{"", "1table", "users; DROP", `a"b`, "a-b", "é"} {
+		_, err := QuoteIdentifier(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestQueryf(t *testing.T) {
+	var testCases = []struct {
+		format   string
+		args     []interface{}
+		expected string
+	}{
+		{"SELECT * FROM users WHERE username = %s", []interface{}{"o'brien"}, `SELECT * FROM users WHERE username = 'o''brien'`},
+		{"SELECT * FROM %I WHERE pid = %d", []interface{}{"processes", 42}, `SELECT * FROM "processes" WHERE pid = 42`},
+		{"SELECT %v, %v, %v, %v, %v", []interface{}{"x", uint8(7), -1.5, true, nil}, `SELECT 'x', 7, -1.5, 1, NULL`},
+		{"SELECT %f", []interface{}{float32(0.5)}, `SELECT 0.5`},
+		{"SELECT * FROM file WHERE path LIKE %s", []interface{}{"/tmp/%"}, `SELECT * FROM file WHERE path LIKE '/tmp/%'`},
+		{"SELECT 100%%", nil, `SELECT 100%`},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.format, func(t *testing.T) {
+			sql, err := Queryf(tt.format, tt.args...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sql)
+		})
+	}
+}
+
+func TestQueryfErrors(t *testing.T) {
+	var testCases = []struct {
+		format string
+		args   []interface{}
+	}{
+		{"SELECT %s", nil},
+		{"SELECT %s", []interface{}{"a", "b"}},
+		{"SELECT %s", []interface{}{1}},
+		{"SELECT %d", []interface{}{"1"}},
+		{"SELECT %f", []interface{}{math.NaN()}},
+		{"SELECT %v", []interface{}{[]string{"a"}}},
+		{"SELECT * FROM %I", []interface{}{"users; DROP TABLE x"}},
+		{"SELECT %q", []interface{}{"a"}},
+		{"SELECT 100%", nil},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.format, func(t *testing.T) {
+			_, err := Queryf(tt.format, tt.args...)
+			assert.Error(t, err)
+		})
+	}
+}
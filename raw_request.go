@@ -0,0 +1,33 @@
+package osquery
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+type rawRequestKey struct{}
+
+// RawRequestFromContext returns a copy of the request osquery sent for the
+// plugin call, when ctx is the context of a call served by an
+// ExtensionManagerServer.
+//
+// This is an escape hatch for plugins needing fields of the request that the
+// plugin packages do not model (yet). The fields sent by osquery are not part
+// of a stable API and differ between osquery versions, so plugins relying on
+// them may break when osquery is upgraded.
+func RawRequestFromContext(ctx context.Context) (osquery.ExtensionPluginRequest, bool) {
+	request, ok := ctx.Value(rawRequestKey{}).(osquery.ExtensionPluginRequest)
+	if !ok {
+		return nil, false
+	}
+	raw := make(osquery.ExtensionPluginRequest, len(request))
+	for k, v := range request {
+		raw[k] = v
+	}
+	return raw, true
+}
+
+func withRawRequest(ctx context.Context, request osquery.ExtensionPluginRequest) context.Context {
+	return context.WithValue(ctx, rawRequestKey{}, request)
+}
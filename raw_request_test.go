@@ -0,0 +1,37 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawRequestFromContext(t *testing.T) {
+	var raw osquery.ExtensionPluginRequest
+	var ok bool
+	plugin := table.NewPlugin("raw", []table.ColumnDefinition{table.TextColumn("foo")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			raw, ok = RawRequestFromContext(ctx)
+			// Modifying the copy returned does not affect the call.
+			raw["action"] = "modified"
+			return nil, nil
+		},
+	)
+	server := newTestServer(plugin)
+
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}", "unmodeled": "value"}
+	resp, err := server.Call(context.Background(), "table", "raw", request)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+
+	require.True(t, ok)
+	assert.Equal(t, osquery.ExtensionPluginRequest{"action": "modified", "context": "{}", "unmodeled": "value"}, raw)
+	assert.Equal(t, "generate", request["action"])
+
+	_, ok = RawRequestFromContext(context.Background())
+	assert.False(t, ok)
+}
@@ -0,0 +1,96 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultReadinessTimeout = 30 * time.Second
+
+// The delay between readiness checks starts at readinessMinInterval and
+// doubles after each failure, up to readinessMaxInterval.
+const (
+	readinessMinInterval = 100 * time.Millisecond
+	readinessMaxInterval = 5 * time.Second
+)
+
+// WithReadinessCheck sets a check that must pass before Start registers the
+// extension with osquery, so that osquery does not see tables whose backend
+// is not yet available (eg. a database being migrated). The check is retried
+// with an increasing delay until it returns nil or the readiness timeout (see
+// WithReadinessTimeout) is reached, in which case Start returns an error
+// matching ErrNotReady. The ctx passed to check is done once the timeout is
+// reached.
+func WithReadinessCheck(check func(ctx context.Context) error) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.readinessCheck = check
+	}
+}
+
+// WithReadinessTimeout sets how long Start waits for the check set with
+// WithReadinessCheck to pass. The default is 30 seconds.
+func WithReadinessTimeout(timeout time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.readinessTimeout = timeout
+	}
+}
+
+// waitReady runs the readiness check until it passes or the timeout is
+// reached.
+func (s *ExtensionManagerServer) waitReady() error {
+	if s.readinessCheck == nil {
+		return nil
+	}
+
+	timeout := s.readinessTimeout
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+	base := s.baseContext
+	if base == nil {
+		base = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(base, timeout)
+	defer cancel()
+
+	interval := readinessMinInterval
+	for attempt := 1; ; attempt++ {
+		err := s.readinessCheck(ctx)
+		if err == nil {
+			return nil
+		}
+		s.log("level", "info", "msg", "extension not ready", "attempt", attempt, "err", err)
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return wrapSentinel(ErrNotReady, fmt.Errorf("check did not pass within %s after %d attempts: %w", timeout, attempt, err))
+		case <-timer.C:
+		}
+		if interval *= 2; interval > readinessMaxInterval {
+			interval = readinessMaxInterval
+		}
+	}
+}
+
+// WithReadinessGate sets whether the server rejects plugin calls until
+// MarkReady is called, for extensions whose plugins finish initializing
+// after the server is started (and osquery may call them). Calls received
+// before then are returned a StatusInitializing status rather than reaching
+// the plugins. It is disabled by default. Pings are answered either way, so
+// that osquery keeps the extension registered while it initializes.
+func WithReadinessGate(enabled bool) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.readinessGate = enabled
+	}
+}
+
+// MarkReady signals that the plugins are initialized, so that the calls of
+// osquery are no longer rejected by the gate set with WithReadinessGate.
+func (s *ExtensionManagerServer) MarkReady() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ready = true
+}
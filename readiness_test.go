@@ -0,0 +1,96 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadinessCheckDefersRegistration(t *testing.T) {
+	var checks int
+	mock := newCollisionsMock()
+	check := func(ctx context.Context) error {
+		assert.False(t, mock.RegisterExtensionFuncInvoked)
+		checks++
+		if checks < 3 {
+			return errors.New("database not migrated")
+		}
+		return nil
+	}
+	logger := &testLogger{}
+	server := newTestServer(newTestTable("users"), WithReadinessCheck(check), WithLogger(logger))
+	server.serverClient = mock
+
+	err := server.Start()
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.True(t, mock.RegisterExtensionFuncInvoked)
+	assert.Equal(t, 3, checks)
+	assert.Len(t, logger.lines(), 2)
+}
+
+func TestReadinessCheckTimeout(t *testing.T) {
+	mock := newCollisionsMock()
+	checkErr := errors.New("api unreachable")
+	check := func(ctx context.Context) error {
+		_, ok := ctx.Deadline()
+		assert.True(t, ok)
+		return checkErr
+	}
+	server := newTestServer(newTestTable("users"), WithReadinessCheck(check), WithReadinessTimeout(250*time.Millisecond))
+	server.serverClient = mock
+
+	start := time.Now()
+	err := server.Start()
+	assert.True(t, errors.Is(err, ErrNotReady))
+	assert.True(t, errors.Is(err, checkErr))
+	assert.Contains(t, err.Error(), "api unreachable")
+	assert.False(t, mock.RegisterExtensionFuncInvoked)
+	assert.WithinDuration(t, start.Add(250*time.Millisecond), time.Now(), 200*time.Millisecond)
+}
+
+func TestReadinessGate(t *testing.T) {
+	var calls int
+	plugin := newTestTable("users")
+	server := newTestServer(mockCountingPlugin{plugin, &calls}, WithReadinessGate(true))
+
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+	resp, err := server.Call(context.Background(), "table", "users", request)
+	require.NoError(t, err)
+	assert.Equal(t, StatusInitializing, resp.Status.Code)
+	assert.Equal(t, "extension is initializing", resp.Status.Message)
+	assert.Equal(t, 0, calls)
+
+	status, err := server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	server.MarkReady()
+	resp, err = server.Call(context.Background(), "table", "users", request)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, 1, calls)
+}
+
+func TestReadinessGateDisabled(t *testing.T) {
+	server := newTestServer(newTestTable("users"))
+
+	resp, err := server.Call(context.Background(), "table", "users", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code)
+}
+
+// mockCountingPlugin counts the calls to the plugin.
+type mockCountingPlugin struct {
+	OsqueryPlugin
+	calls *int
+}
+
+func (p mockCountingPlugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	*p.calls++
+	return p.OsqueryPlugin.Call(ctx, request)
+}
@@ -0,0 +1,147 @@
+package osquery
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// CallRecord is a plugin call recorded by a CallRecorder, encoded as a line of
+// JSON.
+type CallRecord struct {
+	Registry string                         `json:"registry"`
+	Item     string                         `json:"item"`
+	Request  osquery.ExtensionPluginRequest `json:"request"`
+	Response osquery.ExtensionResponse      `json:"response"`
+}
+
+// CallRecorder records the plugin calls routed by a server as JSON lines, so
+// that they can later be replayed against a plugin with ReplayFile. Add it to
+// a server with WithCallInterceptors(recorder.Intercept). Recording is opt-in.
+//
+// The recorded requests and responses contain the full query contexts and
+// rows, as well as any logs, configs or distributed query results handled by
+// the plugins. These may contain sensitive data, so recordings should be
+// handled with the same care as the data served by the extension.
+type CallRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewCallRecorder creates a CallRecorder writing to w.
+func NewCallRecorder(w io.Writer) *CallRecorder {
+	return &CallRecorder{enc: json.NewEncoder(w)}
+}
+
+// Intercept is a CallInterceptor recording the call.
+func (r *CallRecorder) Intercept(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+	response := next(ctx, registry, item, request)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(CallRecord{
+			Registry: registry,
+			Item:     item,
+			Request:  request,
+			Response: response,
+		})
+	}
+	return response
+}
+
+// Err returns the error that stopped the recording, if writing a record
+// failed. Calls are no longer recorded after an error.
+func (r *CallRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// ReplayResult is the result of replaying a recorded call.
+type ReplayResult struct {
+	// Record is the recorded call.
+	Record CallRecord
+	// Response is the response returned by the plugin when replaying.
+	Response osquery.ExtensionResponse
+	// Diff describes how Response differs from the recorded response. It
+	// is empty if they are the same.
+	Diff string
+}
+
+// ReplayFile replays the calls to plugin recorded by a CallRecorder in the
+// file at path, returning the results in the order of the recording. Calls
+// recorded for other plugins are skipped. An error is returned if the file
+// cannot be read or parsed.
+func ReplayFile(path string, plugin OsqueryPlugin) ([]ReplayResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording: %w", err)
+	}
+	defer f.Close()
+
+	var results []ReplayResult
+	scanner := bufio.NewScanner(f)
+	// Rows may make for long lines.
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record CallRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("parsing recording line %d: %w", line, err)
+		}
+		if record.Registry != plugin.RegistryName() || record.Item != plugin.Name() {
+			continue
+		}
+
+		response := plugin.Call(context.Background(), record.Request)
+		results = append(results, ReplayResult{
+			Record:   record,
+			Response: response,
+			Diff:     diffResponses(record.Response, response),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading recording: %w", err)
+	}
+	return results, nil
+}
+
+// diffResponses describes the differences from the recorded response to the
+// replayed response, or returns an empty string if they are the same.
+func diffResponses(recorded, replayed osquery.ExtensionResponse) string {
+	recordedStatus, replayedStatus := recorded.Status, replayed.Status
+	if recordedStatus == nil {
+		recordedStatus = &osquery.ExtensionStatus{}
+	}
+	if replayedStatus == nil {
+		replayedStatus = &osquery.ExtensionStatus{}
+	}
+	if recordedStatus.Code != replayedStatus.Code || recordedStatus.Message != replayedStatus.Message {
+		return fmt.Sprintf("status: recorded %d %q, got %d %q",
+			recordedStatus.Code, recordedStatus.Message, replayedStatus.Code, replayedStatus.Message)
+	}
+
+	if len(recorded.Response) != len(replayed.Response) {
+		return fmt.Sprintf("rows: recorded %d rows, got %d", len(recorded.Response), len(replayed.Response))
+	}
+	for i := range recorded.Response {
+		if len(recorded.Response[i]) == 0 && len(replayed.Response[i]) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(recorded.Response[i], replayed.Response[i]) {
+			return fmt.Sprintf("row %d: recorded %v, got %v", i, recorded.Response[i], replayed.Response[i])
+		}
+	}
+	return ""
+}
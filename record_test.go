@@ -0,0 +1,64 @@
+package osquery
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	columns := []table.ColumnDefinition{table.TextColumn("name")}
+	rows := []map[string]string{{"name": "alice"}, {"name": "bob"}}
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return rows, nil
+	}
+
+	var buf bytes.Buffer
+	recorder := NewCallRecorder(&buf)
+	server := newTestServer(table.NewPlugin("users", columns, gen), WithCallInterceptors(recorder.Intercept))
+	server.RegisterPlugin(newTestTable("other"))
+
+	request := osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"}
+	for _, item := range []string{"users", "other", "users"} {
+		_, err := server.Call(context.Background(), "table", item, request)
+		require.NoError(t, err)
+	}
+	require.NoError(t, recorder.Err())
+
+	dir, err := ioutil.TempDir("", "osquery-go-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "calls.jsonl")
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0600))
+
+	// Replaying against the same plugin reports no differences, and skips
+	// the calls to other plugins.
+	results, err := ReplayFile(path, table.NewPlugin("users", columns, gen))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for _, res := range results {
+		assert.Equal(t, "users", res.Record.Item)
+		assert.Equal(t, request, res.Record.Request)
+		assert.Empty(t, res.Diff)
+	}
+
+	// A changed plugin is reported.
+	changed := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"name": "alice"}, {"name": "carol"}}, nil
+	}
+	results, err = ReplayFile(path, table.NewPlugin("users", columns, changed))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "row 1: recorded map[name:bob], got map[name:carol]", results[0].Diff)
+
+	_, err = ReplayFile(filepath.Join(dir, "missing.jsonl"), newTestTable("users"))
+	assert.Error(t, err)
+}
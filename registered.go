@@ -0,0 +1,46 @@
+package osquery
+
+import (
+	"sort"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// RegistrationResult describes a successful registration of the extension
+// with osquery, as passed to the callback set with WithOnRegistered.
+type RegistrationResult struct {
+	// Message is the status message returned by osquery.
+	Message string
+	// Plugins contains the names of the plugins sent to osquery, keyed by
+	// registry name and sorted.
+	Plugins map[string][]string
+	// SocketPath is the path of the socket the extension serves osquery
+	// calls on.
+	SocketPath string
+}
+
+// WithOnRegistered sets a callback invoked by Start once osquery accepted the
+// registration of the extension, with the UUID osquery assigned to it. It
+// allows logging or recording metrics at startup. The callback runs before
+// the server begins accepting calls, so it should return quickly.
+func WithOnRegistered(fn func(uuid osquery.ExtensionRouteUUID, result RegistrationResult)) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.onRegistered = fn
+	}
+}
+
+// registrationResult returns the RegistrationResult of a registration.
+func registrationResult(stat *osquery.ExtensionStatus, registry osquery.ExtensionRegistry, listenPath string) RegistrationResult {
+	result := RegistrationResult{
+		Message:    stat.Message,
+		Plugins:    make(map[string][]string, len(registry)),
+		SocketPath: listenPath,
+	}
+	for name, routes := range registry {
+		for plugin := range routes {
+			result.Plugins[name] = append(result.Plugins[name], plugin)
+		}
+		sort.Strings(result.Plugins[name])
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnRegistered(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+	defer os.Remove(tempPath.Name() + ".42")
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: 42}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+
+	type registration struct {
+		uuid   osquery.ExtensionRouteUUID
+		result RegistrationResult
+	}
+	registered := make(chan registration, 1)
+	server, err := NewExtensionManagerServer("test", tempPath.Name(),
+		WithClient(mock),
+		WithOnRegistered(func(uuid osquery.ExtensionRouteUUID, result RegistrationResult) {
+			registered <- registration{uuid, result}
+		}),
+	)
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("b"), newTestTable("a"), config.NewPlugin("static", nil))
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Start() }()
+
+	select {
+	case r := <-registered:
+		assert.Equal(t, osquery.ExtensionRouteUUID(42), r.uuid)
+		assert.Equal(t, RegistrationResult{
+			Message:    "OK",
+			Plugins:    map[string][]string{"table": {"a", "b"}, "config": {"static"}},
+			SocketPath: tempPath.Name() + ".42",
+		}, r.result)
+	case err := <-errc:
+		t.Fatalf("start returned before registration callback: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("registration callback not called")
+	}
+
+	server.waitStarted()
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.NoError(t, <-errc)
+}
+
+func TestRegistrationError(t *testing.T) {
+	cause := errors.New("broken pipe")
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			assert.Equal(t, "1.2.3", info.Version)
+			return nil, cause
+		},
+	}
+	server, err := NewExtensionManagerServer("test", "/tmp/osquery.em", WithClient(mock), WithExtensionVersion("1.2.3"))
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("a"), newTestTable("b"), config.NewPlugin("static", nil))
+
+	err = server.Start()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.True(t, errors.Is(err, cause))
+	assert.Equal(t, `registering extension: name "test", version "1.2.3", plugins 3, socket /tmp/osquery.em: broken pipe`, err.Error())
+
+	mock.RegisterExtensionFunc = func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+		return &osquery.ExtensionStatus{Code: 1, Message: "Duplicate extension registered"}, nil
+	}
+	server, err = NewExtensionManagerServer("test", "/tmp/osquery.em", WithClient(mock))
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("a"))
+
+	err = server.Start()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
+	assert.Equal(t, `registering extension: name "test", plugins 1, socket /tmp/osquery.em: status 1: Duplicate extension registered`, err.Error())
+}
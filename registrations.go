@@ -0,0 +1,54 @@
+package osquery
+
+import (
+	"sort"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// PluginInfo describes a plugin registered with the server, as returned by
+// Registrations.
+type PluginInfo struct {
+	// Name is the name of the plugin.
+	Name string
+	// Columns are the columns declared in the routes of table plugins. It
+	// is nil for plugins of other registries.
+	Columns []table.ColumnSchema
+}
+
+// Registrations returns the plugins registered with the server, keyed by
+// registry name and sorted by plugin name. Registries without plugins are
+// omitted. The information comes from the plugins themselves, not from
+// osquery, so it is available whether or not the server is started (eg. for
+// debug endpoints).
+func (s *ExtensionManagerServer) Registrations() map[string][]PluginInfo {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	registrations := map[string][]PluginInfo{}
+	for registry, plugins := range s.registry {
+		for _, plugin := range plugins {
+			info := PluginInfo{Name: plugin.Name()}
+			if registry == "table" {
+				info.Columns = routeColumns(plugin)
+			}
+			registrations[registry] = append(registrations[registry], info)
+		}
+		sort.Slice(registrations[registry], func(i, j int) bool {
+			return registrations[registry][i].Name < registrations[registry][j].Name
+		})
+	}
+	return registrations
+}
+
+// routeColumns returns the columns declared in the routes of plugin, in
+// order.
+func routeColumns(plugin OsqueryPlugin) []table.ColumnSchema {
+	var columns []table.ColumnSchema
+	for _, route := range plugin.Routes() {
+		if route["id"] == "column" {
+			columns = append(columns, table.ColumnSchema{Name: route["name"], Type: table.ColumnType(route["type"])})
+		}
+	}
+	return columns
+}
@@ -0,0 +1,28 @@
+package osquery
+
+import (
+	"testing"
+
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrations(t *testing.T) {
+	server := newTestServer(newTestTable("users"))
+	server.RegisterPlugin(
+		table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("path"), table.BigIntColumn("size")}, nil),
+		logger.NewPlugin("log", nil),
+		config.NewPlugin("conf", nil),
+	)
+
+	assert.Equal(t, map[string][]PluginInfo{
+		"table": {
+			{Name: "files", Columns: []table.ColumnSchema{{Name: "path", Type: table.ColumnTypeText}, {Name: "size", Type: table.ColumnTypeBigInt}}},
+			{Name: "users", Columns: []table.ColumnSchema{{Name: "foo", Type: table.ColumnTypeText}}},
+		},
+		"logger": {{Name: "log"}},
+		"config": {{Name: "conf"}},
+	}, server.Registrations())
+}
@@ -0,0 +1,47 @@
+package osquery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the plugin call, when ctx is the
+// context of a call served by an ExtensionManagerServer. Each call is given
+// an ID unique to the process (osquery does not identify its requests), which
+// is included in the log lines and CallMetrics for the call so that they can
+// be correlated with the logs of plugins and interceptors.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+var (
+	requestIDOnce   sync.Once
+	requestIDPrefix string
+	requestIDSeq    uint64
+)
+
+// newRequestID returns an ID of the form "<prefix>-<n>", where the prefix is
+// random for the process and n is incremented for each call. This is cheaper
+// than reading random bytes for each call, and the prefix avoids collisions
+// between the IDs of different processes (eg. across extension restarts).
+func newRequestID() string {
+	requestIDOnce.Do(func() {
+		var b [4]byte
+		// An error leaves the prefix zeroed, which only affects
+		// uniqueness across processes.
+		rand.Read(b[:])
+		requestIDPrefix = hex.EncodeToString(b[:])
+	})
+	return requestIDPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&requestIDSeq, 1), 10)
+}
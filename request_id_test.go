@@ -0,0 +1,43 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	var ids []string
+	capture := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest, next CallHandler) osquery.ExtensionResponse {
+		id, ok := RequestIDFromContext(ctx)
+		assert.True(t, ok)
+		ids = append(ids, id)
+		return next(ctx, registry, item, request)
+	}
+	logger := &testLogger{}
+	metrics := &mockMetricsRecorder{}
+	server := newTestServer(newTestTable("foo"), WithLogger(logger), WithMetricsRecorder(metrics), WithCallInterceptors(capture))
+
+	for i := 0; i < 2; i++ {
+		_, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "frobnicate"})
+		require.NoError(t, err)
+	}
+
+	require.Len(t, ids, 2)
+	assert.NotEmpty(t, ids[0])
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.Equal(t, []string{
+		fmt.Sprint("level", "warn", "msg", "unknown action", "plugin", "table/foo", "action", "frobnicate", "request_id", ids[0]),
+		fmt.Sprint("level", "warn", "msg", "unknown action", "plugin", "table/foo", "action", "frobnicate", "request_id", ids[1]),
+	}, logger.lines())
+	require.Len(t, metrics.calls, 2)
+	assert.Equal(t, ids[0], metrics.calls[0].RequestID)
+	assert.Equal(t, ids[1], metrics.calls[1].RequestID)
+
+	_, ok := RequestIDFromContext(context.Background())
+	assert.False(t, ok)
+}
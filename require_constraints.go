@@ -0,0 +1,48 @@
+package osquery
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// RequireConstraints wraps a table plugin so that generate calls are rejected
+// with an error status, without calling the plugin, unless the query has an
+// equality constraint on each of columns. This allows tables that can only
+// generate rows for given values (eg. "WHERE path = '/etc/hosts'") to share
+// the check, instead of each implementing it.
+//
+// Other actions, and generate calls with a query context that cannot be
+// parsed, are passed to the plugin unmodified.
+func RequireConstraints(plugin OsqueryPlugin, columns ...string) OsqueryPlugin {
+	return &requireConstraintsPlugin{OsqueryPlugin: plugin, columns: columns}
+}
+
+type requireConstraintsPlugin struct {
+	OsqueryPlugin
+	columns []string
+}
+
+func (p *requireConstraintsPlugin) Call(ctx context.Context, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+	if request["action"] != "generate" {
+		return p.OsqueryPlugin.Call(ctx, request)
+	}
+	queryContext, err := table.ParseQueryContext(request["context"])
+	if err != nil {
+		return p.OsqueryPlugin.Call(ctx, request)
+	}
+
+	pushdown := queryContext.Pushdown(p.columns...)
+	for _, col := range p.columns {
+		if !pushdown.Has(col) {
+			return osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{
+					Code:    1,
+					Message: "query must have an equality constraint on column " + col,
+				},
+			}
+		}
+	}
+	return p.OsqueryPlugin.Call(ctx, request)
+}
@@ -0,0 +1,57 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireConstraints(t *testing.T) {
+	var generated int
+	plugin := RequireConstraints(table.NewPlugin("files", []table.ColumnDefinition{
+		table.TextColumn("path"),
+		table.TextColumn("owner"),
+		table.BigIntColumn("size"),
+	}, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		generated++
+		return []map[string]string{{"path": "/etc/hosts", "owner": "root", "size": "10"}}, nil
+	}), "path", "owner")
+
+	assert.Equal(t, "files", plugin.Name())
+	assert.Equal(t, "table", plugin.RegistryName())
+	assert.Len(t, plugin.Routes(), 3)
+
+	for _, tt := range []struct {
+		name    string
+		context string
+		missing string
+	}{
+		{"no constraints", `{}`, "path"},
+		{"missing owner", `{"constraints":[{"name":"path","list":[{"op":2,"expr":"/etc/hosts"}],"affinity":"TEXT"}]}`, "owner"},
+		{"non-equality", `{"constraints":[{"name":"path","list":[{"op":65,"expr":"/etc/%"}],"affinity":"TEXT"},{"name":"owner","list":[{"op":2,"expr":"root"}],"affinity":"TEXT"}]}`, "path"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "generate", "context": tt.context})
+			require.Equal(t, int32(1), resp.Status.Code)
+			assert.Equal(t, "query must have an equality constraint on column "+tt.missing, resp.Status.Message)
+		})
+	}
+	assert.Equal(t, 0, generated)
+
+	resp := plugin.Call(context.Background(), osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"path","list":[{"op":2,"expr":"/etc/hosts"}],"affinity":"TEXT"},{"name":"owner","list":[{"op":2,"expr":"root"}],"affinity":"TEXT"}]}`,
+	})
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Len(t, resp.Response, 1)
+	assert.Equal(t, 1, generated)
+
+	// Other actions are passed through.
+	resp = plugin.Call(context.Background(), osquery.ExtensionPluginRequest{"action": "columns"})
+	require.Equal(t, int32(0), resp.Status.Code)
+	assert.Len(t, resp.Response, 3)
+}
@@ -0,0 +1,85 @@
+package osquery
+
+import (
+	"errors"
+	"time"
+)
+
+// errNotServing is returned by reRegister when the server is not started or
+// has been shut down.
+var errNotServing = errors.New("extension server not serving")
+
+// WithAutoReRegister sets whether Run registers the extension again when the
+// osquery instance restarts, rather than shutting down. When enabled, Run
+// keeps pinging osquery (see ServerPingInterval) after a ping fails, and once
+// osquery answers again registers the same plugins with it and serves its
+// calls on the socket of the new registration. The callback set with
+// WithOnRegistered is invoked on each registration. It is disabled by
+// default.
+func WithAutoReRegister(enabled bool) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.autoReRegister = enabled
+	}
+}
+
+// reRegisterWhenBack waits for osquery to answer pings again after pingErr,
+// then registers the extension with it. It returns pingErr once the
+// extension is not serving, eg. when it shuts down while osquery is
+// unreachable.
+func (s *ExtensionManagerServer) reRegisterWhenBack(pingErr error) error {
+	s.log("level", "warn", "msg", "osquery ping failed, waiting to register again", "err", pingErr)
+	for {
+		err := s.reRegister()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errNotServing) {
+			return pingErr
+		}
+		time.Sleep(s.pingInterval)
+	}
+}
+
+// reRegister registers the extension with osquery if it answers pings, and
+// replaces the server with one serving the socket of the new registration.
+func (s *ExtensionManagerServer) reRegister() error {
+	if !s.serving() {
+		return errNotServing
+	}
+	if err := s.pingOsquery(); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	if !s.servingLocked() {
+		s.mutex.Unlock()
+		return errNotServing
+	}
+	previous := s.server
+	uuid, result, err := s.register()
+	if err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	s.mutex.Unlock()
+
+	// Start serves the new server once the previous one stopped.
+	previous.Stop()
+	s.log("level", "info", "msg", "registered again with osquery", "uuid", uuid)
+	if s.onRegistered != nil {
+		s.onRegistered(uuid, result)
+	}
+	return nil
+}
+
+// serving returns whether the server is started and not shutting down.
+func (s *ExtensionManagerServer) serving() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.servingLocked()
+}
+
+// servingLocked is serving, for callers holding the mutex.
+func (s *ExtensionManagerServer) servingLocked() bool {
+	return s.started && s.server != nil && s.shutdownDone == nil
+}
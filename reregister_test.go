@@ -0,0 +1,211 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoReRegister(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to the current process on windows")
+	}
+
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+
+	// The fake osquery is down while osqueryDown is set. Each
+	// registration is assigned a new UUID, as a restarted osquery would.
+	var osqueryDown, lastUUID int32
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			if atomic.LoadInt32(&osqueryDown) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			uuid := atomic.AddInt32(&lastUUID, 1)
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: osquery.ExtensionRouteUUID(uuid)}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			if atomic.LoadInt32(&osqueryDown) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+
+	registered := make(chan RegistrationResult, 2)
+	server, err := NewExtensionManagerServer("test", tempPath.Name(),
+		WithClient(mock),
+		ServerPingInterval(10*time.Millisecond),
+		WithSignals(syscall.SIGHUP),
+		WithAutoReRegister(true),
+		WithOnRegistered(func(uuid osquery.ExtensionRouteUUID, result RegistrationResult) {
+			registered <- result
+		}),
+	)
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("a"))
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Run() }()
+
+	waitRegistered := func() RegistrationResult {
+		select {
+		case result := <-registered:
+			return result
+		case err := <-errc:
+			t.Fatalf("Run returned: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("extension not registered")
+		}
+		return RegistrationResult{}
+	}
+	first := waitRegistered()
+	assert.Equal(t, tempPath.Name()+".1", first.SocketPath)
+
+	// Simulate a restart of osquery.
+	atomic.StoreInt32(&osqueryDown, 1)
+	time.Sleep(50 * time.Millisecond)
+	atomic.StoreInt32(&osqueryDown, 0)
+
+	second := waitRegistered()
+	assert.Equal(t, tempPath.Name()+".2", second.SocketPath)
+	assert.Equal(t, first.Plugins, second.Plugins)
+
+	// The extension serves the calls of the new osquery, once the new
+	// server is listening.
+	addr, err := net.ResolveUnixAddr("unix", second.SocketPath)
+	require.NoError(t, err)
+	trans := thrift.NewTSocketFromAddrTimeout(addr, 5*time.Second, 5*time.Second)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if err := trans.Open(); err == nil {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("connecting to the new socket: %v", err)
+		}
+	}
+	defer trans.Close()
+	client := osquery.NewExtensionClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault())
+	resp, err := client.Call(context.Background(), "table", "a", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+
+	proc, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, proc.Signal(syscall.SIGHUP))
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after signal")
+	}
+	assert.Equal(t, osquery.ExtensionRouteUUID(2), server.uuid)
+}
+
+func TestAutoReRegisterDisabled(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			return nil, errors.New("connection refused")
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server, err := NewExtensionManagerServer("test", tempPath.Name(),
+		WithClient(mock),
+		ServerPingInterval(10*time.Millisecond),
+		WithSignals(),
+	)
+	require.NoError(t, err)
+
+	err = server.Run()
+	assert.True(t, errors.Is(err, ErrPingFailed))
+}
+
+func TestAutoReRegisterShutdownWhileUnreachable(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+
+	// osquery goes away for good once the extension is registered.
+	var osqueryDown, pings int32
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{Code: 0, Message: "OK", UUID: 1}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			atomic.AddInt32(&pings, 1)
+			if atomic.LoadInt32(&osqueryDown) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	registered := make(chan struct{}, 1)
+	server, err := NewExtensionManagerServer("test", tempPath.Name(),
+		WithClient(mock),
+		ServerPingInterval(10*time.Millisecond),
+		WithSignals(),
+		WithAutoReRegister(true),
+		WithOnRegistered(func(uuid osquery.ExtensionRouteUUID, result RegistrationResult) {
+			registered <- struct{}{}
+		}),
+	)
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("a"))
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Run() }()
+	select {
+	case <-registered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("extension not registered")
+	}
+
+	// Wait for the extension to be waiting for osquery to come back.
+	atomic.StoreInt32(&osqueryDown, 1)
+	for start := atomic.LoadInt32(&pings); atomic.LoadInt32(&pings) < start+3; {
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, server.Shutdown(context.Background()))
+	select {
+	case <-errc:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+
+	// The re-register loop ends, and stops pinging.
+	time.Sleep(50 * time.Millisecond)
+	stopped := atomic.LoadInt32(&pings)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&pings))
+}
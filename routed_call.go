@@ -0,0 +1,40 @@
+package osquery
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CallRegisteredPlugin calls a plugin registered with osquery, by osquery
+// itself or by any extension, and returns the response rows. The call is
+// made to the osquery extension manager, which routes it to the extension
+// owning the plugin, allowing an extension to use the plugins of others (eg.
+// a config plugin reading from a secrets extension).
+//
+// If the call fails and the plugin is not in the osquery registry, the error
+// returned matches ErrPluginNotFound. Other failures of the plugin are
+// returned as an *OsqueryError, as by CallExtension.
+func (c *ExtensionManagerClient) CallRegisteredPlugin(registry, item string, request map[string]string) ([]map[string]string, error) {
+	rows, callErr := c.CallExtension(registry, item, request)
+	var osqueryErr *OsqueryError
+	if callErr == nil || !errors.As(callErr, &osqueryErr) {
+		return rows, callErr
+	}
+
+	// osquery reports unknown plugins with a failure status, like plugin
+	// errors, so the registry tells them apart.
+	sql, err := Queryf("SELECT active FROM osquery_registry WHERE registry = %s AND name = %s", registry, item)
+	if err != nil {
+		return nil, callErr
+	}
+	registered, err := c.QueryRows(sql)
+	if err != nil {
+		return nil, callErr
+	}
+	for _, row := range registered {
+		if row["active"] == "1" {
+			return nil, callErr
+		}
+	}
+	return nil, wrapSentinel(ErrPluginNotFound, fmt.Errorf("no active %s plugin %q in the osquery registry", registry, item))
+}
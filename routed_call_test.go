@@ -0,0 +1,83 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRoutingManager returns a fake osquery extension manager routing calls
+// to the plugins, by registry and name, as osquery routes them to the
+// extensions owning them.
+func newRoutingManager(plugins map[string]map[string]OsqueryPlugin) *mock.ExtensionManager {
+	return &mock.ExtensionManager{
+		CallFunc: func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+			plugin, ok := plugins[registry][item]
+			if !ok {
+				return &osquery.ExtensionResponse{
+					Status: &osquery.ExtensionStatus{Code: 1, Message: "Unknown registry plugin: " + item},
+				}, nil
+			}
+			response := plugin.Call(ctx, request)
+			return &response, nil
+		},
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			var rows []map[string]string
+			for registry := range plugins {
+				for name := range plugins[registry] {
+					if sql == "SELECT active FROM osquery_registry WHERE registry = '"+registry+"' AND name = '"+name+"'" {
+						rows = append(rows, map[string]string{"active": "1"})
+					}
+				}
+			}
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0}, Response: rows}, nil
+		},
+	}
+}
+
+func TestCallRegisteredPlugin(t *testing.T) {
+	manager := newRoutingManager(map[string]map[string]OsqueryPlugin{
+		"table": {"users": newTestTable("users")},
+	})
+	client := &ExtensionManagerClient{Client: manager}
+
+	rows, err := client.CallRegisteredPlugin("table", "users", map[string]string{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string(newTestTable("users").Routes()), rows)
+	assert.False(t, manager.QueryFuncInvoked)
+
+	// A failure of the plugin is returned as is.
+	_, err = client.CallRegisteredPlugin("table", "users", map[string]string{"action": "unknown"})
+	var osqueryErr *OsqueryError
+	require.True(t, errors.As(err, &osqueryErr))
+	assert.Equal(t, "users", osqueryErr.Item)
+	assert.False(t, errors.Is(err, ErrPluginNotFound))
+	assert.True(t, manager.QueryFuncInvoked)
+}
+
+func TestCallRegisteredPluginNotRegistered(t *testing.T) {
+	manager := newRoutingManager(map[string]map[string]OsqueryPlugin{
+		"table": {"users": newTestTable("users")},
+	})
+	client := &ExtensionManagerClient{Client: manager}
+
+	_, err := client.CallRegisteredPlugin("config", "users", map[string]string{"action": "genConfig"})
+	assert.True(t, errors.Is(err, ErrPluginNotFound))
+	assert.Contains(t, err.Error(), `no active config plugin "users" in the osquery registry`)
+
+	// Transport errors are returned without checking the registry.
+	manager = newRoutingManager(nil)
+	manager.CallFunc = func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+		return nil, errors.New("boom!")
+	}
+	client = &ExtensionManagerClient{Client: manager}
+	_, err = client.CallRegisteredPlugin("config", "secrets", map[string]string{"action": "genConfig"})
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrPluginNotFound))
+	assert.False(t, manager.QueryFuncInvoked)
+}
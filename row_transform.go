@@ -0,0 +1,65 @@
+package osquery
+
+import "github.com/osquery/osquery-go/gen/osquery"
+
+// RowTransformer transforms a row generated by a table before it is returned
+// to osquery, for example to redact or normalize values, or to add a column.
+// The row is a copy, which the transformer may modify and return. Returning
+// nil drops the row.
+type RowTransformer func(row map[string]string) map[string]string
+
+// WithRowTransformer adds a transformer applied to the rows generated by
+// every table of the extension. Transformers run in the order they are
+// added, after those set for the table with WithPluginRowTransformer, and
+// before the UTF-8 sanitization and cell size limit.
+func WithRowTransformer(transformer RowTransformer) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.rowTransformers = append(s.rowTransformers, transformer)
+	}
+}
+
+// WithPluginRowTransformer adds a transformer applied to the rows generated
+// by the table, before the transformers set with WithRowTransformer.
+func WithPluginRowTransformer(transformer RowTransformer) PluginOption {
+	return func(c *pluginConfig) {
+		c.rowTransformers = append(c.rowTransformers, transformer)
+	}
+}
+
+// rowTransformersFor returns the transformers applied to the rows generated
+// by a plugin of the registry with the config, in order.
+func (s *ExtensionManagerServer) rowTransformersFor(registry string, config pluginConfig) []RowTransformer {
+	if registry != "table" {
+		return nil
+	}
+	transformers := config.rowTransformers
+	if len(transformers) == 0 {
+		return s.rowTransformers
+	}
+	return append(transformers[:len(transformers):len(transformers)], s.rowTransformers...)
+}
+
+// transformRows applies the transformers to the rows of a successful
+// generate response. The rows are copied before being transformed, as
+// plugins may retain them.
+func transformRows(transformers []RowTransformer, request osquery.ExtensionPluginRequest, response *osquery.ExtensionResponse) {
+	if request["action"] != "generate" || response.Status == nil || response.Status.Code != 0 {
+		return
+	}
+	rows := make(osquery.ExtensionPluginResponse, 0, len(response.Response))
+	for _, row := range response.Response {
+		transformed := make(map[string]string, len(row))
+		for k, v := range row {
+			transformed[k] = v
+		}
+		for _, transformer := range transformers {
+			if transformed = transformer(transformed); transformed == nil {
+				break
+			}
+		}
+		if transformed != nil {
+			rows = append(rows, transformed)
+		}
+	}
+	response.Response = rows
+}
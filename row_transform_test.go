@@ -0,0 +1,75 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowTransformer(t *testing.T) {
+	rows := []map[string]string{
+		{"username": "alice", "password": "hunter2"},
+		{"username": "root", "password": "toor"},
+		{"username": "bob", "password": "letmein"},
+	}
+	plugin := table.NewPlugin("users", []table.ColumnDefinition{table.TextColumn("username"), table.TextColumn("password")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return rows, nil
+		},
+	)
+	redact := func(row map[string]string) map[string]string {
+		row["password"] = "REDACTED"
+		return row
+	}
+	dropRoot := func(row map[string]string) map[string]string {
+		if row["username"] == "root" {
+			return nil
+		}
+		return row
+	}
+	server := newTestServer(plugin, WithRowTransformer(redact), WithRowTransformer(dropRoot))
+
+	resp, err := server.Call(context.Background(), "table", "users", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code)
+	assert.Equal(t, osquery.ExtensionPluginResponse{
+		{"username": "alice", "password": "REDACTED"},
+		{"username": "bob", "password": "REDACTED"},
+	}, resp.Response)
+
+	// The rows returned by the plugin are not modified
+	assert.Equal(t, "hunter2", rows[0]["password"])
+
+	// The column definitions are not transformed
+	resp, err = server.Call(context.Background(), "table", "users", osquery.ExtensionPluginRequest{"action": "columns"})
+	require.NoError(t, err)
+	assert.Equal(t, plugin.Routes(), resp.Response)
+}
+
+func TestPluginRowTransformer(t *testing.T) {
+	newTable := func(name string) *table.Plugin {
+		return table.NewPlugin(name, []table.ColumnDefinition{table.TextColumn("name")},
+			func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+				return []map[string]string{{"name": name}}, nil
+			},
+		)
+	}
+	addHostname := func(row map[string]string) map[string]string {
+		row["hostname"] = "host1"
+		return row
+	}
+	server := newTestServer(newTable("plain"))
+	server.RegisterPluginWithOptions(newTable("enriched"), WithPluginRowTransformer(addHostname))
+
+	resp, err := server.Call(context.Background(), "table", "enriched", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "enriched", "hostname": "host1"}}, resp.Response)
+
+	resp, err = server.Call(context.Background(), "table", "plain", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "plain"}}, resp.Response)
+}
@@ -0,0 +1,110 @@
+package osquery
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// UTF8Sanitization controls how invalid UTF-8 in row values is handled before
+// responses are sent to osquery.
+type UTF8Sanitization int
+
+const (
+	// UTF8Passthrough sends values unmodified. This is the default.
+	UTF8Passthrough UTF8Sanitization = iota
+	// UTF8Replace replaces each run of invalid bytes with the Unicode
+	// replacement character (U+FFFD).
+	UTF8Replace
+	// UTF8HexEscape replaces each invalid byte with a \xNN escape, so that
+	// the original bytes can be recovered.
+	UTF8HexEscape
+)
+
+// WithUTF8Sanitization sets how invalid UTF-8 sequences in the row values
+// returned by plugins are handled. Tables reading arbitrary bytes (eg. file
+// contents) may otherwise return values that osquery fails to process. The
+// values of BLOB columns are never modified, as they are expected to contain
+// raw bytes.
+func WithUTF8Sanitization(mode UTF8Sanitization) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.utf8Sanitization = mode
+	}
+}
+
+// blobColumns returns the names of the BLOB columns declared in the routes of
+// plugin. It is called when the plugin is registered, rather than on each
+// call, as building the routes may be costly.
+func blobColumns(plugin OsqueryPlugin) map[string]bool {
+	if plugin.RegistryName() != "table" {
+		return nil
+	}
+	var blobs map[string]bool
+	for _, route := range plugin.Routes() {
+		if route["id"] == "column" && route["type"] == "BLOB" {
+			if blobs == nil {
+				blobs = map[string]bool{}
+			}
+			blobs[route["name"]] = true
+		}
+	}
+	return blobs
+}
+
+// sanitizeResponse returns the rows with any invalid UTF-8 values sanitized
+// according to mode, skipping the columns in skip. Rows are copied before
+// being modified, as plugins may retain them.
+func sanitizeResponse(rows osquery.ExtensionPluginResponse, mode UTF8Sanitization, skip map[string]bool) osquery.ExtensionPluginResponse {
+	if mode == UTF8Passthrough {
+		return rows
+	}
+
+	var sanitized osquery.ExtensionPluginResponse
+	for i, row := range rows {
+		var copied map[string]string
+		for k, v := range row {
+			if skip[k] || utf8.ValidString(v) {
+				continue
+			}
+			if copied == nil {
+				copied = make(map[string]string, len(row))
+				for k, v := range row {
+					copied[k] = v
+				}
+			}
+			copied[k] = sanitizeString(v, mode)
+		}
+		if copied == nil {
+			continue
+		}
+		if sanitized == nil {
+			sanitized = append(osquery.ExtensionPluginResponse(nil), rows...)
+		}
+		sanitized[i] = copied
+	}
+
+	if sanitized == nil {
+		return rows
+	}
+	return sanitized
+}
+
+func sanitizeString(s string, mode UTF8Sanitization) string {
+	if mode == UTF8Replace {
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, "\\x%02x", s[0])
+		} else {
+			b.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTF8Sanitization(t *testing.T) {
+	var testCases = []struct {
+		mode     UTF8Sanitization
+		expected string
+	}{
+		{UTF8Passthrough, "ok\xff\xfedata"},
+		{UTF8Replace, "ok�data"},
+		{UTF8HexEscape, `ok\xff\xfedata`},
+	}
+
+	for _, tt := range testCases {
+		t.Run("", func(t *testing.T) {
+			rows := []map[string]string{
+				{"data": "ok\xff\xfedata", "name": "bin"},
+				{"data": "valid", "name": "text"},
+			}
+			plugin := table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("name"), table.TextColumn("data")},
+				func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+					return rows, nil
+				},
+			)
+			server := newTestServer(plugin, WithUTF8Sanitization(tt.mode))
+
+			resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+			require.NoError(t, err)
+			require.Equal(t, int32(0), resp.Status.Code)
+			require.Len(t, resp.Response, 2)
+			assert.Equal(t, tt.expected, resp.Response[0]["data"])
+			assert.Equal(t, "bin", resp.Response[0]["name"])
+			assert.Equal(t, map[string]string{"data": "valid", "name": "text"}, resp.Response[1])
+			if tt.mode != UTF8Passthrough {
+				for _, row := range resp.Response {
+					for _, v := range row {
+						assert.True(t, utf8.ValidString(v))
+					}
+				}
+			}
+
+			// The rows returned by the plugin are not modified
+			assert.Equal(t, "ok\xff\xfedata", rows[0]["data"])
+		})
+	}
+}
+
+func TestUTF8SanitizationSkipsBlobs(t *testing.T) {
+	plugin := table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("name"), table.BlobColumn("data")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "bin\xff", "data": "\x00\xff\xfe"}}, nil
+		},
+	)
+	server := newTestServer(plugin, WithUTF8Sanitization(UTF8HexEscape))
+
+	resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": `bin\xff`, "data": "\x00\xff\xfe"}}, resp.Response)
+}
+
+// routesCounter counts the calls to the Routes of a table.
+type routesCounter struct {
+	*table.Plugin
+	routes int
+}
+
+func (p *routesCounter) Routes() osquery.ExtensionPluginResponse {
+	p.routes++
+	return p.Plugin.Routes()
+}
+
+func TestBlobColumnsComputedOnRegistration(t *testing.T) {
+	plugin := &routesCounter{Plugin: table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("name"), table.BlobColumn("data")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "bin\xff", "data": "\x00\xff\xfe"}}, nil
+		},
+	)}
+	server := newTestServer(plugin, WithUTF8Sanitization(UTF8HexEscape), WithMaxCellBytes(64, CellLimitTruncate))
+	registered := plugin.routes
+
+	for i := 0; i < 3; i++ {
+		resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"name": `bin\xff`, "data": "\x00\xff\xfe"}}, resp.Response)
+	}
+	assert.Equal(t, registered, plugin.routes)
+
+	// Replacing the table replaces its BLOB columns
+	server.RegisterPlugin(table.NewPlugin("files", []table.ColumnDefinition{table.TextColumn("name"), table.TextColumn("data")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return []map[string]string{{"name": "bin", "data": "\xff"}}, nil
+		},
+	))
+	resp, err := server.Call(context.Background(), "table", "files", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "bin", "data": `\xff`}}, resp.Response)
+}
@@ -0,0 +1,155 @@
+package osquery
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ScheduledQuery is a query in the osquery schedule, as reported by the
+// osquery_schedule table. Packed queries are named "pack:<pack>:<query>".
+type ScheduledQuery struct {
+	Name     string
+	Query    string
+	Interval time.Duration
+	// Executions is the number of times the query was executed.
+	Executions int64
+	// LastExecuted is the time of the last execution, or the zero time if
+	// the query was not executed yet.
+	LastExecuted time.Time
+	// Denylisted is true if osquery stopped scheduling the query (eg. for
+	// exceeding its resource limits).
+	Denylisted bool
+	// OutputSize is the total size in bytes of the results logged.
+	OutputSize int64
+	// WallTime, UserTime and SystemTime are the totals spent executing the
+	// query.
+	WallTime   time.Duration
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// AverageMemory is the average memory used by the query, in bytes.
+	AverageMemory int64
+}
+
+// Pack is a query pack loaded by osquery, as reported by the osquery_packs
+// table.
+type Pack struct {
+	Name     string
+	Platform string
+	Version  string
+	Shard    int64
+	// DiscoveryCacheHits and DiscoveryExecutions count the evaluations of
+	// the pack discovery queries.
+	DiscoveryCacheHits  int64
+	DiscoveryExecutions int64
+	// Active is true if the pack passed its discovery queries and its
+	// queries are scheduled.
+	Active bool
+}
+
+// ScheduledQueries returns the queries in the osquery schedule, including the
+// queries of the loaded packs.
+func (c *ExtensionManagerClient) ScheduledQueries() ([]ScheduledQuery, error) {
+	rows, err := c.QueryRows("SELECT * FROM osquery_schedule")
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]ScheduledQuery, 0, len(rows))
+	for _, row := range rows {
+		q, err := parseScheduledQuery(row)
+		if err != nil {
+			return nil, fmt.Errorf("parsing osquery_schedule row %q: %w", row["name"], err)
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// Packs returns the query packs loaded by osquery.
+func (c *ExtensionManagerClient) Packs() ([]Pack, error) {
+	rows, err := c.QueryRows("SELECT * FROM osquery_packs")
+	if err != nil {
+		return nil, err
+	}
+	packs := make([]Pack, 0, len(rows))
+	for _, row := range rows {
+		p, err := parsePack(row)
+		if err != nil {
+			return nil, fmt.Errorf("parsing osquery_packs row %q: %w", row["name"], err)
+		}
+		packs = append(packs, p)
+	}
+	return packs, nil
+}
+
+func parseScheduledQuery(row map[string]string) (ScheduledQuery, error) {
+	p := rowParser{row: row}
+	q := ScheduledQuery{
+		Name:          row["name"],
+		Query:         row["query"],
+		Interval:      time.Duration(p.int("interval")) * time.Second,
+		Executions:    p.int("executions"),
+		LastExecuted:  p.unixTime("last_executed"),
+		OutputSize:    p.int("output_size"),
+		UserTime:      time.Duration(p.int("user_time")) * time.Millisecond,
+		SystemTime:    time.Duration(p.int("system_time")) * time.Millisecond,
+		AverageMemory: p.int("average_memory"),
+	}
+	// osquery 5 renamed blacklisted to denylisted, and reports wall_time
+	// in seconds alongside the more precise wall_time_ms.
+	if _, ok := row["denylisted"]; ok {
+		q.Denylisted = p.bool("denylisted")
+	} else {
+		q.Denylisted = p.bool("blacklisted")
+	}
+	if _, ok := row["wall_time_ms"]; ok {
+		q.WallTime = time.Duration(p.int("wall_time_ms")) * time.Millisecond
+	} else {
+		q.WallTime = time.Duration(p.int("wall_time")) * time.Second
+	}
+	return q, p.err
+}
+
+func parsePack(row map[string]string) (Pack, error) {
+	p := rowParser{row: row}
+	pack := Pack{
+		Name:                row["name"],
+		Platform:            row["platform"],
+		Version:             row["version"],
+		Shard:               p.int("shard"),
+		DiscoveryCacheHits:  p.int("discovery_cache_hits"),
+		DiscoveryExecutions: p.int("discovery_executions"),
+		Active:              p.bool("active"),
+	}
+	return pack, p.err
+}
+
+// rowParser parses the values of a row, recording the first error. Missing
+// and empty values parse as zero.
+type rowParser struct {
+	row map[string]string
+	err error
+}
+
+func (p *rowParser) int(column string) int64 {
+	v := p.row[column]
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("column %s: %w", column, err)
+	}
+	return n
+}
+
+func (p *rowParser) bool(column string) bool {
+	return p.int(column) != 0
+}
+
+func (p *rowParser) unixTime(column string) time.Time {
+	if n := p.int(column); n != 0 {
+		return time.Unix(n, 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,88 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRowsMock returns a mock responding to queries with the provided rows,
+// recording the queries in sql.
+func newRowsMock(sql *string, rows ...map[string]string) *mock.ExtensionManager {
+	return &mock.ExtensionManager{
+		QueryFunc: func(ctx context.Context, query string) (*osquery.ExtensionResponse, error) {
+			*sql = query
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: rows,
+			}, nil
+		},
+	}
+}
+
+func TestScheduledQueries(t *testing.T) {
+	var sql string
+	client := &ExtensionManagerClient{Client: newRowsMock(&sql,
+		// osquery 5
+		map[string]string{
+			"name": "pack:it:usb", "query": "SELECT * FROM usb_devices", "interval": "3600",
+			"executions": "4", "last_executed": "1600000000", "denylisted": "0", "output_size": "2048",
+			"wall_time": "1", "wall_time_ms": "1500", "last_wall_time_ms": "300", "user_time": "120",
+			"last_user_time": "30", "system_time": "80", "last_system_time": "20",
+			"average_memory": "65536", "last_memory": "60000",
+		},
+		// osquery 4
+		map[string]string{
+			"name": "procs", "query": "SELECT * FROM processes", "interval": "60",
+			"executions": "0", "last_executed": "0", "blacklisted": "1", "output_size": "0",
+			"wall_time": "2", "user_time": "0", "system_time": "0", "average_memory": "0",
+		},
+	)}
+
+	queries, err := client.ScheduledQueries()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM osquery_schedule", sql)
+	assert.Equal(t, []ScheduledQuery{
+		{
+			Name: "pack:it:usb", Query: "SELECT * FROM usb_devices", Interval: time.Hour,
+			Executions: 4, LastExecuted: time.Unix(1600000000, 0), OutputSize: 2048,
+			WallTime: 1500 * time.Millisecond, UserTime: 120 * time.Millisecond, SystemTime: 80 * time.Millisecond,
+			AverageMemory: 65536,
+		},
+		{
+			Name: "procs", Query: "SELECT * FROM processes", Interval: time.Minute,
+			Denylisted: true, WallTime: 2 * time.Second,
+		},
+	}, queries)
+
+	client = &ExtensionManagerClient{Client: newRowsMock(&sql, map[string]string{"name": "bad", "interval": "often"})}
+	_, err = client.ScheduledQueries()
+	assert.EqualError(t, err, `parsing osquery_schedule row "bad": column interval: strconv.ParseInt: parsing "often": invalid syntax`)
+}
+
+func TestPacks(t *testing.T) {
+	var sql string
+	client := &ExtensionManagerClient{Client: newRowsMock(&sql,
+		map[string]string{
+			"name": "it", "platform": "darwin", "version": "1.2.0", "shard": "50",
+			"discovery_cache_hits": "6", "discovery_executions": "2", "active": "1",
+		},
+		map[string]string{
+			"name": "incident", "platform": "", "version": "", "shard": "0",
+			"discovery_cache_hits": "0", "discovery_executions": "1", "active": "0",
+		},
+	)}
+
+	packs, err := client.Packs()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM osquery_packs", sql)
+	assert.Equal(t, []Pack{
+		{Name: "it", Platform: "darwin", Version: "1.2.0", Shard: 50, DiscoveryCacheHits: 6, DiscoveryExecutions: 2, Active: true},
+		{Name: "incident", DiscoveryExecutions: 1},
+	}, packs)
+}
@@ -0,0 +1,33 @@
+#!/bin/sh
+# Runs the integration test against a real osqueryd.
+#
+# The osqueryd binary is taken from the OSQUERYD environment variable, or
+# the PATH. Otherwise, on linux, the osquery release OSQUERY_VERSION is
+# downloaded to a temporary directory.
+#
+#   OSQUERYD=/usr/local/bin/osqueryd ./scripts/integration.sh
+set -eu
+
+OSQUERY_VERSION=${OSQUERY_VERSION:-5.10.2}
+
+cd "$(dirname "$0")/.."
+
+if [ -z "${OSQUERYD:-}" ]; then
+	OSQUERYD=$(command -v osqueryd || true)
+fi
+
+if [ -z "$OSQUERYD" ]; then
+	if [ "$(uname -s)" != Linux ] || [ "$(uname -m)" != x86_64 ]; then
+		echo "osqueryd not found, set OSQUERYD to its path" >&2
+		exit 1
+	fi
+	tmp=$(mktemp -d)
+	trap 'rm -rf "$tmp"' EXIT
+	url="https://github.com/osquery/osquery/releases/download/${OSQUERY_VERSION}/osquery-${OSQUERY_VERSION}_1.linux_x86_64.tar.gz"
+	echo "downloading $url" >&2
+	curl -fsSL "$url" | tar -xz -C "$tmp" ./opt/osquery/bin/osqueryd
+	OSQUERYD="$tmp/opt/osquery/bin/osqueryd"
+fi
+
+echo "using $OSQUERYD" >&2
+OSQUERYD="$OSQUERYD" go test -tags integration -run '^TestIntegration$' -count 1 -v .
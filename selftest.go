@@ -0,0 +1,117 @@
+package osquery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// SelfTestResult contains the outcome of exercising a single plugin with
+// SelfTest.
+type SelfTestResult struct {
+	// Registry is the registry name of the plugin.
+	Registry string
+	// Name is the name of the plugin.
+	Name string
+	// Rows is the number of rows returned by the plugin.
+	Rows int
+	// Err is non-nil if the plugin returned an error status or produced
+	// rows that do not match its declared columns.
+	Err error
+}
+
+// SelfTestReport contains the results of a SelfTest run, one per exercised
+// plugin.
+type SelfTestReport struct {
+	Results []SelfTestResult
+}
+
+// Failed returns true if any of the exercised plugins failed.
+func (r SelfTestReport) Failed() bool {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human readable summary of the report, with one line per
+// plugin.
+func (r SelfTestReport) String() string {
+	var buf bytes.Buffer
+	for _, res := range r.Results {
+		if res.Err != nil {
+			fmt.Fprintf(&buf, "FAIL %s/%s: %s\n", res.Registry, res.Name, res.Err)
+			continue
+		}
+		fmt.Fprintf(&buf, "ok   %s/%s: %d rows\n", res.Registry, res.Name, res.Rows)
+	}
+	return buf.String()
+}
+
+// SelfTest exercises the provided plugins without requiring a running osquery
+// instance. Each table plugin is asked to generate with an empty query context,
+// and the returned rows are validated against the columns declared in the
+// plugin routes. Plugins registered to other registries are skipped, as they
+// cannot be exercised without side effects.
+//
+// The returned error is non-nil if any plugin failed. The report contains the
+// details for every exercised plugin.
+func SelfTest(ctx context.Context, plugins ...OsqueryPlugin) (SelfTestReport, error) {
+	var report SelfTestReport
+	for _, plugin := range plugins {
+		if plugin.RegistryName() != "table" {
+			continue
+		}
+		report.Results = append(report.Results, selfTestTable(ctx, plugin))
+	}
+
+	if report.Failed() {
+		return report, errors.New("self-test failed")
+	}
+	return report, nil
+}
+
+func selfTestTable(ctx context.Context, plugin OsqueryPlugin) SelfTestResult {
+	result := SelfTestResult{Registry: plugin.RegistryName(), Name: plugin.Name()}
+
+	columns := map[string]bool{}
+	for _, route := range plugin.Routes() {
+		if route["id"] == "column" {
+			columns[route["name"]] = true
+		}
+	}
+	if len(columns) == 0 {
+		result.Err = errors.New("no columns declared in routes")
+		return result
+	}
+
+	resp := plugin.Call(ctx, osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": "{}",
+	})
+	if resp.Status == nil {
+		result.Err = errors.New("generate returned nil status")
+		return result
+	}
+	if resp.Status.Code != 0 {
+		result.Err = fmt.Errorf("generate returned status %d: %s", resp.Status.Code, resp.Status.Message)
+		return result
+	}
+
+	result.Rows = len(resp.Response)
+	for i, row := range resp.Response {
+		for col := range row {
+			if !columns[col] {
+				result.Err = fmt.Errorf("row %d contains undeclared column %q", i, col)
+				return result
+			}
+		}
+	}
+
+	return result
+}
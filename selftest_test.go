@@ -0,0 +1,52 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfTest(t *testing.T) {
+	columns := []table.ColumnDefinition{
+		table.TextColumn("text"),
+		table.IntegerColumn("integer"),
+	}
+
+	good := table.NewPlugin("good", columns, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{
+			{"text": "hello", "integer": "1"},
+			{"text": "world", "integer": "2"},
+		}, nil
+	})
+	failing := table.NewPlugin("failing", columns, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return nil, errors.New("boom")
+	})
+	undeclared := table.NewPlugin("undeclared", columns, func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return []map[string]string{{"text": "hello", "bogus": "1"}}, nil
+	})
+	log := logger.NewPlugin("log", func(ctx context.Context, typ logger.LogType, log string) error {
+		t.Fatal("logger plugins should not be exercised")
+		return nil
+	})
+
+	report, err := SelfTest(context.Background(), good, log)
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+	assert.False(t, report.Failed())
+	assert.Equal(t, SelfTestResult{Registry: "table", Name: "good", Rows: 2}, report.Results[0])
+
+	report, err = SelfTest(context.Background(), good, failing, undeclared)
+	assert.Error(t, err)
+	require.Len(t, report.Results, 3)
+	assert.True(t, report.Failed())
+	assert.NoError(t, report.Results[0].Err)
+	assert.Contains(t, report.Results[1].Err.Error(), "boom")
+	assert.Contains(t, report.Results[2].Err.Error(), `undeclared column "bogus"`)
+	assert.Contains(t, report.String(), "FAIL table/failing")
+	assert.Contains(t, report.String(), "ok   table/good: 2 rows")
+}
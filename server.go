@@ -2,15 +2,19 @@ package osquery
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
 
 	"github.com/osquery/osquery-go/gen/osquery"
 	"github.com/osquery/osquery-go/transport"
-	"github.com/pkg/errors"
 )
 
 type OsqueryPlugin interface {
@@ -18,13 +22,15 @@ type OsqueryPlugin interface {
 	// table the plugin implements).
 	Name() string
 	// RegistryName is which "registry" the plugin should be added to.
-	// Valid names are ["config", "logger", "table"].
+	// Valid names are ["config", "logger", "table", "distributed", "carver"].
 	RegistryName() string
 	// Routes returns the detailed information about the interface exposed
 	// by the plugin. See the example plugins for samples.
 	Routes() osquery.ExtensionPluginResponse
 	// Ping implements a health check for the plugin. If the plugin is in a
-	// healthy state, StatusOK should be returned.
+	// healthy state, StatusOK should be returned. Ping is evaluated each
+	// time osquery pings the extension, so the result may change over the
+	// lifetime of the plugin.
 	Ping() osquery.ExtensionStatus
 	// Call requests the plugin to perform its defined behavior, returning
 	// a response containing the result.
@@ -35,6 +41,11 @@ type OsqueryPlugin interface {
 
 const defaultTimeout = 1 * time.Second
 const defaultPingInterval = 5 * time.Second
+const defaultDrainTimeout = 5 * time.Second
+
+// defaultSignals are the signals handled by Run unless overridden with
+// WithSignals.
+var defaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
 
 // ExtensionManagerServer is an implementation of the full ExtensionManager
 // API. Plugins can register with an extension manager, which handles the
@@ -51,6 +62,76 @@ type ExtensionManagerServer struct {
 	mutex        sync.Mutex
 	uuid         osquery.ExtensionRouteUUID
 	started      bool // Used to ensure tests wait until the server is actually started
+	metrics      MetricsRecorder
+	interceptors []CallInterceptor
+	callTimeout  time.Duration
+	drainTimeout time.Duration
+	signals      []os.Signal
+	calls        callTracker
+	logger       Logger
+
+	maxMessageSize int64
+
+	// pluginConfigs contains the options for plugins registered with
+	// RegisterPluginWithOptions, by registry and plugin name.
+	pluginConfigs map[string]map[string]pluginConfig
+	// tableBlobs contains the BLOB columns of the registered tables, by
+	// table name, computed when the tables are registered.
+	tableBlobs map[string]map[string]bool
+
+	rejectTableCollisions bool
+
+	utf8Sanitization UTF8Sanitization
+
+	rowTransformers []RowTransformer
+
+	// maxCellBytes limits the size of row values, if positive.
+	maxCellBytes    int
+	cellLimitAction CellLimitAction
+
+	readinessCheck   func(context.Context) error
+	readinessTimeout time.Duration
+	readinessGate    bool
+	ready            bool
+
+	// fromEnv is set by FromEnv.
+	fromEnv bool
+
+	osqueryTimeout       time.Duration
+	osqueryTimeoutMargin time.Duration
+
+	osqueryVersion versionCache
+
+	// responseBudget sheds load when plugins return too much data, if
+	// set.
+	responseBudget *responseBudget
+
+	slowCallThreshold time.Duration
+	logConstraints    bool
+
+	tablePrefix string
+
+	version string
+
+	onRegistered func(osquery.ExtensionRouteUUID, RegistrationResult)
+
+	autoReRegister bool
+	// processor handles the calls of osquery, once the server is
+	// started.
+	processor thrift.TProcessorFactory
+
+	// shutdownDone is created by the first call to Shutdown, and closed
+	// once the server is shut down.
+	shutdownDone chan struct{}
+
+	// skipDeregister disables deregistration in Shutdown.
+	skipDeregister bool
+
+	baseContext context.Context
+	connContext func(context.Context) context.Context
+	// cancelConns cancels the context of every connection, once the
+	// server is started.
+	cancelConns context.CancelFunc
 }
 
 // validRegistryNames contains the allowable RegistryName() values. If a plugin
@@ -60,6 +141,7 @@ var validRegistryNames = map[string]bool{
 	"logger":      true,
 	"config":      true,
 	"distributed": true,
+	"carver":      true,
 }
 
 type ServerOption func(*ExtensionManagerServer)
@@ -76,6 +158,63 @@ func ServerPingInterval(interval time.Duration) ServerOption {
 	}
 }
 
+// WithClient sets the client used to communicate with the osquery extension
+// manager, instead of connecting to the socket at the path provided to
+// NewExtensionManagerServer. This is mostly useful for tests.
+func WithClient(client ExtensionManager) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.serverClient = client
+	}
+}
+
+// WithSignals sets the signals that cause Run to shut down the server
+// gracefully. By default Run handles SIGINT and SIGTERM. Calling WithSignals
+// with no signals disables the handling, for programs that manage signals
+// themselves.
+func WithSignals(signals ...os.Signal) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.signals = signals
+	}
+}
+
+// WithDrainTimeout sets how long Run waits for in-flight plugin calls to
+// complete when shutting down. The default is 5 seconds.
+func WithDrainTimeout(timeout time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.drainTimeout = timeout
+	}
+}
+
+// WithDeregisterOnShutdown sets whether Shutdown deregisters the extension
+// from osquery, so that its plugins are removed from the osquery registry
+// immediately rather than once osquery notices the disconnect. It is enabled
+// by default. If osquery is already gone when shutting down, the failure to
+// deregister is ignored.
+func WithDeregisterOnShutdown(enabled bool) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.skipDeregister = !enabled
+	}
+}
+
+// WithExtensionVersion sets the version of the extension sent to osquery on
+// registration, which osquery reports in the osquery_extensions table.
+func WithExtensionVersion(version string) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.version = version
+	}
+}
+
+// WithCallTimeout sets a timeout for each call routed to a plugin. The ctx
+// passed to the plugin (eg. to a table's Generate) has a deadline of the
+// timeout from the start of the call, allowing plugins to budget their work
+// with ctx.Deadline() and to stop once ctx is done. By default there is no
+// timeout and the ctx has no deadline.
+func WithCallTimeout(timeout time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.callTimeout = timeout
+	}
+}
+
 // NewExtensionManagerServer creates a new extension management server
 // communicating with osquery over the socket at the provided path. If
 // resolving the address or connecting to the socket fails, this function will
@@ -91,19 +230,38 @@ func NewExtensionManagerServer(name string, sockPath string, opts ...ServerOptio
 		name:         name,
 		sockPath:     sockPath,
 		registry:     registry,
-		timeout:      defaultTimeout,
-		pingInterval: defaultPingInterval,
+		drainTimeout: defaultDrainTimeout,
+		signals:      defaultSignals,
 	}
 
 	for _, opt := range opts {
 		opt(manager)
 	}
 
-	serverClient, err := NewClient(sockPath, manager.timeout)
-	if err != nil {
+	// The timeout and ping interval are zero unless set by an option, so
+	// that FromEnv does not override them.
+	if manager.fromEnv {
+		if err := manager.applyEnv(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validTablePrefix(manager.tablePrefix); err != nil {
 		return nil, err
 	}
-	manager.serverClient = serverClient
+	if manager.timeout == 0 {
+		manager.timeout = defaultTimeout
+	}
+	if manager.pingInterval == 0 {
+		manager.pingInterval = defaultPingInterval
+	}
+
+	if manager.serverClient == nil {
+		serverClient, err := NewClient(manager.sockPath, manager.timeout, WithMaxResponseSize(manager.maxMessageSize))
+		if err != nil {
+			return nil, err
+		}
+		manager.serverClient = serverClient
+	}
 
 	return manager, nil
 }
@@ -113,16 +271,66 @@ func (s *ExtensionManagerServer) RegisterPlugin(plugins ...OsqueryPlugin) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	for _, plugin := range plugins {
-		if !validRegistryNames[plugin.RegistryName()] {
-			panic("invalid registry name: " + plugin.RegistryName())
+		s.registerPlugin(plugin, nil)
+	}
+}
+
+// registerPlugin adds the plugin to the registry with its options, if any,
+// replacing any plugin of the same name and its options. The plugin and its
+// options are published together, so that calls never see the plugin
+// without them. It must be called with s.mutex held.
+func (s *ExtensionManagerServer) registerPlugin(plugin OsqueryPlugin, config *pluginConfig) {
+	if !validRegistryNames[plugin.RegistryName()] {
+		panic("invalid registry name: " + plugin.RegistryName())
+	}
+	plugin = s.withTablePrefix(plugin)
+	s.registry[plugin.RegistryName()][plugin.Name()] = plugin
+	if config != nil {
+		if s.pluginConfigs == nil {
+			s.pluginConfigs = map[string]map[string]pluginConfig{}
+		}
+		if s.pluginConfigs[plugin.RegistryName()] == nil {
+			s.pluginConfigs[plugin.RegistryName()] = map[string]pluginConfig{}
+		}
+		s.pluginConfigs[plugin.RegistryName()][plugin.Name()] = *config
+	} else {
+		delete(s.pluginConfigs[plugin.RegistryName()], plugin.Name())
+	}
+	if plugin.RegistryName() == "table" {
+		s.checkColumnTypes(plugin)
+		if blobs := blobColumns(plugin); blobs != nil {
+			if s.tableBlobs == nil {
+				s.tableBlobs = map[string]map[string]bool{}
+			}
+			s.tableBlobs[plugin.Name()] = blobs
+		} else {
+			delete(s.tableBlobs, plugin.Name())
+		}
+	}
+}
+
+// checkColumnTypes logs the columns of plugin with an invalid or unknown type.
+// Unknown types are not rejected, as they may be supported by the osquery
+// version in use.
+func (s *ExtensionManagerServer) checkColumnTypes(plugin OsqueryPlugin) {
+	for _, col := range routeColumns(plugin) {
+		switch {
+		case !col.Type.Valid():
+			s.log("level", "warn", "msg", "column without type", "table", plugin.Name(), "column", col.Name)
+		case !col.Type.Known():
+			s.log("level", "warn", "msg", "unknown column type", "table", plugin.Name(), "column", col.Name, "type", string(col.Type))
 		}
-		s.registry[plugin.RegistryName()][plugin.Name()] = plugin
 	}
 }
 
 func (s *ExtensionManagerServer) genRegistry() osquery.ExtensionRegistry {
 	registry := osquery.ExtensionRegistry{}
 	for regName, _ := range s.registry {
+		// Only send registries with plugins, so that osquery is not
+		// told about registries the extension does not use.
+		if len(s.registry[regName]) == 0 {
+			continue
+		}
 		registry[regName] = osquery.ExtensionRouteTable{}
 		for _, plugin := range s.registry[regName] {
 			registry[regName][plugin.Name()] = plugin.Routes()
@@ -133,48 +341,38 @@ func (s *ExtensionManagerServer) genRegistry() osquery.ExtensionRegistry {
 
 // Start registers the extension plugins and begins listening on a unix socket
 // for requests from the osquery process. All plugins should be registered with
-// RegisterPlugin() before calling Start().
+// RegisterPlugin() before calling Start(). If a readiness check is set with
+// WithReadinessCheck, registration is deferred until it passes.
 func (s *ExtensionManagerServer) Start() error {
+	if err := s.waitReady(); err != nil {
+		return err
+	}
+
 	var server thrift.TServer
+	var uuid osquery.ExtensionRouteUUID
+	var result RegistrationResult
 	err := func() error {
 		s.mutex.Lock()
 		defer s.mutex.Unlock()
-		registry := s.genRegistry()
 
-		stat, err := s.serverClient.RegisterExtension(
-			&osquery.InternalExtensionInfo{
-				Name: s.name,
-			},
-			registry,
-		)
-
-		if err != nil {
-			return errors.Wrap(err, "registering extension")
+		base := s.baseContext
+		if base == nil {
+			base = context.Background()
 		}
-		if stat.Code != 0 {
-			return errors.Errorf("status %d registering extension: %s", stat.Code, stat.Message)
+		base, s.cancelConns = context.WithCancel(base)
+		s.processor = &connProcessorFactory{
+			processor:   osquery.NewExtensionProcessor(extensionHandler{s}),
+			base:        base,
+			connContext: s.connContext,
 		}
-		s.uuid = stat.UUID
-
-		listenPath := fmt.Sprintf("%s.%d", s.sockPath, stat.UUID)
 
-		processor := osquery.NewExtensionProcessor(s)
-
-		s.transport, err = transport.OpenServer(listenPath, s.timeout)
+		var err error
+		uuid, result, err = s.register()
 		if err != nil {
-			openError := errors.Wrapf(err, "opening server socket (%s)", listenPath)
-			_, err = s.serverClient.DeregisterExtension(stat.UUID)
-			if err != nil {
-				return errors.Wrapf(err, "deregistering extension - follows %s", openError.Error())
-			}
-			return openError
+			return err
 		}
-
-		s.server = thrift.NewTSimpleServer2(processor, s.transport)
 		server = s.server
-
 		s.started = true
-
 		return nil
 	}()
 
@@ -182,83 +380,378 @@ func (s *ExtensionManagerServer) Start() error {
 		return err
 	}
 
-	return server.Serve()
+	if s.onRegistered != nil {
+		s.onRegistered(uuid, result)
+	}
+	for {
+		err := server.Serve()
+
+		// The server is replaced when the extension registers again
+		// with a restarted osquery (see WithAutoReRegister), and
+		// cleared on shutdown.
+		s.mutex.Lock()
+		next := s.server
+		s.mutex.Unlock()
+		if err != nil || next == nil || next == server {
+			return err
+		}
+		server = next
+	}
+}
+
+// register registers the extension plugins with osquery and opens the socket
+// serving the calls of osquery, replacing s.transport and s.server. s.mutex
+// must be held.
+func (s *ExtensionManagerServer) register() (osquery.ExtensionRouteUUID, RegistrationResult, error) {
+	if err := s.checkTableCollisions(); err != nil {
+		return 0, RegistrationResult{}, err
+	}
+	registry := s.genRegistry()
+
+	stat, err := s.serverClient.RegisterExtension(
+		&osquery.InternalExtensionInfo{
+			Name:    s.name,
+			Version: s.version,
+		},
+		registry,
+	)
+
+	if err != nil {
+		return 0, RegistrationResult{}, s.registrationError(registry, err)
+	}
+	if stat.Code != 0 {
+		return 0, RegistrationResult{}, s.registrationError(registry, fmt.Errorf("status %d: %s", stat.Code, stat.Message))
+	}
+
+	listenPath := fmt.Sprintf("%s.%d", s.sockPath, stat.UUID)
+
+	serverTransport, err := transport.OpenServer(listenPath, s.timeout)
+	if err != nil {
+		openError := fmt.Errorf("opening server socket (%s): %w", listenPath, err)
+		_, err = s.serverClient.DeregisterExtension(stat.UUID)
+		if err != nil {
+			return 0, RegistrationResult{}, fmt.Errorf("deregistering extension - follows %s: %w", openError.Error(), err)
+		}
+		return 0, RegistrationResult{}, openError
+	}
+
+	s.uuid = stat.UUID
+	s.transport = serverTransport
+	s.server = thrift.NewTSimpleServerFactory4(
+		s.processor,
+		s.transport,
+		thrift.NewTTransportFactory(),
+		protocolFactory(s.maxMessageSize),
+	)
+
+	return stat.UUID, registrationResult(stat, registry, listenPath), nil
 }
 
-// Run starts the extension manager and runs until osquery calls for a shutdown
-// or the osquery instance goes away.
+// registrationError returns an error matching ErrRegistrationFailed, wrapping
+// cause with the details of the registration attempted.
+func (s *ExtensionManagerServer) registrationError(registry osquery.ExtensionRegistry, cause error) error {
+	plugins := 0
+	for _, routes := range registry {
+		plugins += len(routes)
+	}
+	details := fmt.Sprintf("name %q", s.name)
+	if s.version != "" {
+		details += fmt.Sprintf(", version %q", s.version)
+	}
+	return wrapSentinel(ErrRegistrationFailed, fmt.Errorf("%s, plugins %d, socket %s: %w", details, plugins, s.sockPath, cause))
+}
+
+// Run starts the extension manager and runs until osquery calls for a shutdown,
+// the osquery instance goes away (unless WithAutoReRegister is enabled) or one
+// of the signals set with WithSignals (SIGINT and SIGTERM by default) is
+// received. On return the server has been shut down, waiting up to the drain
+// timeout for in-flight calls to complete.
 func (s *ExtensionManagerServer) Run() error {
-	errc := make(chan error)
+	// Both goroutines may send, and the channel is buffered so that the
+	// one not received from can still return.
+	errc := make(chan error, 2)
 	go func() {
 		errc <- s.Start()
 	}()
 
 	// Watch for the osquery process going away. If so, initiate shutdown.
+	// With WithAutoReRegister, wait for it to come back instead.
 	go func() {
 		for {
 			time.Sleep(s.pingInterval)
 
-			status, err := s.serverClient.Ping()
-			if err != nil {
-				errc <- errors.Wrap(err, "extension ping failed")
-				break
+			err := s.pingOsquery()
+			if err != nil && s.autoReRegister {
+				err = s.reRegisterWhenBack(err)
 			}
-			if status.Code != 0 {
-				errc <- errors.Errorf("ping returned status %d", status.Code)
+			if err != nil {
+				errc <- err
 				break
 			}
 		}
 	}()
 
-	err := <-errc
-	if err := s.Shutdown(context.Background()); err != nil {
+	// A nil channel is never ready, so signals are ignored when signal
+	// handling is disabled.
+	var sigc chan os.Signal
+	if len(s.signals) > 0 {
+		sigc = make(chan os.Signal, 1)
+		signal.Notify(sigc, s.signals...)
+		defer signal.Stop(sigc)
+	}
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-sigc:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
 		return err
 	}
 	return err
 }
 
-// Ping implements the basic health check.
+// pingOsquery pings the osquery instance the extension is registered with.
+func (s *ExtensionManagerServer) pingOsquery() error {
+	status, err := s.serverClient.Ping()
+	if err != nil {
+		return wrapSentinel(ErrPingFailed, err)
+	}
+	if status.Code != 0 {
+		return wrapSentinel(ErrPingFailed, fmt.Errorf("ping returned status %d", status.Code))
+	}
+	return nil
+}
+
+// Ping implements the basic health check. The Ping method of every registered
+// plugin is evaluated on each call (results are never cached), and the first
+// non-OK status is returned.
+//
+// osquery pings each extension every --extensions_interval seconds (3 by
+// default). A ping returning a non-zero status is treated the same as a
+// failed connection: after a few consecutive failures osquery considers the
+// extension gone and removes its plugins from the registry. Plugins should
+// therefore only report an unhealthy status when it is acceptable for
+// osquery to drop the extension, and should not expect their status to be
+// observed more often than the osquery interval.
 func (s *ExtensionManagerServer) Ping(ctx context.Context) (*osquery.ExtensionStatus, error) {
-	return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+	s.mutex.Lock()
+	uuid := s.uuid
+	var plugins []OsqueryPlugin
+	for _, subreg := range s.registry {
+		for _, plugin := range subreg {
+			plugins = append(plugins, plugin)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, plugin := range plugins {
+		status := plugin.Ping()
+		if status.Code != 0 {
+			status.UUID = uuid
+			return &status, nil
+		}
+	}
+	return NewStatus(0, "OK", uuid), nil
 }
 
 // Call routes a call from the osquery process to the appropriate registered
-// plugin.
+// plugin, through any interceptors added with WithCallInterceptors.
 func (s *ExtensionManagerServer) Call(ctx context.Context, registry string, item string, request osquery.ExtensionPluginRequest) (*osquery.ExtensionResponse, error) {
+	s.mutex.Lock()
+	uuid := s.uuid
 	subreg, ok := s.registry[registry]
 	if !ok {
+		s.mutex.Unlock()
 		return &osquery.ExtensionResponse{
-			Status: &osquery.ExtensionStatus{
-				Code:    1,
-				Message: "Unknown registry: " + registry,
-			},
+			Status: NewStatus(1, "Unknown registry: "+registry, uuid),
 		}, nil
 	}
 
 	plugin, ok := subreg[item]
+	config := s.pluginConfigFor(registry, item)
+	var blobs map[string]bool
+	if registry == "table" {
+		blobs = s.tableBlobs[item]
+	}
+	initializing := s.readinessGate && !s.ready
+	s.mutex.Unlock()
 	if !ok {
 		return &osquery.ExtensionResponse{
-			Status: &osquery.ExtensionStatus{
-				Code:    1,
-				Message: "Unknown registry item: " + item,
-			},
+			Status: NewStatus(1, "Unknown registry item: "+item, uuid),
+		}, nil
+	}
+	if initializing {
+		return &osquery.ExtensionResponse{
+			Status: NewStatus(StatusInitializing, "extension is initializing", uuid),
 		}, nil
 	}
 
-	response := plugin.Call(context.Background(), request)
+	if !s.calls.add() {
+		return &osquery.ExtensionResponse{
+			Status: NewStatus(1, "extension is shutting down", uuid),
+		}, nil
+	}
+	defer s.calls.done()
+
+	id := newRequestID()
+	if s.responseBudget != nil {
+		if response, shed := s.shedLoad(id, registry, item, request); shed {
+			response.Status.UUID = uuid
+			return &response, nil
+		}
+	}
+	if s.logConstraints {
+		s.logGenerateConstraints(id, registry, item, request)
+	}
+	handler := func(ctx context.Context, registry, item string, request osquery.ExtensionPluginRequest) osquery.ExtensionResponse {
+		start := time.Now()
+		response := plugin.Call(ctx, request)
+		if response.Status != nil && response.Status.Code == 1 && ctx.Err() != nil {
+			// The plugin most likely failed because the call was
+			// canceled or timed out, which the status code tells
+			// apart from other failures. The status is copied, as
+			// plugins may return a shared status.
+			status := *response.Status
+			status.Code = contextErrorCode(ctx.Err())
+			response.Status = &status
+		}
+		if isUnknownAction(request, response) {
+			// Likely an action added in a newer osquery version.
+			s.log("level", "warn", "msg", "unknown action", "plugin", registry+"/"+item, "action", request["action"], "request_id", id)
+		}
+		if s.metrics != nil {
+			s.recordCall(id, registry, item, request, response, time.Since(start))
+		}
+		return response
+	}
+	timeout := s.callTimeoutFor(config)
+	if s.osqueryTimeout > 0 {
+		if deadline := osqueryDeadline(s.osqueryTimeout, s.osqueryTimeoutMargin); timeout == 0 || deadline < timeout {
+			timeout = deadline
+		}
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx = withRequestID(ctx, id)
+	if s.logger != nil {
+		ctx = withLogger(ctx, callLogger{logger: s.logger, plugin: registry + "/" + item, requestID: id})
+	}
+	ctx = withCallInfo(ctx, s.serverClient, registry, item)
+	ctx = withRawRequest(ctx, request)
+	ctx = withVersionCache(ctx, &s.osqueryVersion)
+	call := func(ctx context.Context) osquery.ExtensionResponse {
+		return chainInterceptors(s.interceptors, handler)(ctx, registry, item, request)
+	}
+	var response osquery.ExtensionResponse
+	start := time.Now()
+	if s.osqueryTimeout > 0 {
+		response = s.callBeforeDeadline(ctx, call)
+	} else {
+		response = call(ctx)
+	}
+	if duration := time.Since(start); s.slowCallThreshold > 0 && duration > s.slowCallThreshold {
+		s.logSlowCall(id, registry, item, request, duration)
+	}
+	if transformers := s.rowTransformersFor(registry, config); len(transformers) > 0 {
+		transformRows(transformers, request, &response)
+	}
+	if s.utf8Sanitization != UTF8Passthrough {
+		response.Response = sanitizeResponse(response.Response, s.utf8Sanitization, blobs)
+	}
+	if s.maxCellBytes > 0 && registry == "table" && request["action"] == "generate" {
+		s.limitCells(id, registry, item, &response, blobs)
+	}
+	if s.responseBudget != nil {
+		s.responseBudget.add(responseSize(response.Response))
+	}
+	if response.Status != nil {
+		// The status is copied, as plugins may return a shared
+		// status.
+		status := *response.Status
+		status.UUID = uuid
+		response.Status = &status
+	}
 	return &response, nil
 }
 
-// Shutdown deregisters the extension, stops the server and closes all sockets.
+func (s *ExtensionManagerServer) recordCall(id, registry, item string, request osquery.ExtensionPluginRequest, response osquery.ExtensionResponse, duration time.Duration) {
+	m := CallMetrics{
+		Registry:  registry,
+		Item:      item,
+		Action:    request["action"],
+		RequestID: id,
+		Duration:  duration,
+		Rows:      len(response.Response),
+		Bytes:     responseSize(response.Response),
+	}
+	if response.Status != nil {
+		m.StatusCode = response.Status.Code
+	}
+	s.metrics.RecordCall(m)
+}
+
+// Shutdown deregisters the extension (unless disabled with
+// WithDeregisterOnShutdown), waits for in-flight plugin calls to complete,
+// shuts down the registered plugins, stops the server and closes all sockets.
+// If ctx is done before the in-flight calls complete, shutdown
+// proceeds without waiting for them and an error matching both
+// ErrCallsInterrupted and the ctx error is returned.
+//
+// Calls received once Shutdown is called are rejected with an error status.
+// Shutdown may be called more than once, and concurrently: the server is only
+// shut down once, and later calls wait for it to complete (or for their ctx
+// to be done) and return nil.
+//
+// Shutdown must not be called from within a plugin call, as it would wait for
+// that call to complete.
 func (s *ExtensionManagerServer) Shutdown(ctx context.Context) (err error) {
+	s.mutex.Lock()
+	if s.shutdownDone != nil {
+		done := s.shutdownDone
+		s.mutex.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	s.shutdownDone = make(chan struct{})
+	defer close(s.shutdownDone)
+	s.calls.close()
+	if !s.skipDeregister {
+		err = s.deregister()
+	}
+	s.mutex.Unlock()
+
+	// The mutex is not held while waiting, so that in-flight calls can
+	// complete and new calls are rejected rather than blocked.
+	if drainErr := s.calls.wait(ctx); drainErr != nil && err == nil {
+		err = wrapSentinel(ErrCallsInterrupted, fmt.Errorf("waiting for in-flight calls: %w", drainErr))
+	}
+
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	stat, err := s.serverClient.DeregisterExtension(s.uuid)
-	err = errors.Wrap(err, "deregistering extension")
-	if err == nil && stat.Code != 0 {
-		err = errors.Errorf("status %d deregistering extension: %s", stat.Code, stat.Message)
+	if closeErr := s.serverClient.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing client: %w", closeErr)
+	}
+	for _, subreg := range s.registry {
+		for _, plugin := range subreg {
+			plugin.Shutdown()
+		}
+	}
+	if s.cancelConns != nil {
+		// When shutting down in response to a shutdown request from
+		// osquery, keep its connection open to write the response.
+		keepOpenOnDone(ctx)
+		s.cancelConns()
 	}
-	s.serverClient.Close()
 	if s.server != nil {
 		server := s.server
 		s.server = nil
@@ -274,6 +767,41 @@ func (s *ExtensionManagerServer) Shutdown(ctx context.Context) (err error) {
 	return
 }
 
+// deregister deregisters the extension from osquery. Transport errors showing
+// that osquery is already gone are ignored, as osquery has then dropped the
+// extension itself.
+func (s *ExtensionManagerServer) deregister() error {
+	stat, err := s.serverClient.DeregisterExtension(s.uuid)
+	if err != nil {
+		if osqueryGone(err) {
+			s.log("level", "info", "msg", "osquery is gone, skipping deregistration", "err", err)
+			return nil
+		}
+		return wrapSentinel(ErrDeregistrationFailed, err)
+	}
+	if stat.Code != 0 {
+		return wrapSentinel(ErrDeregistrationFailed, fmt.Errorf("status %d: %s", stat.Code, stat.Message))
+	}
+	return nil
+}
+
+// osqueryGone returns true if err shows that the connection to osquery is
+// closed or cannot be established.
+func osqueryGone(err error) bool {
+	if errors.Is(err, ErrSocketUnavailable) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var transportErr thrift.TTransportException
+	if errors.As(err, &transportErr) {
+		switch transportErr.TypeId() {
+		case thrift.NOT_OPEN, thrift.END_OF_FILE:
+			return true
+		}
+	}
+	return false
+}
+
 // Useful for testing
 func (s *ExtensionManagerServer) waitStarted() {
 	for {
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"syscall"
 	"testing"
@@ -15,7 +16,11 @@ import (
 	"github.com/apache/thrift/lib/go/thrift"
 
 	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/carver"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/osquery/osquery-go/plugin/distributed"
 	"github.com/osquery/osquery-go/plugin/logger"
+	"github.com/osquery/osquery-go/plugin/table"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -39,7 +44,7 @@ func TestNoDeadlockOnError(t *testing.T) {
 		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
 			return &osquery.ExtensionStatus{}, nil
 		},
-		CloseFunc: func() {},
+		CloseFunc: func() error { return nil },
 	}
 	server := &ExtensionManagerServer{
 		serverClient: mock,
@@ -54,6 +59,7 @@ func TestNoDeadlockOnError(t *testing.T) {
 
 	err := server.Run()
 	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrRegistrationFailed))
 	mut.Lock()
 	defer mut.Unlock()
 	assert.True(t, mock.RegisterExtensionFuncInvoked)
@@ -77,7 +83,7 @@ func TestShutdownWhenPingFails(t *testing.T) {
 		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
 			return &osquery.ExtensionStatus{}, nil
 		},
-		CloseFunc: func() {},
+		CloseFunc: func() error { return nil },
 	}
 	server := &ExtensionManagerServer{
 		serverClient: mock,
@@ -87,6 +93,8 @@ func TestShutdownWhenPingFails(t *testing.T) {
 	err := server.Run()
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "broken pipe")
+	assert.True(t, errors.Is(err, ErrPingFailed))
+	assert.True(t, errors.Is(err, syscall.EPIPE))
 	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
 	assert.True(t, mock.CloseFuncInvoked)
 }
@@ -117,7 +125,7 @@ func testShutdownDeadlock(t *testing.T) {
 		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
 			return &osquery.ExtensionStatus{}, nil
 		},
-		CloseFunc: func() {},
+		CloseFunc: func() error { return nil },
 	}
 	server := ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
 
@@ -189,7 +197,7 @@ func TestShutdownBasic(t *testing.T) {
 		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
 			return &osquery.ExtensionStatus{}, nil
 		},
-		CloseFunc: func() {},
+		CloseFunc: func() error { return nil },
 	}
 	server := ExtensionManagerServer{serverClient: mock, sockPath: tempPath.Name()}
 
@@ -213,3 +221,209 @@ func TestShutdownBasic(t *testing.T) {
 		t.Fatal("hung on shutdown")
 	}
 }
+
+func TestPingEvaluatesPlugins(t *testing.T) {
+	healthy := true
+	plugin := table.NewPlugin("ping", []table.ColumnDefinition{table.TextColumn("text")},
+		func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+			return nil, nil
+		},
+		table.WithPing(func() osquery.ExtensionStatus {
+			if healthy {
+				return osquery.ExtensionStatus{Code: 0, Message: "OK"}
+			}
+			return osquery.ExtensionStatus{Code: 1, Message: "backend unavailable"}
+		}),
+	)
+
+	server := &ExtensionManagerServer{
+		registry: map[string](map[string]OsqueryPlugin){"table": {}},
+	}
+	server.RegisterPlugin(plugin)
+
+	status, err := server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+
+	healthy = false
+	status, err = server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), status.Code)
+	assert.Equal(t, "backend unavailable", status.Message)
+
+	healthy = true
+	status, err = server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), status.Code)
+}
+
+type closeCounter struct {
+	closes int
+}
+
+func (c *closeCounter) Close() error {
+	c.closes++
+	return nil
+}
+
+func TestShutdownClosesTableSet(t *testing.T) {
+	registry := make(map[string](map[string]OsqueryPlugin))
+	for reg := range validRegistryNames {
+		registry[reg] = make(map[string]OsqueryPlugin)
+	}
+	mock := &MockExtensionManager{
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server := &ExtensionManagerServer{serverClient: mock, registry: registry}
+
+	backend := &closeCounter{}
+	set := table.NewSet(backend)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		set.Add(name, []table.ColumnDefinition{table.TextColumn("col")}, nil)
+	}
+	for _, plugin := range set.Plugins() {
+		server.RegisterPlugin(plugin)
+	}
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, 1, backend.closes)
+}
+
+func TestCallTimeoutDeadline(t *testing.T) {
+	var testCases = []struct {
+		opts        []ServerOption
+		hasDeadline bool
+	}{
+		{nil, false},
+		{[]ServerOption{WithCallTimeout(time.Minute)}, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run("", func(t *testing.T) {
+			var deadline time.Time
+			var ok bool
+			plugin := table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("bar")},
+				func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+					deadline, ok = ctx.Deadline()
+					return nil, nil
+				},
+			)
+			server := newTestServer(plugin, tt.opts...)
+
+			start := time.Now()
+			_, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.hasDeadline, ok)
+			if tt.hasDeadline {
+				assert.WithinDuration(t, start.Add(time.Minute), deadline, time.Second)
+			}
+		})
+	}
+}
+
+func TestUnknownActionLogged(t *testing.T) {
+	logger := &testLogger{}
+	server := newTestServer(newTestTable("foo"), WithLogger(logger))
+
+	resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "frobnicate"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Equal(t, "unknown action: frobnicate", resp.Status.Message)
+	require.Len(t, logger.lines(), 1)
+	assert.True(t, strings.HasPrefix(logger.lines()[0], fmt.Sprint("level", "warn", "msg", "unknown action", "plugin", "table/foo", "action", "frobnicate", "request_id")))
+
+	// Known actions are not logged, even when they fail.
+	resp, err = server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "bad"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), resp.Status.Code)
+	assert.Len(t, logger.lines(), 1)
+
+	// Nor are missing actions, including in a nil request.
+	resp, err = server.Call(context.Background(), "table", "foo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "missing 'action' in request", resp.Status.Message)
+	assert.Len(t, logger.lines(), 1)
+}
+
+func TestRegisterPluginLogsUnknownColumnTypes(t *testing.T) {
+	logger := &testLogger{}
+	server := newTestServer(newTestTable("known"), WithLogger(logger))
+	server.RegisterPlugin(table.NewPlugin("custom", []table.ColumnDefinition{
+		table.TextColumn("name"),
+		{Name: "size", Type: "UNSIGNED BIGINT"},
+		{Name: "untyped"},
+	}, nil))
+
+	assert.Equal(t, []string{
+		fmt.Sprint("level", "warn", "msg", "unknown column type", "table", "custom", "column", "size", "type", "UNSIGNED BIGINT"),
+		fmt.Sprint("level", "warn", "msg", "column without type", "table", "custom", "column", "untyped"),
+	}, logger.lines())
+
+	// The table is registered with the type unchanged
+	registry := server.genRegistry()
+	assert.Equal(t, "UNSIGNED BIGINT", registry["table"]["custom"][1]["type"])
+}
+
+func TestShutdownDeregistration(t *testing.T) {
+	newMock := func(deregisterErr error) *MockExtensionManager {
+		return &MockExtensionManager{
+			DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+				if deregisterErr != nil {
+					return nil, deregisterErr
+				}
+				return &osquery.ExtensionStatus{Code: 0, Message: "OK"}, nil
+			},
+			CloseFunc: func() error { return nil },
+		}
+	}
+
+	// Enabled by default
+	mock := newMock(nil)
+	server := newTestServer(newTestTable("foo"), WithClient(mock))
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+
+	// Disabled
+	mock = newMock(nil)
+	server = newTestServer(newTestTable("foo"), WithClient(mock), WithDeregisterOnShutdown(false))
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.False(t, mock.DeRegisterExtensionFuncInvoked)
+	assert.True(t, mock.CloseFuncInvoked)
+
+	// osquery already gone
+	mock = newMock(thrift.NewTTransportException(thrift.NOT_OPEN, "connection closed"))
+	server = newTestServer(newTestTable("foo"), WithClient(mock))
+	assert.NoError(t, server.Shutdown(context.Background()))
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+
+	// Other errors are still reported
+	mock = newMock(errors.New("boom"))
+	server = newTestServer(newTestTable("foo"), WithClient(mock))
+	assert.True(t, errors.Is(server.Shutdown(context.Background()), ErrDeregistrationFailed))
+}
+
+func TestGenRegistryRoutes(t *testing.T) {
+	server := newTestServer(table.NewPlugin("users", []table.ColumnDefinition{table.TextColumn("name")}, nil))
+	server.RegisterPlugin(
+		logger.NewPlugin("log", nil),
+		config.NewPlugin("conf", nil),
+		distributed.NewPlugin("dist", nil, nil),
+		carver.NewPlugin("carve", nil, nil),
+	)
+
+	// osquery only expects routes for tables, describing the columns.
+	// Plugins of the other registries are sent with an empty route
+	// table.
+	assert.Equal(t, osquery.ExtensionRegistry{
+		"table": {"users": {
+			{"id": "column", "name": "name", "type": "TEXT", "op": "0"},
+		}},
+		"logger":      {"log": {}},
+		"config":      {"conf": {}},
+		"distributed": {"dist": {}},
+		"carver":      {"carve": {}},
+	}, server.genRegistry())
+}
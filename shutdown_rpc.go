@@ -0,0 +1,21 @@
+package osquery
+
+import "context"
+
+// extensionHandler handles the requests of osquery on the extension socket.
+// They are served by the server, except that a shutdown request from osquery
+// (eg. when osquery itself shuts down) is logged and waits at most the drain
+// timeout for the in-flight calls, as for shutdowns initiated by Run.
+type extensionHandler struct {
+	*ExtensionManagerServer
+}
+
+func (h extensionHandler) Shutdown(ctx context.Context) error {
+	h.log("level", "info", "msg", "shutdown requested by osquery")
+	if h.drainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.drainTimeout)
+		defer cancel()
+	}
+	return h.ExtensionManagerServer.Shutdown(ctx)
+}
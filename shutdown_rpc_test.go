@@ -0,0 +1,73 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownRequestedByOsquery(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{UUID: 7}, nil
+		},
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{}}, nil
+		},
+		PingFunc: func() (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	shutdown := make(chan struct{})
+	logger := &testLogger{}
+	server, err := NewExtensionManagerServer("test", tempPath.Name(), WithClient(mock), WithSignals(), WithLogger(logger))
+	require.NoError(t, err)
+	server.RegisterPlugin(table.NewPlugin("foo", []table.ColumnDefinition{table.TextColumn("foo")}, nil,
+		table.WithShutdown(func() { close(shutdown) }),
+	))
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Run() }()
+	server.waitStarted()
+
+	// Send the shutdown request osquery sends on the extension socket.
+	addr, err := net.ResolveUnixAddr("unix", fmt.Sprintf("%s.%d", tempPath.Name(), 7))
+	require.NoError(t, err)
+	trans := thrift.NewTSocketFromAddrTimeout(addr, 5*time.Second, 5*time.Second)
+	require.NoError(t, trans.Open())
+	defer trans.Close()
+	client := osquery.NewExtensionClientFactory(trans, thrift.NewTBinaryProtocolFactoryDefault())
+	require.NoError(t, client.Shutdown(context.Background()))
+
+	select {
+	case err := <-errc:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the shutdown request")
+	}
+	select {
+	case <-shutdown:
+	default:
+		t.Error("table not shut down")
+	}
+	assert.True(t, mock.DeRegisterExtensionFuncInvoked)
+	assert.Contains(t, logger.lines(), fmt.Sprint("level", "info", "msg", "shutdown requested by osquery"))
+}
@@ -0,0 +1,26 @@
+package osquery
+
+import (
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// WithSlowCallThreshold logs the plugin calls taking longer than threshold to
+// dispatch, interceptors included, with the plugin name, action and duration.
+// The constraints of the query are also logged for table generate calls, so
+// that the slow queries can be identified. A threshold of zero or less
+// disables the logging, which is the default.
+func WithSlowCallThreshold(threshold time.Duration) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.slowCallThreshold = threshold
+	}
+}
+
+func (s *ExtensionManagerServer) logSlowCall(id, registry, item string, request osquery.ExtensionPluginRequest, duration time.Duration) {
+	keyvals := []interface{}{"level", "warn", "msg", "slow call", "plugin", registry + "/" + item, "action", request["action"], "duration", duration}
+	if registry == "table" && request["action"] == "generate" {
+		keyvals = append(keyvals, "constraints", renderConstraints(request))
+	}
+	s.log(append(keyvals, "request_id", id)...)
+}
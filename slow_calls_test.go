@@ -0,0 +1,44 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowCallThreshold(t *testing.T) {
+	newTable := func(name string, delay time.Duration) *table.Plugin {
+		return table.NewPlugin(name, []table.ColumnDefinition{table.TextColumn("path")},
+			func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+				time.Sleep(delay)
+				return nil, nil
+			},
+		)
+	}
+	logger := &testLogger{}
+	server := newTestServer(newTable("slow", 50*time.Millisecond), WithSlowCallThreshold(20*time.Millisecond), WithLogger(logger))
+	server.RegisterPlugin(newTable("fast", 0))
+
+	request := osquery.ExtensionPluginRequest{
+		"action":  "generate",
+		"context": `{"constraints":[{"name":"path","list":[{"op":2,"expr":"/etc/hosts"}],"affinity":"TEXT"}]}`,
+	}
+	_, err := server.Call(context.Background(), "table", "fast", request)
+	require.NoError(t, err)
+	assert.Empty(t, logger.lines())
+
+	_, err = server.Call(context.Background(), "table", "slow", request)
+	require.NoError(t, err)
+	lines := logger.lines()
+	require.Len(t, lines, 1)
+	prefix := fmt.Sprint("level", "warn", "msg", "slow call", "plugin", "table/slow", "action", "generate", "duration")
+	assert.True(t, strings.HasPrefix(lines[0], prefix), lines[0])
+	assert.Contains(t, lines[0], fmt.Sprint("constraints", "path = '/etc/hosts'", "request_id"))
+}
@@ -0,0 +1,48 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// Status codes returned by the extension in addition to 0 (success) and 1
+// (failure). osquery treats every non-zero code as a failure, so these only
+// allow the extension's callers and metrics to tell the failures apart.
+const (
+	// StatusOverloaded is returned for the generate calls rejected by
+	// WithLoadShedding.
+	StatusOverloaded int32 = 2
+	// StatusInitializing is returned for the calls received before
+	// MarkReady, if WithReadinessGate is set.
+	StatusInitializing int32 = 3
+	// StatusCanceled is returned for the calls failing once their
+	// context is canceled, eg. when a generate function returns
+	// ctx.Err() after osquery disconnected or the server shut down.
+	StatusCanceled int32 = 4
+	// StatusDeadlineExceeded is returned for the calls failing once
+	// their deadline is exceeded (see WithCallTimeout and
+	// WithOsqueryTimeout).
+	StatusDeadlineExceeded int32 = 5
+)
+
+// NewStatus returns a status with all its fields set. The UUID identifies the
+// extension the status is returned by, as assigned by osquery on
+// registration. The server sets it on the statuses of all its responses to
+// osquery, so plugins do not need to set it.
+func NewStatus(code int32, message string, uuid osquery.ExtensionRouteUUID) *osquery.ExtensionStatus {
+	return &osquery.ExtensionStatus{Code: code, Message: message, UUID: uuid}
+}
+
+// contextErrorCode returns the status code of a call failing once its
+// context is done with err, or 1 if err is not a context error.
+func contextErrorCode(err error) int32 {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusDeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return StatusCanceled
+	}
+	return 1
+}
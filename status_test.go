@@ -0,0 +1,64 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusUUID(t *testing.T) {
+	tempPath, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tempPath.Name())
+	defer os.Remove(tempPath.Name() + ".42")
+
+	mock := &MockExtensionManager{
+		RegisterExtensionFunc: func(info *osquery.InternalExtensionInfo, registry osquery.ExtensionRegistry) (*osquery.ExtensionStatus, error) {
+			return NewStatus(0, "OK", 42), nil
+		},
+		DeRegisterExtensionFunc: func(uuid osquery.ExtensionRouteUUID) (*osquery.ExtensionStatus, error) {
+			return &osquery.ExtensionStatus{}, nil
+		},
+		CloseFunc: func() error { return nil },
+	}
+	server, err := NewExtensionManagerServer("test", tempPath.Name(), WithClient(mock))
+	require.NoError(t, err)
+	server.RegisterPlugin(newTestTable("foo"))
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Start() }()
+	server.waitStarted()
+
+	status, err := server.Ping(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, NewStatus(0, "OK", 42), status)
+
+	resp, err := server.Call(context.Background(), "table", "foo", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, osquery.ExtensionRouteUUID(42), resp.Status.UUID)
+	assert.Equal(t, int32(0), resp.Status.Code)
+
+	for _, call := range []struct{ registry, item string }{{"foo", "foo"}, {"table", "bar"}} {
+		resp, err = server.Call(context.Background(), call.registry, call.item, osquery.ExtensionPluginRequest{"action": "generate"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), resp.Status.Code)
+		assert.Equal(t, osquery.ExtensionRouteUUID(42), resp.Status.UUID)
+	}
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.NoError(t, <-errc)
+}
+
+func TestContextErrorCode(t *testing.T) {
+	assert.Equal(t, StatusCanceled, contextErrorCode(context.Canceled))
+	assert.Equal(t, StatusDeadlineExceeded, contextErrorCode(context.DeadlineExceeded))
+	assert.Equal(t, StatusDeadlineExceeded, contextErrorCode(fmt.Errorf("querying backend: %w", context.DeadlineExceeded)))
+	assert.Equal(t, int32(1), contextErrorCode(errors.New("boom!")))
+}
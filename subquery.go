@@ -0,0 +1,105 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+)
+
+// ErrQueryLoop is returned by QueryInGenerate when the query references the
+// table being generated, which would make osquery call back into the table
+// indefinitely.
+var ErrQueryLoop = errors.New("query references the table being generated")
+
+type callKey struct{}
+
+// callInfo describes the plugin call being served, for QueryInGenerate.
+type callInfo struct {
+	client   ExtensionManager
+	registry string
+	item     string
+}
+
+// withCallInfo returns a copy of ctx carrying the client used by the server
+// and the plugin being called.
+func withCallInfo(ctx context.Context, client ExtensionManager, registry, item string) context.Context {
+	return context.WithValue(ctx, callKey{}, callInfo{client: client, registry: registry, item: item})
+}
+
+// ClientFromContext returns the client the extension uses to communicate with
+// osquery, when ctx is the context of a plugin call served by an
+// ExtensionManagerServer. Prefer QueryInGenerate for queries made while
+// generating a table.
+func ClientFromContext(ctx context.Context) (ExtensionManager, bool) {
+	info, ok := ctx.Value(callKey{}).(callInfo)
+	if !ok || info.client == nil {
+		return nil, false
+	}
+	return info.client, true
+}
+
+// QueryInGenerate runs a query against osquery from within a plugin call,
+// reusing the connection of the extension, and returns the resulting rows.
+// It allows tables to enrich their rows with data from other osquery tables.
+// ctx must be the context passed to the plugin (eg. to a table GenerateFunc).
+//
+// QueryInGenerate returns once ctx is done, even if osquery has not yet
+// responded, so that the deadline of the plugin call also bounds the query.
+//
+// As a reentrancy guard, QueryInGenerate returns ErrQueryLoop without running
+// the query if it references the table being generated, as osquery would
+// call the table again to answer the query. The guard only inspects the
+// query text: a loop through another table, or through a view, is not
+// detected.
+func QueryInGenerate(ctx context.Context, sql string) ([]map[string]string, error) {
+	info, ok := ctx.Value(callKey{}).(callInfo)
+	if !ok || info.client == nil {
+		return nil, errors.New("context is not from a plugin call served by an extension")
+	}
+	if info.registry == "table" && referencesTable(sql, info.item) {
+		return nil, fmt.Errorf("%w: %s", ErrQueryLoop, info.item)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		res *osquery.ExtensionResponse
+		err error
+	}
+	// Buffered so that the query does not block once ctx is done.
+	resc := make(chan result, 1)
+	go func() {
+		res, err := info.client.Query(sql)
+		resc <- result{res, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-resc:
+		if r.err != nil {
+			return nil, fmt.Errorf("transport error in query: %w", r.err)
+		}
+		if r.res.Status == nil {
+			return nil, errors.New("query returned nil status")
+		}
+		if r.res.Status.Code != 0 {
+			return nil, &OsqueryError{
+				Code:    int(r.res.Status.Code),
+				Message: r.res.Status.Message,
+				Query:   sql,
+			}
+		}
+		return r.res.Response, nil
+	}
+}
+
+// referencesTable returns true if sql contains name as an identifier.
+func referencesTable(sql, name string) bool {
+	re := regexp.MustCompile(`(?i)(^|[^\w$])` + regexp.QuoteMeta(name) + `($|[^\w$])`)
+	return re.MatchString(sql)
+}
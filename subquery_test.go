@@ -0,0 +1,85 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSubqueryServer returns a server with a table named "enriched" running
+// sql with QueryInGenerate, and a manager answering queries with a single
+// row.
+func newSubqueryServer(sql string) (*ExtensionManagerServer, *MockExtensionManager) {
+	mock := &MockExtensionManager{
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: osquery.ExtensionPluginResponse{{"pid": "1"}},
+			}, nil
+		},
+	}
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		return QueryInGenerate(ctx, sql)
+	}
+	plugin := table.NewPlugin("enriched", []table.ColumnDefinition{table.TextColumn("pid")}, gen)
+	return newTestServer(plugin, WithClient(mock)), mock
+}
+
+func TestQueryInGenerate(t *testing.T) {
+	server, mock := newSubqueryServer("select pid from processes")
+
+	resp, err := server.Call(context.Background(), "table", "enriched", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+	assert.Equal(t, osquery.ExtensionPluginResponse{{"pid": "1"}}, resp.Response)
+	assert.True(t, mock.QueryFuncInvoked)
+}
+
+func TestQueryInGenerateLoop(t *testing.T) {
+	for _, sql := range []string{
+		"select * from enriched",
+		"SELECT pid FROM processes JOIN Enriched USING (pid)",
+		`select * from "enriched"`,
+	} {
+		server, mock := newSubqueryServer(sql)
+
+		resp, err := server.Call(context.Background(), "table", "enriched", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), resp.Status.Code, sql)
+		assert.Contains(t, resp.Status.Message, ErrQueryLoop.Error(), sql)
+		assert.False(t, mock.QueryFuncInvoked, sql)
+	}
+
+	// Similarly named tables are not a loop.
+	assert.False(t, referencesTable("select * from enriched_users", "enriched"))
+}
+
+func TestQueryInGenerateDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	mock := &MockExtensionManager{
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			<-release
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}, nil
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx = withCallInfo(ctx, mock, "table", "enriched")
+
+	_, err := QueryInGenerate(ctx, "select * from processes")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestQueryInGenerateOutsideCall(t *testing.T) {
+	_, err := QueryInGenerate(context.Background(), "select 1")
+	assert.Error(t, err)
+	_, ok := ClientFromContext(context.Background())
+	assert.False(t, ok)
+}
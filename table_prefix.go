@@ -0,0 +1,51 @@
+package osquery
+
+import "fmt"
+
+// WithTablePrefix prefixes the names of all the table plugins registered with
+// the server, so that an extension can be deployed alongside others without
+// its tables colliding with theirs, and without renaming each plugin. For
+// example, with the prefix "acme_" a table plugin named "users" is registered
+// with osquery, and queried, as "acme_users". Name returns the prefixed name
+// for the plugins registered. Other plugins are not renamed.
+//
+// The prefix must start with a letter or underscore, followed by letters,
+// digits or underscores. NewExtensionManagerServer returns an error for any
+// other prefix.
+func WithTablePrefix(prefix string) ServerOption {
+	return func(s *ExtensionManagerServer) {
+		s.tablePrefix = prefix
+	}
+}
+
+// validTablePrefix returns an error if prefix cannot start a table name.
+func validTablePrefix(prefix string) error {
+	for i, c := range prefix {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return fmt.Errorf("invalid table prefix %q: must be letters, digits and underscores, not starting with a digit", prefix)
+		}
+	}
+	return nil
+}
+
+// prefixedPlugin is a table plugin registered under a prefixed name.
+type prefixedPlugin struct {
+	OsqueryPlugin
+	name string
+}
+
+func (p prefixedPlugin) Name() string {
+	return p.name
+}
+
+// withTablePrefix returns plugin renamed with the table prefix, if it is a
+// table plugin and a prefix is set.
+func (s *ExtensionManagerServer) withTablePrefix(plugin OsqueryPlugin) OsqueryPlugin {
+	if s.tablePrefix == "" || plugin.RegistryName() != "table" {
+		return plugin
+	}
+	return prefixedPlugin{OsqueryPlugin: plugin, name: s.tablePrefix + plugin.Name()}
+}
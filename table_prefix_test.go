@@ -0,0 +1,54 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTablePrefix(t *testing.T) {
+	server := newTestServer(newTestTable("users"), WithTablePrefix("acme_"))
+	server.RegisterPlugin(config.NewPlugin("static", nil))
+
+	registry := server.genRegistry()
+	assert.Equal(t, []string{"acme_users"}, routeNames(registry["table"]))
+	assert.Equal(t, []string{"static"}, routeNames(registry["config"]))
+	assert.Equal(t, "acme_users", server.registry["table"]["acme_users"].Name())
+
+	resp, err := server.Call(context.Background(), "table", "acme_users", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+
+	resp, err = server.Call(context.Background(), "table", "users", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+	require.NoError(t, err)
+	assert.Equal(t, "Unknown registry item: users", resp.Status.Message)
+}
+
+func TestTablePrefixPluginOptions(t *testing.T) {
+	server := newTestServer(newTestTable("other"), WithTablePrefix("acme_"))
+	server.RegisterPluginWithOptions(newTestTable("users"), WithPluginTimeout(42))
+	assert.Equal(t, int64(42), int64(server.callTimeoutFor(server.pluginConfigFor("table", "acme_users"))))
+}
+
+func TestTablePrefixInvalid(t *testing.T) {
+	for _, prefix := range []string{"1acme", "acme-", "acme ", "a;b", "é"} {
+		_, err := NewExtensionManagerServer("test", "/tmp/osquery.em", WithClient(&MockExtensionManager{}), WithTablePrefix(prefix))
+		assert.Error(t, err, prefix)
+	}
+	for _, prefix := range []string{"", "acme_", "_a1", "A"} {
+		_, err := NewExtensionManagerServer("test", "/tmp/osquery.em", WithClient(&MockExtensionManager{}), WithTablePrefix(prefix))
+		assert.NoError(t, err, prefix)
+	}
+}
+
+func routeNames(routes osquery.ExtensionRouteTable) []string {
+	var names []string
+	for name := range routes {
+		names = append(names, name)
+	}
+	return names
+}
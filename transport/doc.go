@@ -2,3 +2,13 @@
 // implementations for use on mac/linux (TSocket/TServerSocket) and Windows
 // (custom named pipe implementation).
 package transport
+
+import "errors"
+
+// ErrSocketUnavailable is returned by Open when the socket (or named pipe)
+// does not become available within the timeout.
+var ErrSocketUnavailable = errors.New("waiting for socket to be available")
+
+// ErrSocketPathTooLong is returned by Open and OpenServer when the socket path
+// exceeds the length supported by the platform for unix domain sockets.
+var ErrSocketPathTooLong = errors.New("socket path too long")
@@ -4,20 +4,24 @@ package transport
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"os"
+	"runtime"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
-	"github.com/pkg/errors"
 )
 
 // Open opens the unix domain socket with the provided path and timeout,
 // returning a TTransport.
 func Open(sockPath string, timeout time.Duration) (*thrift.TSocket, error) {
+	if err := checkSocketPath(sockPath); err != nil {
+		return nil, err
+	}
 	addr, err := net.ResolveUnixAddr("unix", sockPath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "resolving socket path '%s'", sockPath)
+		return nil, fmt.Errorf("resolving socket path '%s': %w", sockPath, err)
 	}
 
 	// the timeout parameter is passed to thrift, which passes it to net.DialTimeout
@@ -25,27 +29,59 @@ func Open(sockPath string, timeout time.Duration) (*thrift.TSocket, error) {
 	// waitForSocket will loop every 200ms to stat the socket path,
 	// or until the timeout value passes, similar to the C++ and python implementations.
 	if err := waitForSocket(sockPath, timeout); err != nil {
-		return nil, errors.Wrapf(err, "waiting for unix socket to be available: %s", sockPath)
+		return nil, fmt.Errorf("%w: %s: %v", ErrSocketUnavailable, sockPath, err)
 	}
 
 	trans := thrift.NewTSocketFromAddrTimeout(addr, timeout, timeout)
 	if err := trans.Open(); err != nil {
-		return nil, errors.Wrap(err, "opening socket transport")
+		return nil, fmt.Errorf("opening socket transport: %w", err)
 	}
 
 	return trans, nil
 }
 
 func OpenServer(listenPath string, timeout time.Duration) (*thrift.TServerSocket, error) {
+	if err := checkSocketPath(listenPath); err != nil {
+		return nil, err
+	}
 	addr, err := net.ResolveUnixAddr("unix", listenPath)
 	if err != nil {
-		return nil, errors.Wrapf(err, "resolving addr (%s)", addr)
+		return nil, fmt.Errorf("resolving addr (%s): %w", addr, err)
 	}
 
 	return thrift.NewTServerSocketFromAddrTimeout(addr, 0), nil
 }
 
+// maxSocketPathLen returns the maximum length of a unix domain socket path on
+// the platform, or 0 if unknown. The limit is set by the size of sun_path in
+// struct sockaddr_un, which must also hold a NUL terminator.
+func maxSocketPathLen() int {
+	switch runtime.GOOS {
+	case "linux", "android":
+		return 107
+	case "darwin", "ios", "freebsd", "netbsd", "openbsd", "dragonfly":
+		return 103
+	}
+	return 0
+}
+
+// checkSocketPath returns an error if path is too long to be used as a socket,
+// rather than leaving the bind or connect call failing with "invalid
+// argument".
+func checkSocketPath(path string) error {
+	if max := maxSocketPathLen(); max > 0 && len(path) > max {
+		return fmt.Errorf("%w: %s is %d bytes, exceeding the maximum of %d bytes on %s; use a shorter path, eg. by setting TMPDIR to a shorter directory",
+			ErrSocketPathTooLong, path, len(path), max, runtime.GOOS)
+	}
+	return nil
+}
+
 func waitForSocket(sockPath string, timeout time.Duration) error {
+	// Avoid waiting for the first tick when the socket already exists.
+	if _, err := os.Stat(sockPath); err == nil {
+		return nil
+	}
+
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
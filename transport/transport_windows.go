@@ -1,12 +1,14 @@
 package transport
 
 import (
+	"errors"
+	"fmt"
 	"net"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/Microsoft/go-winio"
-	"github.com/pkg/errors"
 
 	"github.com/apache/thrift/lib/go/thrift"
 )
@@ -16,7 +18,10 @@ import (
 func Open(path string, timeout time.Duration) (*thrift.TSocket, error) {
 	conn, err := winio.DialPipe(path, &timeout)
 	if err != nil {
-		return nil, errors.Wrapf(err, "dialing pipe '%s'", path)
+		if err == winio.ErrTimeout || os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s: %v", ErrSocketUnavailable, path, err)
+		}
+		return nil, fmt.Errorf("dialing pipe '%s': %w", path, err)
 	}
 	return thrift.NewTSocketFromConnTimeout(conn, timeout), nil
 }
@@ -0,0 +1,86 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// versionCache caches the version of the osquery instance the server is
+// registered with.
+type versionCache struct {
+	mu      sync.Mutex
+	version string
+}
+
+type versionKey struct{}
+
+// OsqueryVersionFromContext returns the version of osquery (eg. "5.2.3"),
+// when ctx is the context of a plugin call served by an
+// ExtensionManagerServer. It allows plugins to adapt their responses to the
+// osquery version in use, eg. to omit a column that older versions do not
+// handle:
+//
+//	version, err := osquery.OsqueryVersionFromContext(ctx)
+//	if err == nil && !osquery.VersionAtLeast(version, "5.0.0") {
+//		delete(row, "new_column")
+//	}
+//
+// The version is queried from the osquery_info table on first use and then
+// cached by the server, so it does not delay calls of plugins that do not use
+// it. Errors are not cached.
+func OsqueryVersionFromContext(ctx context.Context) (string, error) {
+	cache, ok := ctx.Value(versionKey{}).(*versionCache)
+	if !ok {
+		return "", errors.New("context is not from a plugin call served by an extension")
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.version != "" {
+		return cache.version, nil
+	}
+	rows, err := QueryInGenerate(ctx, "SELECT version FROM osquery_info")
+	if err != nil {
+		return "", err
+	}
+	if len(rows) != 1 || rows[0]["version"] == "" {
+		return "", errors.New("osquery_info returned no version")
+	}
+	cache.version = rows[0]["version"]
+	return cache.version, nil
+}
+
+func withVersionCache(ctx context.Context, cache *versionCache) context.Context {
+	return context.WithValue(ctx, versionKey{}, cache)
+}
+
+// VersionAtLeast returns true if the dotted version (eg. "4.9.0") is greater
+// than or equal to min. Components are compared numerically, with missing
+// components counting as zero, and any suffix of a component (eg. the "-12"
+// of "5.2.3-12-gdeadbeef") is ignored.
+func VersionAtLeast(version, min string) bool {
+	v, m := strings.Split(version, "."), strings.Split(min, ".")
+	for i := 0; i < len(v) || i < len(m); i++ {
+		a, b := versionComponent(v, i), versionComponent(m, i)
+		if a != b {
+			return a > b
+		}
+	}
+	return true
+}
+
+func versionComponent(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	part := parts[i]
+	end := 0
+	for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(part[:end])
+	return n
+}
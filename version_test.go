@@ -0,0 +1,73 @@
+package osquery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/plugin/table"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryVersionFromContext(t *testing.T) {
+	var queries int
+	mock := &MockExtensionManager{
+		QueryFunc: func(sql string) (*osquery.ExtensionResponse, error) {
+			queries++
+			assert.Equal(t, "SELECT version FROM osquery_info", sql)
+			return &osquery.ExtensionResponse{
+				Status:   &osquery.ExtensionStatus{Code: 0, Message: "OK"},
+				Response: osquery.ExtensionPluginResponse{{"version": "4.9.0"}},
+			}, nil
+		},
+	}
+	gen := func(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+		row := map[string]string{"name": "foo", "new_column": "bar"}
+		version, err := OsqueryVersionFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !VersionAtLeast(version, "5.0.0") {
+			delete(row, "new_column")
+		}
+		return []map[string]string{row}, nil
+	}
+	plugin := table.NewPlugin("versioned", []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("new_column"),
+	}, gen)
+	server := newTestServer(plugin, WithClient(mock))
+
+	for i := 0; i < 2; i++ {
+		resp, err := server.Call(context.Background(), "table", "versioned", osquery.ExtensionPluginRequest{"action": "generate", "context": "{}"})
+		require.NoError(t, err)
+		require.Equal(t, int32(0), resp.Status.Code, resp.Status.Message)
+		assert.Equal(t, osquery.ExtensionPluginResponse{{"name": "foo"}}, resp.Response)
+	}
+	// The version is cached after the first call.
+	assert.Equal(t, 1, queries)
+}
+
+func TestOsqueryVersionFromContextOutsideCall(t *testing.T) {
+	_, err := OsqueryVersionFromContext(context.Background())
+	assert.Error(t, err)
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	for _, tt := range []struct {
+		version, min string
+		expected     bool
+	}{
+		{"5.0.0", "5.0.0", true},
+		{"5.0.1", "5.0.0", true},
+		{"4.9.0", "5.0.0", false},
+		{"5.10.0", "5.9.0", true},
+		{"5.2", "5.2.0", true},
+		{"5.2", "5.2.1", false},
+		{"5.2.3-12-gdeadbeef", "5.2.3", true},
+		{"5.2.2-12-gdeadbeef", "5.2.3", false},
+	} {
+		assert.Equal(t, tt.expected, VersionAtLeast(tt.version, tt.min), "%s >= %s", tt.version, tt.min)
+	}
+}
@@ -0,0 +1,35 @@
+package osquery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// waitTableInterval is the delay between the queries made by WaitForTable.
+const waitTableInterval = 100 * time.Millisecond
+
+// WaitForTable waits until the table name can be queried through osquery, eg.
+// after starting an extension providing it, as osquery only attaches the
+// tables of an extension once it has registered. It polls osquery with a
+// query selecting no rows from the table, which does not generate the table.
+// It returns an error wrapping the ctx error and the error of the last query
+// if the table is not queryable before ctx is done.
+func (c *ExtensionManagerClient) WaitForTable(ctx context.Context, name string) error {
+	sql := `SELECT * FROM "` + strings.Replace(name, `"`, `""`, -1) + `" LIMIT 0`
+	for {
+		_, err := c.QueryRows(sql)
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(waitTableInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("waiting for table %s: %w (last error: %v)", name, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package osquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/osquery/osquery-go/gen/osquery"
+	"github.com/osquery/osquery-go/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitForTable(t *testing.T) {
+	var queries []string
+	mock := &mock.ExtensionManager{
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			queries = append(queries, sql)
+			if len(queries) < 3 {
+				return &osquery.ExtensionResponse{
+					Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table: my_table"},
+				}, nil
+			}
+			return &osquery.ExtensionResponse{Status: &osquery.ExtensionStatus{Code: 0, Message: "OK"}}, nil
+		},
+	}
+	client := &ExtensionManagerClient{Client: mock}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, client.WaitForTable(ctx, "my_table"))
+	require.Len(t, queries, 3)
+	assert.Equal(t, `SELECT * FROM "my_table" LIMIT 0`, queries[0])
+}
+
+func TestWaitForTableTimeout(t *testing.T) {
+	mock := &mock.ExtensionManager{
+		QueryFunc: func(ctx context.Context, sql string) (*osquery.ExtensionResponse, error) {
+			return &osquery.ExtensionResponse{
+				Status: &osquery.ExtensionStatus{Code: 1, Message: "no such table: my_table"},
+			}, nil
+		},
+	}
+	client := &ExtensionManagerClient{Client: mock}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	err := client.WaitForTable(ctx, `my"table`)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "no such table: my_table")
+	assert.True(t, mock.QueryFuncInvoked)
+}